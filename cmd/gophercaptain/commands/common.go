@@ -1,16 +1,22 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/ecairns22/GopherCaptain/internal/caddy"
 	"github.com/ecairns22/GopherCaptain/internal/config"
 	"github.com/ecairns22/GopherCaptain/internal/db"
 	ghclient "github.com/ecairns22/GopherCaptain/internal/github"
+	"github.com/ecairns22/GopherCaptain/internal/haproxy"
 	"github.com/ecairns22/GopherCaptain/internal/nginx"
 	"github.com/ecairns22/GopherCaptain/internal/orchestrator"
 	"github.com/ecairns22/GopherCaptain/internal/runner"
 	"github.com/ecairns22/GopherCaptain/internal/state"
 	"github.com/ecairns22/GopherCaptain/internal/systemd"
+	"github.com/ecairns22/GopherCaptain/internal/templates"
 )
 
 const stateDBPath = "/var/lib/gophercaptain/state.db"
@@ -36,25 +42,98 @@ func buildOrchestrator() (*orchestrator.Orchestrator, func(), error) {
 	}
 
 	r := &runner.OSRunner{}
-	sys := systemd.New(r, unitDir)
-	ngx := nginx.New(r, cfg.Nginx.SitesDir, cfg.Nginx.EnabledDir)
+	tmpl := templates.New(cfg.Templates.Dir)
+	sys, closeSys := newSystemd(context.Background(), r)
+	sys.WithTemplates(tmpl)
+	proxy := newProxy(cfg, r, tmpl)
 
-	dbMgr, err := db.NewFromConfig(cfg)
+	dbMgr, err := db.NewFromConfig(cfg, r)
 	if err != nil {
+		closeSys()
 		store.Close()
-		return nil, nil, fmt.Errorf("connecting to MariaDB: %w", err)
+		return nil, nil, fmt.Errorf("connecting to databases: %w", err)
 	}
 
-	orc := orchestrator.New(cfg, store, gh, sys, ngx, dbMgr)
+	orc := orchestrator.New(cfg, store, gh, sys, proxy, dbMgr)
 
 	cleanup := func() {
 		dbMgr.Close()
+		closeSys()
 		store.Close()
 	}
 
 	return orc, cleanup, nil
 }
 
+// newProxy builds the reverse proxy manager selected by [proxy] kind.
+// Override templates only apply to nginx.
+func newProxy(cfg *config.Config, r runner.CommandRunner, tmpl *templates.Dir) orchestrator.Proxy {
+	switch cfg.Proxy.Kind {
+	case "caddy":
+		return caddy.New(r, cfg.Proxy.Caddyfile, cfg.Proxy.CaddyDir)
+	case "haproxy":
+		return haproxy.New(r, cfg.Proxy.HAProxyConfig, cfg.Proxy.HAProxyDir)
+	}
+	return nginx.New(r, cfg.Nginx.SitesDir, cfg.Nginx.EnabledDir, cfg.Nginx.SnippetsDir).WithTemplates(tmpl)
+}
+
+// newSystemd builds a systemd manager that controls units over D-Bus, falling
+// back to systemctl when the system bus is unavailable (e.g. in containers).
+// The returned function releases the bus connection.
+func newSystemd(ctx context.Context, r runner.CommandRunner) (*systemd.Manager, func()) {
+	ctl, err := systemd.NewDBusController(ctx)
+	if err != nil {
+		return systemd.New(r, unitDir), func() {}
+	}
+	return systemd.NewWithController(r, ctl, unitDir), ctl.Close
+}
+
+// serviceStatus summarizes a service's live systemd state for list/status.
+// A socket-activated service that is idle but listening reports "listening",
+// a scheduled job waiting on its timer reports "scheduled", and a scaled
+// service with only some instances up reports "degraded (k/n)".
+func serviceStatus(ctx context.Context, sys *systemd.Manager, svc *state.Service) string {
+	if len(svc.Instances) > 0 {
+		up := 0
+		for _, port := range svc.Instances {
+			if active, _ := sys.IsInstanceActive(ctx, svc.Name, port); active {
+				up++
+			}
+		}
+		switch up {
+		case len(svc.Instances):
+			return "running"
+		case 0:
+			return "stopped"
+		default:
+			return fmt.Sprintf("degraded (%d/%d)", up, len(svc.Instances))
+		}
+	}
+	if active, _ := sys.IsActive(ctx, svc.Name); active {
+		return "running"
+	}
+	if svc.Schedule != "" {
+		if waiting, _ := sys.IsTimerActive(ctx, svc.Name); waiting {
+			return "scheduled"
+		}
+	}
+	if svc.SocketActivated {
+		if listening, _ := sys.IsSocketActive(ctx, svc.Name); listening {
+			return "listening"
+		}
+	}
+	return "stopped"
+}
+
+// joinPorts formats instance ports for display, e.g. "3000, 3004".
+func joinPorts(ports []int) string {
+	s := make([]string, len(ports))
+	for i, p := range ports {
+		s[i] = strconv.Itoa(p)
+	}
+	return strings.Join(s, ", ")
+}
+
 // buildStateOnly opens just the state store (for read-only commands like list/status).
 func buildStateOnly() (*state.Store, error) {
 	return state.Open(stateDBPath)
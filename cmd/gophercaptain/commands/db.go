@@ -0,0 +1,199 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func dbCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Back up and restore the databases of services",
+	}
+	cmd.AddCommand(dbBackupCmd(), dbBackupsCmd(), dbRestoreCmd(), dbScheduleCmd())
+	return cmd
+}
+
+func dbBackupCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "backup <service>",
+		Short: "Back up a service's database",
+		Long: `Dump a service's database with mysqldump (or pg_dump for --db postgres)
+and save it gzip-compressed as <name>-<timestamp>.sql.gz under
+[backups] dir/<name>/. Once saved, all but the newest [backups] keep
+backups (default 7) are deleted. The service keeps running.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			result, err := orc.BackupDatabase(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			fmt.Fprintf(w, "✓ %s backed up to %s (%s)\n", args[0], result.Backup.Path, formatSize(result.Backup.Size))
+			for _, b := range result.Pruned {
+				fmt.Fprintf(w, "  Pruned %s\n", b.Name)
+			}
+			return nil
+		},
+	}
+}
+
+func dbBackupsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "backups <service>",
+		Short: "List a service's database backups, newest first",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			backups, err := orc.Backups(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			if len(backups) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "No backups of %s.\n", args[0])
+				return nil
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "BACKUP\tCREATED\tSIZE")
+			for _, b := range backups {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", b.Name, b.Created.Format("2006-01-02 15:04:05"), formatSize(b.Size))
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func dbRestoreCmd() *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <service> <backup>",
+		Short: "Replace a service's database with a backup",
+		Long: `Replace a service's database with one of its backups, named as listed by
+'gophercaptain db backups' or "latest". The current database is backed up
+first, then the service is stopped, the backup loaded and the service
+started again. Routes serve the maintenance page meanwhile.
+
+Example:
+  gophercaptain db restore api latest
+  gophercaptain db restore api api-20260101-030000.sql.gz`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			w := cmd.OutOrStdout()
+			if !yes {
+				svc, err := orc.GetService(cmd.Context(), name)
+				if err != nil {
+					return fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", name)
+				}
+				fmt.Fprintf(w, "This will stop %s and replace %s database %q with %s. Continue? [y/N] ", name, svc.DBProvider, svc.DBName, args[1])
+				reader := bufio.NewReader(cmd.InOrStdin())
+				answer, _ := reader.ReadString('\n')
+				answer = strings.TrimSpace(strings.ToLower(answer))
+				if answer != "y" && answer != "yes" {
+					fmt.Fprintln(w, "Aborted.")
+					return nil
+				}
+			}
+
+			result, err := orc.RestoreDatabase(cmd.Context(), name, args[1], func(msg string) {
+				fmt.Fprintln(w, msg)
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "✓ %s restored from %s\n", name, result.Restored.Name)
+			fmt.Fprintf(w, "  Undo with: gophercaptain db restore %s %s\n", name, result.Safety.Name)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip confirmation")
+
+	return cmd
+}
+
+func dbScheduleCmd() *cobra.Command {
+	var off bool
+
+	cmd := &cobra.Command{
+		Use:   "schedule <service> [<calendar>]",
+		Short: "Back up a service's database on a schedule",
+		Long: `Install gophercaptain-backup-<name>.timer, which runs 'gophercaptain db
+backup <name>' on a systemd OnCalendar= schedule, pruning old backups as
+it goes. Runs missed while the machine was off happen at the next boot.
+Scheduling again replaces the schedule; --off removes the timer.
+
+Example:
+  gophercaptain db schedule api daily
+  gophercaptain db schedule api "*-*-* 03:00"
+  gophercaptain db schedule api --off`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if off == (len(args) == 2) {
+				return fmt.Errorf("give either a calendar expression or --off")
+			}
+			schedule := ""
+			if !off {
+				schedule = args[1]
+			}
+
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			if err := orc.ScheduleBackups(cmd.Context(), args[0], schedule); err != nil {
+				return err
+			}
+			if off {
+				fmt.Fprintf(cmd.OutOrStdout(), "✓ %s backups unscheduled\n", args[0])
+			} else {
+				fmt.Fprintf(cmd.OutOrStdout(), "✓ %s backups scheduled: %s\n", args[0], schedule)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&off, "off", false, "Stop scheduled backups")
+
+	return cmd
+}
+
+// formatSize formats a byte count in binary units.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/ecairns22/GopherCaptain/internal/orchestrator"
+	"github.com/ecairns22/GopherCaptain/internal/systemd"
 	"github.com/spf13/cobra"
 )
 
@@ -12,12 +13,33 @@ func deployCmd() *cobra.Command {
 	var (
 		version    string
 		port       int
-		route      string
+		routes     []string
 		routeType  string
 		name       string
 		envVars    []string
+		execArgs   string
+		workDir    string
+		socket     bool
+		hardening  string
+		schedule   string
+		preStart   string
+		tls        bool
+		noTLS      bool
+		tlsCert    string
+		tlsKey     string
 		noDB       bool
+		dbProvider string
 		configFile bool
+
+		proto       string
+		keepalive   int
+		readTimeout string
+		maxBodySize string
+
+		allow     []string
+		deny      []string
+		rateLimit string
+		rateBurst int
 	)
 
 	cmd := &cobra.Command{
@@ -37,6 +59,15 @@ func deployCmd() *cobra.Command {
 				extraEnv[parts[0]] = parts[1]
 			}
 
+			args, err := systemd.SplitArgs(execArgs)
+			if err != nil {
+				return fmt.Errorf("invalid --args: %w", err)
+			}
+			preStartArgs, err := systemd.SplitArgs(preStart)
+			if err != nil {
+				return fmt.Errorf("invalid --pre-start: %w", err)
+			}
+
 			orc, cleanup, err := buildOrchestrator()
 			if err != nil {
 				return err
@@ -48,11 +79,34 @@ func deployCmd() *cobra.Command {
 				Name:       name,
 				Version:    version,
 				Port:       port,
-				Route:      route,
+				Routes:     routes,
 				RouteType:  routeType,
 				ExtraEnv:   extraEnv,
+				Args:       args,
+				WorkDir:    workDir,
 				NoDB:       noDB,
+				DB:         dbProvider,
 				ConfigFile: configFile,
+
+				SocketActivated: socket,
+				Hardening:       hardening,
+				Schedule:        schedule,
+				PreStart:        preStartArgs,
+
+				TLS:     tls,
+				NoTLS:   noTLS,
+				TLSCert: tlsCert,
+				TLSKey:  tlsKey,
+
+				Proto:       proto,
+				Keepalive:   keepalive,
+				ReadTimeout: readTimeout,
+				MaxBodySize: maxBodySize,
+
+				Allow:     allow,
+				Deny:      deny,
+				RateLimit: rateLimit,
+				RateBurst: rateBurst,
 			}
 
 			result, err := orc.Deploy(cmd.Context(), req)
@@ -62,15 +116,29 @@ func deployCmd() *cobra.Command {
 
 			w := cmd.OutOrStdout()
 			fmt.Fprintf(w, "✓ %s %s deployed\n", result.Name, result.Version)
-			fmt.Fprintf(w, "  Port:     %d\n", result.Port)
-			if result.Route != "" {
-				fmt.Fprintf(w, "  Route:    %s → localhost:%d\n", result.Route, result.Port)
+			if schedule != "" {
+				fmt.Fprintf(w, "  Schedule: %s (gc-%s.timer)\n", schedule, result.Name)
+			} else {
+				fmt.Fprintf(w, "  Port:     %d\n", result.Port)
+			}
+			for _, r := range result.Routes {
+				scheme := "http"
+				if result.TLS && r.Type == "subdomain" {
+					scheme = "https"
+				}
+				fmt.Fprintf(w, "  Route:    %s → localhost:%d (%s)\n", r.Value, result.Port, scheme)
 			}
 			if result.DBName != "" {
-				fmt.Fprintf(w, "  Database: %s\n", result.DBName)
+				fmt.Fprintf(w, "  Database: %s (%s)\n", result.DBName, result.DBProvider)
+			}
+			if socket {
+				fmt.Fprintf(w, "  Socket:   gc-%s.socket (starts on first connection)\n", result.Name)
+			}
+			if result.TLSWarn != "" {
+				fmt.Fprintf(w, "  Warning:  TLS certificate failed (%s); serving HTTP until 'gophercaptain tls renew' succeeds\n", result.TLSWarn)
 			}
 			if result.NginxWarn != "" {
-				fmt.Fprintf(w, "  Warning:  nginx config failed (%s); service running without routing\n", result.NginxWarn)
+				fmt.Fprintf(w, "  Warning:  nginx config failed (%s); service running without routing until 'gophercaptain route set %s' succeeds\n", result.NginxWarn, result.Name)
 			}
 
 			return nil
@@ -79,11 +147,31 @@ func deployCmd() *cobra.Command {
 
 	cmd.Flags().StringVarP(&version, "version", "v", "latest", "Release tag (default: latest)")
 	cmd.Flags().IntVarP(&port, "port", "p", 0, "Override port (default: auto-assign)")
-	cmd.Flags().StringVarP(&route, "route", "r", "", "Route rule, e.g. \"api.example.com\" or \"/api\"")
-	cmd.Flags().StringVar(&routeType, "route-type", "", "\"subdomain\" or \"path\" (inferred from --route)")
+	cmd.Flags().StringArrayVarP(&routes, "route", "r", nil, "Route rule, e.g. \"api.example.com\" or \"example.com/api\" (repeatable)")
+	cmd.Flags().StringVar(&routeType, "route-type", "", "\"subdomain\" or \"path\" for every --route (inferred per route)")
 	cmd.Flags().StringVarP(&name, "name", "n", "", "Service name (default: repo name)")
 	cmd.Flags().StringSliceVarP(&envVars, "env", "e", nil, "Extra env vars: -e KEY=VALUE")
+	cmd.Flags().StringVar(&execArgs, "args", "", "Arguments appended to ExecStart, e.g. \"serve --http\"")
+	cmd.Flags().StringVar(&workDir, "workdir", "", "Working directory (default: the service's state directory)")
+	cmd.Flags().BoolVar(&socket, "socket-activated", false, "Listen via a systemd socket and start the service on first connection")
+	cmd.Flags().StringVar(&hardening, "hardening", "baseline", "systemd hardening profile: baseline, strict or paranoid")
+	cmd.Flags().StringVar(&schedule, "schedule", "", "Run as a scheduled job on a systemd calendar expression, e.g. \"*-*-* 02:00\"")
+	cmd.Flags().StringVar(&preStart, "pre-start", "", "Arguments to run with the new binary before each upgrade, e.g. \"migrate up\"")
+	cmd.Flags().BoolVar(&tls, "tls", false, "Serve the subdomain routes over HTTPS with a certificate from ACME (Let's Encrypt)")
+	cmd.Flags().BoolVar(&noTLS, "no-tls", false, "Serve plain HTTP even when [nginx] tls = \"acme\"")
+	cmd.Flags().StringVar(&tlsCert, "tls-cert", "", "Serve HTTPS with this certificate chain instead of ACME")
+	cmd.Flags().StringVar(&tlsKey, "tls-key", "", "Private key for --tls-cert")
+	cmd.Flags().StringVar(&proto, "proto", "http", "Route protocol: http, websocket or grpc (grpc needs TLS)")
+	cmd.Flags().IntVar(&keepalive, "keepalive", 0, "Idle connections to the service kept open per nginx worker (0 = none)")
+	cmd.Flags().StringVar(&readTimeout, "read-timeout", "", "nginx proxy_read_timeout for the routes, e.g. 3600s (default: nginx's 60s)")
+	cmd.Flags().StringVar(&maxBodySize, "max-body-size", "", "nginx client_max_body_size for the routes, e.g. 50m, 0 = unlimited (default: nginx's 1m)")
+	cmd.Flags().StringSliceVar(&allow, "allow", nil, "Only allow these IPs or CIDRs to reach the routes (repeatable)")
+	cmd.Flags().StringSliceVar(&deny, "deny", nil, "Refuse these IPs or CIDRs (repeatable)")
+	cmd.Flags().StringVar(&rateLimit, "rate-limit", "", "Requests per client address, e.g. 10r/s or 30r/m")
+	cmd.Flags().IntVar(&rateBurst, "rate-burst", 0, "Requests allowed above --rate-limit before refusing")
 	cmd.Flags().BoolVar(&noDB, "no-db", false, "Skip database creation")
+	cmd.Flags().StringVar(&dbProvider, "db", "mariadb", "Database provider: mariadb or postgres")
+	cmd.MarkFlagsMutuallyExclusive("db", "no-db")
 	cmd.Flags().BoolVar(&configFile, "config-file", false, "Write config file instead of env vars")
 
 	return cmd
@@ -3,9 +3,12 @@ package commands
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/ecairns22/GopherCaptain/internal/config"
 	"github.com/ecairns22/GopherCaptain/internal/db"
+	"github.com/ecairns22/GopherCaptain/internal/runner"
 	"github.com/ecairns22/GopherCaptain/internal/state"
 	"github.com/spf13/cobra"
 )
@@ -52,29 +55,66 @@ func runInit(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Fprintf(cmd.OutOrStdout(), "  config loaded from %s\n", configPath)
 
-	// 4. Test MariaDB connection
-	dbMgr, err := db.NewFromConfig(cfg)
+	// 4. Test the database connections. PostgreSQL is only checked once
+	// configured for --db postgres.
+	dbMgr, err := db.NewFromConfig(cfg, &runner.OSRunner{})
 	if err != nil {
-		return fmt.Errorf("connecting to MariaDB: %w", err)
+		return fmt.Errorf("connecting to databases: %w", err)
 	}
 	defer dbMgr.Close()
 
-	if err := dbMgr.Ping(ctx); err != nil {
-		fmt.Fprintf(cmd.OutOrStdout(), "  MariaDB: FAILED (%v)\n", err)
-		return err
+	servers := []struct{ kind, label string }{{db.MariaDBProvider, "MariaDB"}}
+	if cfg.Postgres.AdminPasswordFile != "" {
+		servers = append(servers, struct{ kind, label string }{db.PostgresProvider, "PostgreSQL"})
+	}
+	for _, s := range servers {
+		provider, err := dbMgr.Provider(s.kind)
+		if err != nil {
+			return err
+		}
+		if err := provider.Ping(ctx); err != nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "  %s: FAILED (%v)\n", s.label, err)
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "  %s: OK\n", s.label)
 	}
-	fmt.Fprintf(cmd.OutOrStdout(), "  MariaDB: OK\n")
 
-	// 5. Verify nginx directories
-	for _, d := range []string{cfg.Nginx.SitesDir, cfg.Nginx.EnabledDir} {
-		info, err := os.Stat(d)
+	// 5. Verify the reverse proxy's config locations
+	switch cfg.Proxy.Kind {
+	case "caddy":
+		data, err := os.ReadFile(cfg.Proxy.Caddyfile)
 		if err != nil {
-			return fmt.Errorf("nginx directory %s: %w", d, err)
+			return fmt.Errorf("caddy config: %w", err)
+		}
+		if err := os.MkdirAll(cfg.Proxy.CaddyDir, 0755); err != nil {
+			return fmt.Errorf("creating %s: %w", cfg.Proxy.CaddyDir, err)
+		}
+		imp := "import " + filepath.Join(cfg.Proxy.CaddyDir, "*.caddy")
+		if !strings.Contains(string(data), imp) {
+			fmt.Fprintf(cmd.OutOrStdout(), "  caddy: add %q to %s\n", imp, cfg.Proxy.Caddyfile)
+		} else {
+			fmt.Fprintf(cmd.OutOrStdout(), "  caddy %s: OK\n", cfg.Proxy.Caddyfile)
+		}
+	case "haproxy":
+		if _, err := os.Stat(cfg.Proxy.HAProxyConfig); err != nil {
+			return fmt.Errorf("haproxy config: %w", err)
+		}
+		if err := os.MkdirAll(cfg.Proxy.HAProxyDir, 0755); err != nil {
+			return fmt.Errorf("creating %s: %w", cfg.Proxy.HAProxyDir, err)
 		}
-		if !info.IsDir() {
-			return fmt.Errorf("nginx path %s is not a directory", d)
+		fmt.Fprintf(cmd.OutOrStdout(), "  haproxy: load %s with -f next to %s\n",
+			filepath.Join(cfg.Proxy.HAProxyDir, "gophercaptain.cfg"), cfg.Proxy.HAProxyConfig)
+	default:
+		for _, d := range []string{cfg.Nginx.SitesDir, cfg.Nginx.EnabledDir} {
+			info, err := os.Stat(d)
+			if err != nil {
+				return fmt.Errorf("nginx directory %s: %w", d, err)
+			}
+			if !info.IsDir() {
+				return fmt.Errorf("nginx path %s is not a directory", d)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "  nginx dir %s: OK\n", d)
 		}
-		fmt.Fprintf(cmd.OutOrStdout(), "  nginx dir %s: OK\n", d)
 	}
 
 	// 6. Initialize state database
@@ -7,10 +7,12 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/ecairns22/GopherCaptain/internal/config"
+	"github.com/ecairns22/GopherCaptain/internal/templates"
 	"github.com/spf13/cobra"
 )
 
-var credentialKeys = regexp.MustCompile(`(?i)(PASSWORD|SECRET|TOKEN|KEY)`)
+var credentialKeys = regexp.MustCompile(`(?i)(PASSWORD|SECRET|TOKEN|KEY|DATABASE_URL)`)
 
 func inspectCmd() *cobra.Command {
 	return &cobra.Command{
@@ -30,29 +32,101 @@ func inspectCmd() *cobra.Command {
 			if _, err := store.GetService(cmd.Context(), name); err != nil {
 				return fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", name)
 			}
+			overrides, err := store.Overrides(cmd.Context(), name)
+			if err != nil {
+				return err
+			}
 
 			w := cmd.OutOrStdout()
 
 			// Systemd unit
 			unitPath := filepath.Join(unitDir, fmt.Sprintf("gc-%s.service", name))
-			fmt.Fprintf(w, "=== Systemd Unit (%s) ===\n", unitPath)
 			if data, err := os.ReadFile(unitPath); err == nil {
+				fmt.Fprintf(w, "=== Systemd Unit (%s) [template: %s] ===\n", unitPath, templates.Source(string(data)))
 				fmt.Fprintln(w, string(data))
 			} else {
+				fmt.Fprintf(w, "=== Systemd Unit (%s) ===\n", unitPath)
 				fmt.Fprintf(w, "(not found: %v)\n\n", err)
 			}
 
-			// Nginx config
-			// Try common locations
-			for _, dir := range []string{"/etc/nginx/sites-available", "/etc/nginx/sites-enabled"} {
+			// Socket unit, for socket-activated services
+			socketPath := filepath.Join(unitDir, fmt.Sprintf("gc-%s.socket", name))
+			if data, err := os.ReadFile(socketPath); err == nil {
+				fmt.Fprintf(w, "=== Systemd Socket (%s) ===\n", socketPath)
+				fmt.Fprintln(w, string(data))
+			}
+
+			// Drop-in overrides, with Environment= credentials redacted
+			for _, ov := range overrides {
+				path := filepath.Join(unitDir, fmt.Sprintf("gc-%s.service.d", name), ov.Name+".conf")
+				fmt.Fprintf(w, "=== Systemd Override (%s) ===\n", path)
+				for _, line := range strings.Split(strings.TrimRight(ov.Content, "\n"), "\n") {
+					fmt.Fprintln(w, redactEnvironment(line))
+				}
+				fmt.Fprintln(w)
+			}
+
+			// Template unit, for scaled services
+			templatePath := filepath.Join(unitDir, fmt.Sprintf("gc-%s@.service", name))
+			if data, err := os.ReadFile(templatePath); err == nil {
+				fmt.Fprintf(w, "=== Systemd Template Unit (%s) [template: %s] ===\n", templatePath, templates.Source(string(data)))
+				fmt.Fprintln(w, string(data))
+			}
+
+			// Timer unit, for scheduled jobs
+			timerPath := filepath.Join(unitDir, fmt.Sprintf("gc-%s.timer", name))
+			if data, err := os.ReadFile(timerPath); err == nil {
+				fmt.Fprintf(w, "=== Systemd Timer (%s) ===\n", timerPath)
+				fmt.Fprintln(w, string(data))
+			}
+
+			// Nginx config, from the configured directories; the defaults
+			// stand in when the config cannot be loaded
+			cfg, cfgErr := config.Load()
+			sitesDir, enabledDir, snippetsDir := "/etc/nginx/sites-available", "/etc/nginx/sites-enabled", "/etc/nginx/gophercaptain"
+			if cfgErr == nil {
+				sitesDir, enabledDir, snippetsDir = cfg.Nginx.SitesDir, cfg.Nginx.EnabledDir, cfg.Nginx.SnippetsDir
+			}
+			for _, dir := range []string{sitesDir, enabledDir} {
 				confPath := filepath.Join(dir, fmt.Sprintf("gc-%s.conf", name))
 				if data, err := os.ReadFile(confPath); err == nil {
-					fmt.Fprintf(w, "=== Nginx Config (%s) ===\n", confPath)
+					fmt.Fprintf(w, "=== Nginx Config (%s) [template: %s] ===\n", confPath, templates.Source(string(data)))
 					fmt.Fprintln(w, string(data))
 					break
 				}
 			}
 
+			// Location snippets of path routes, included by each host's server
+			snippets, _ := filepath.Glob(filepath.Join(snippetsDir, "*", fmt.Sprintf("gc-%s.conf", name)))
+			for _, path := range snippets {
+				if data, err := os.ReadFile(path); err == nil {
+					fmt.Fprintf(w, "=== Nginx Location (%s) [template: %s] ===\n", path, templates.Source(string(data)))
+					fmt.Fprintln(w, string(data))
+				}
+			}
+
+			// Caddy or HAProxy config, when one of them serves the routes
+			if cfgErr == nil {
+				var files []string
+				var label string
+				switch cfg.Proxy.Kind {
+				case "caddy":
+					label = "Caddy Site"
+					files = append(files, filepath.Join(cfg.Proxy.CaddyDir, fmt.Sprintf("gc-%s.caddy", name)))
+					handles, _ := filepath.Glob(filepath.Join(cfg.Proxy.CaddyDir, "hosts", "*", fmt.Sprintf("gc-%s.caddy", name)))
+					files = append(files, handles...)
+				case "haproxy":
+					label = "HAProxy Backend"
+					files = append(files, filepath.Join(cfg.Proxy.HAProxyDir, "services", fmt.Sprintf("gc-%s.cfg", name)))
+				}
+				for _, path := range files {
+					if data, err := os.ReadFile(path); err == nil {
+						fmt.Fprintf(w, "=== %s (%s) ===\n", label, path)
+						fmt.Fprintln(w, string(data))
+					}
+				}
+			}
+
 			// Env file (with redaction)
 			envPath := filepath.Join("/etc/gophercaptain", name, "env")
 			fmt.Fprintf(w, "=== Env File (%s) ===\n", envPath)
@@ -77,3 +151,16 @@ func inspectCmd() *cobra.Command {
 		},
 	}
 }
+
+// redactEnvironment masks credential values in an Environment= line.
+func redactEnvironment(line string) string {
+	value, ok := strings.CutPrefix(line, "Environment=")
+	if !ok {
+		return line
+	}
+	key, _, found := strings.Cut(strings.Trim(value, `"`), "=")
+	if found && credentialKeys.MatchString(key) {
+		return fmt.Sprintf("Environment=%s=****", key)
+	}
+	return line
+}
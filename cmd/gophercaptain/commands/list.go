@@ -2,15 +2,18 @@ package commands
 
 import (
 	"fmt"
+	"strings"
 	"text/tabwriter"
 
+	"github.com/ecairns22/GopherCaptain/internal/orchestrator"
 	"github.com/ecairns22/GopherCaptain/internal/runner"
-	"github.com/ecairns22/GopherCaptain/internal/systemd"
 	"github.com/spf13/cobra"
 )
 
 func listCmd() *cobra.Command {
-	return &cobra.Command{
+	var check bool
+
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "Show all deployed services",
 		Args:  cobra.NoArgs,
@@ -31,29 +34,87 @@ func listCmd() *cobra.Command {
 				return nil
 			}
 
+			var orc *orchestrator.Orchestrator
+			if check {
+				var cleanup func()
+				orc, cleanup, err = buildOrchestrator()
+				if err != nil {
+					return err
+				}
+				defer cleanup()
+			}
+
 			// Query live status via systemd
 			r := &runner.OSRunner{}
-			sys := systemd.New(r, unitDir)
+			sys, closeSys := newSystemd(cmd.Context(), r)
+			defer closeSys()
 
 			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tVERSION\tPORT\tROUTE\tSTATUS")
+			if check {
+				fmt.Fprintln(w, "NAME\tVERSION\tPORT\tROUTE\tSTATUS\tREACH")
+			} else {
+				fmt.Fprintln(w, "NAME\tVERSION\tPORT\tROUTE\tSTATUS")
+			}
 
 			for _, svc := range services {
-				route := svc.RouteValue
-				if route == "" {
-					route = "—"
+				route := "—"
+				if len(svc.Routes) > 0 {
+					values := make([]string, len(svc.Routes))
+					for i, r := range svc.Routes {
+						values[i] = r.Value
+					}
+					route = strings.Join(values, ", ")
 				}
 
-				status := "stopped"
-				if active, _ := sys.IsActive(cmd.Context(), svc.Name); active {
-					status = "running"
+				port := "—"
+				if len(svc.Instances) > 0 {
+					port = fmt.Sprintf("%d (×%d)", svc.Port, len(svc.Instances))
+				} else if svc.Port != 0 {
+					port = fmt.Sprintf("%d", svc.Port)
 				}
 
-				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", svc.Name, svc.Version, svc.Port, route, status)
+				status := serviceStatus(cmd.Context(), sys, svc)
+
+				if !check {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", svc.Name, svc.Version, port, route, status)
+					continue
+				}
+				reach := "—"
+				if len(svc.Routes) > 0 {
+					checks, _ := orc.CheckRoutes(cmd.Context(), svc.Name)
+					reach = summarizeRouteChecks(checks, svc.Maintenance)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", svc.Name, svc.Version, port, route, status, reach)
 			}
 
 			w.Flush()
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&check, "check", false, "Request every route through the proxy and show whether it reaches the service")
+	return cmd
+}
+
+// summarizeRouteChecks condenses a service's route checks to "ok" with the
+// slowest latency, or the first route that failed. Routes refused by the
+// service's access control are left out, or reported as "protected" when
+// that is all of them.
+func summarizeRouteChecks(checks []orchestrator.RouteCheck, maintenance bool) string {
+	var slowest *orchestrator.RouteCheck
+	for i, c := range checks {
+		switch {
+		case c.Protected:
+			continue
+		case !c.Reached:
+			return c.Route + ": " + describeRouteCheck(c, maintenance)
+		}
+		if slowest == nil || c.Latency >= slowest.Latency {
+			slowest = &checks[i]
+		}
+	}
+	if slowest == nil {
+		return "protected"
+	}
+	return "ok (" + formatLatency(slowest.Latency) + ")"
 }
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+	"github.com/ecairns22/GopherCaptain/internal/systemd"
+	"github.com/spf13/cobra"
+)
+
+func logsCmd() *cobra.Command {
+	var opts systemd.LogOptions
+
+	cmd := &cobra.Command{
+		Use:   "logs <service> [service...]",
+		Short: "Show journal output for one or more services",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := buildStateOnly()
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			for _, name := range args {
+				if _, err := store.GetService(cmd.Context(), name); err != nil {
+					return fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", name)
+				}
+			}
+			opts.Services = args
+
+			// Stop cleanly on Ctrl-C when following
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			r := &runner.OSRunner{}
+			sys := systemd.New(r, unitDir)
+			return sys.Logs(ctx, opts, cmd.OutOrStdout(), cmd.ErrOrStderr())
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.Follow, "follow", "f", false, "Follow new entries")
+	cmd.Flags().IntVarP(&opts.Lines, "lines", "n", 0, "Number of most recent entries to show (default: all, or 10 with -f)")
+	cmd.Flags().StringVar(&opts.Since, "since", "", "Show entries since, e.g. \"1h ago\" or \"2024-05-01 10:00\"")
+	cmd.Flags().StringVar(&opts.Until, "until", "", "Show entries until, e.g. \"10 min ago\"")
+	cmd.Flags().StringVarP(&opts.Priority, "priority", "p", "", "Minimum priority or range, e.g. \"err\" or \"warning..err\"")
+	cmd.Flags().StringVarP(&opts.Grep, "grep", "g", "", "Only show entries whose message matches the pattern")
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "journalctl output mode, e.g. \"json\" or \"short-iso\"")
+
+	return cmd
+}
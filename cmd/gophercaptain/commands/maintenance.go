@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func maintenanceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "maintenance",
+		Short: "Serve a maintenance page on a service's routes",
+		Long: `Switch a service's routes to answer 503 with Retry-After, serving the
+[nginx] maintenance_page if one is configured, while the service keeps
+running. Upgrades do this on their own while the service is stopped.`,
+	}
+	cmd.AddCommand(maintenanceSetCmd(true), maintenanceSetCmd(false))
+	return cmd
+}
+
+func maintenanceSetCmd(on bool) *cobra.Command {
+	use, short, done := "off <service>", "Send traffic to the service again", "back in service"
+	if on {
+		use, short, done = "on <service>", "Serve the maintenance page", "in maintenance"
+	}
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			if err := orc.SetMaintenance(cmd.Context(), name, on); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "✓ %s %s\n", name, done)
+			return nil
+		},
+	}
+}
@@ -11,8 +11,9 @@ import (
 
 func removeCmd() *cobra.Command {
 	var (
-		dropDB bool
-		yes    bool
+		dropDB    bool
+		purgeData bool
+		yes       bool
 	)
 
 	cmd := &cobra.Command{
@@ -35,7 +36,7 @@ func removeCmd() *cobra.Command {
 					return err
 				}
 				if svc.DBName != "" {
-					fmt.Fprintf(cmd.OutOrStdout(), "This will drop database %q and user %q. Continue? [y/N] ", svc.DBName, svc.DBUser)
+					fmt.Fprintf(cmd.OutOrStdout(), "This will drop %s database %q and user %q. Continue? [y/N] ", svc.DBProvider, svc.DBName, svc.DBUser)
 					reader := bufio.NewReader(cmd.InOrStdin())
 					answer, _ := reader.ReadString('\n')
 					answer = strings.TrimSpace(strings.ToLower(answer))
@@ -52,9 +53,10 @@ func removeCmd() *cobra.Command {
 			}
 
 			req := orchestrator.RemoveRequest{
-				Name:   name,
-				DropDB: dropDB,
-				Yes:    yes,
+				Name:      name,
+				DropDB:    dropDB,
+				PurgeData: purgeData,
+				Yes:       yes,
 			}
 
 			if err := orc.Remove(cmd.Context(), req, step); err != nil {
@@ -66,7 +68,8 @@ func removeCmd() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().BoolVar(&dropDB, "drop-db", false, "Also drop the MariaDB database and user")
+	cmd.Flags().BoolVar(&dropDB, "drop-db", false, "Also drop the service's database and user")
+	cmd.Flags().BoolVar(&purgeData, "purge-data", false, "Also delete the service's state, cache and logs directories and its database backups")
 	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip confirmation")
 
 	return cmd
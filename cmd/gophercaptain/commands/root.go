@@ -9,17 +9,25 @@ func Root() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "gophercaptain",
 		Short: "Deploy Go services from GitHub releases",
-		Long:  "GopherCaptain deploys Go services from GitHub releases with systemd, nginx, and MariaDB.",
+		Long:  "GopherCaptain deploys Go services from GitHub releases with systemd, nginx, and MariaDB or PostgreSQL.",
 	}
 
 	cmd.AddCommand(initCmd())
 	cmd.AddCommand(deployCmd())
 	cmd.AddCommand(upgradeCmd())
 	cmd.AddCommand(rollbackCmd())
+	cmd.AddCommand(scaleCmd())
 	cmd.AddCommand(removeCmd())
 	cmd.AddCommand(listCmd())
 	cmd.AddCommand(statusCmd())
 	cmd.AddCommand(inspectCmd())
+	cmd.AddCommand(logsCmd())
+	cmd.AddCommand(trafficCmd())
+	cmd.AddCommand(dbCmd())
+	cmd.AddCommand(unitCmd())
+	cmd.AddCommand(tlsCmd())
+	cmd.AddCommand(routeCmd())
+	cmd.AddCommand(maintenanceCmd())
 	cmd.AddCommand(versionCmd())
 
 	return cmd
@@ -0,0 +1,250 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ecairns22/GopherCaptain/internal/orchestrator"
+	"github.com/spf13/cobra"
+)
+
+func routeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "route",
+		Short: "Manage the nginx routes of a service after deploy",
+	}
+	cmd.AddCommand(routeSetCmd(), routeRemoveCmd(), routeShowCmd(), routeAuthCmd())
+	return cmd
+}
+
+func routeSetCmd() *cobra.Command {
+	var (
+		routeType       string
+		tls, noTLS      bool
+		tlsCert, tlsKey string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "set <service> <route>...",
+		Short: "Replace the routes of a service",
+		Long: `Replace all routes of a service with the given ones, e.g. to route a service
+deployed without --route or whose nginx step failed. The new config is
+tested with nginx -t before anything changes; if it fails, the old config
+keeps serving. Subdomain routes keep the service's TLS mode unless a TLS
+flag is given.
+
+Example:
+  gophercaptain route set api api.example.com api.example.org
+  gophercaptain route set api example.com/api --route-type path`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			result, err := orc.SetRoutes(cmd.Context(), orchestrator.RouteRequest{
+				Name:      args[0],
+				Routes:    args[1:],
+				RouteType: routeType,
+				TLS:       tls,
+				NoTLS:     noTLS,
+				TLSCert:   tlsCert,
+				TLSKey:    tlsKey,
+			})
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			fmt.Fprintf(w, "✓ %s routes updated\n", result.Name)
+			printRoutes(w, result)
+			if result.TLSWarn != "" {
+				fmt.Fprintf(w, "  Warning:  TLS certificate failed (%s); serving HTTP until 'gophercaptain tls renew' succeeds\n", result.TLSWarn)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&routeType, "route-type", "", "\"subdomain\" or \"path\" for every route (inferred per route)")
+	cmd.Flags().BoolVar(&tls, "tls", false, "Serve the subdomain routes over HTTPS with a certificate from ACME (Let's Encrypt)")
+	cmd.Flags().BoolVar(&noTLS, "no-tls", false, "Serve plain HTTP")
+	cmd.Flags().StringVar(&tlsCert, "tls-cert", "", "Serve HTTPS with this certificate chain instead of ACME")
+	cmd.Flags().StringVar(&tlsKey, "tls-key", "", "Private key for --tls-cert")
+	return cmd
+}
+
+func routeRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <service> [route...]",
+		Short: "Remove some or all routes of a service",
+		Long: `Remove the given routes of a service, or all of them if none are given, in
+which case its nginx config is deleted. The service keeps running.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			result, err := orc.RemoveRoutes(cmd.Context(), args[0], args[1:])
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			if len(result.Routes) == 0 {
+				fmt.Fprintf(w, "✓ %s is no longer routed\n", result.Name)
+				return nil
+			}
+			fmt.Fprintf(w, "✓ %s routes updated\n", result.Name)
+			printRoutes(w, result)
+			return nil
+		},
+	}
+}
+
+func routeShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <service>",
+		Short: "Show the routes of a service",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			result, err := orc.Routes(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			if len(result.Routes) == 0 {
+				fmt.Fprintf(w, "No routes for %s.\n", result.Name)
+				return nil
+			}
+			printRoutes(w, result)
+			switch {
+			case result.TLS && !result.NotAfter.IsZero():
+				fmt.Fprintf(w, "  TLS:      %s, valid until %s\n", result.TLSMode, result.NotAfter.Local().Format("2006-01-02"))
+			case result.TLSMode != "":
+				fmt.Fprintf(w, "  TLS:      %s, no certificate yet\n", result.TLSMode)
+			}
+			return nil
+		},
+	}
+}
+
+// printRoutes prints each route with the scheme it is served on.
+func printRoutes(w io.Writer, result *orchestrator.RouteResult) {
+	for _, r := range result.Routes {
+		scheme := "http"
+		if result.TLS && r.Type == "subdomain" {
+			scheme = "https"
+		}
+		fmt.Fprintf(w, "  Route:    %s → localhost:%d (%s)\n", r.Value, result.Port, scheme)
+	}
+}
+
+func routeAuthCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Manage basic auth users of a service's routes",
+		Long: `Manage the htpasswd users that guard every route of a service. Basic auth
+is on while a service has at least one user; ACME challenges stay public.`,
+	}
+	cmd.AddCommand(routeAuthAddCmd(), routeAuthRemoveCmd(), routeAuthListCmd())
+	return cmd
+}
+
+func routeAuthAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add <service> <user>",
+		Short: "Add a user, or change their password",
+		Long: `Add a basic auth user to a service's routes, or change the password of an
+existing user. The password is read from the first line of stdin.
+
+Example:
+  gophercaptain route auth add admin alice
+  echo "$PASSWORD" | gophercaptain route auth add admin deploy-bot`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, user := args[0], args[1]
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "Password for %s: ", user)
+			reader := bufio.NewReader(cmd.InOrStdin())
+			password, _ := reader.ReadString('\n')
+			password = strings.TrimRight(password, "\r\n")
+
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			if err := orc.AddAuthUser(cmd.Context(), name, user, password); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "✓ %s auth user %q set\n", name, user)
+			return nil
+		},
+	}
+}
+
+func routeAuthRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <service> <user>",
+		Short: "Remove a user",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, user := args[0], args[1]
+
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			if err := orc.RemoveAuthUser(cmd.Context(), name, user); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "✓ %s auth user %q removed\n", name, user)
+			return nil
+		},
+	}
+}
+
+func routeAuthListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list <service>",
+		Short: "List users",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			users, err := orc.AuthUsers(cmd.Context(), name)
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			if len(users) == 0 {
+				fmt.Fprintf(w, "No auth users for %s.\n", name)
+				return nil
+			}
+			for _, u := range users {
+				fmt.Fprintln(w, u)
+			}
+			return nil
+		},
+	}
+}
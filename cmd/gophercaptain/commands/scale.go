@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ecairns22/GopherCaptain/internal/orchestrator"
+	"github.com/spf13/cobra"
+)
+
+func scaleCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "scale <service> <instances>",
+		Short: "Run a number of instances behind an nginx upstream",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid instance count %q", args[1])
+			}
+
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			result, err := orc.Scale(cmd.Context(), orchestrator.ScaleRequest{Name: name, Instances: n})
+			if err != nil {
+				return err
+			}
+
+			w := cmd.OutOrStdout()
+			fmt.Fprintf(w, "✓ %s running %d instance(s)\n", result.Name, len(result.Ports))
+			fmt.Fprintf(w, "  Ports:    %s\n", joinPorts(result.Ports))
+			if result.NginxWarn != "" {
+				fmt.Fprintf(w, "  Warning:  nginx config failed (%s); routing not updated\n", result.NginxWarn)
+			}
+			return nil
+		},
+	}
+}
@@ -2,9 +2,13 @@ package commands
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
 
+	"github.com/ecairns22/GopherCaptain/internal/certs"
+	"github.com/ecairns22/GopherCaptain/internal/orchestrator"
 	"github.com/ecairns22/GopherCaptain/internal/runner"
-	"github.com/ecairns22/GopherCaptain/internal/systemd"
+	"github.com/ecairns22/GopherCaptain/internal/state"
 	"github.com/spf13/cobra"
 )
 
@@ -29,11 +33,9 @@ func statusCmd() *cobra.Command {
 
 			// Live systemd status
 			r := &runner.OSRunner{}
-			sys := systemd.New(r, unitDir)
-			status := "stopped"
-			if active, _ := sys.IsActive(cmd.Context(), svc.Name); active {
-				status = "running"
-			}
+			sys, closeSys := newSystemd(cmd.Context(), r)
+			defer closeSys()
+			status := serviceStatus(cmd.Context(), sys, svc)
 
 			w := cmd.OutOrStdout()
 			fmt.Fprintf(w, "Service:     %s\n", svc.Name)
@@ -42,14 +44,73 @@ func statusCmd() *cobra.Command {
 			if svc.PrevVersion != "" {
 				fmt.Fprintf(w, "Previous:    %s\n", svc.PrevVersion)
 			}
-			fmt.Fprintf(w, "Port:        %d\n", svc.Port)
-			if svc.RouteValue != "" {
-				fmt.Fprintf(w, "Route:       %s (%s)\n", svc.RouteValue, svc.RouteType)
+			if svc.Port != 0 {
+				fmt.Fprintf(w, "Port:        %d\n", svc.Port)
+			}
+			if len(svc.Instances) > 0 {
+				fmt.Fprintf(w, "Instances:   %d (ports %s)\n", len(svc.Instances), joinPorts(svc.Instances))
+			}
+			for _, r := range svc.Routes {
+				fmt.Fprintf(w, "Route:       %s (%s)\n", r.Value, r.Type)
+			}
+			if svc.TLS != "" {
+				fmt.Fprintf(w, "TLS:         %s\n", tlsStatus(svc.TLS, svc.TLSCert))
+			}
+			if proxy := proxyOptions(svc); proxy != "" {
+				fmt.Fprintf(w, "Proxy:       %s\n", proxy)
+			}
+			users, _ := store.AuthUsers(cmd.Context(), svc.Name)
+			if access := accessPolicy(svc, len(users)); access != "" {
+				fmt.Fprintf(w, "Access:      %s\n", access)
+			}
+			if svc.Maintenance {
+				fmt.Fprintf(w, "Maintenance: on\n")
 			}
 			if svc.DBName != "" {
-				fmt.Fprintf(w, "Database:    %s\n", svc.DBName)
+				fmt.Fprintf(w, "Database:    %s (%s)\n", svc.DBName, svc.DBProvider)
+			}
+			if svc.BackupSchedule != "" {
+				fmt.Fprintf(w, "Backups:     %s\n", svc.BackupSchedule)
 			}
 			fmt.Fprintf(w, "Status:      %s\n", status)
+			if len(svc.Routes) > 0 {
+				// Route checks need the proxy config; without it they are left out
+				if orc, cleanup, err := buildOrchestrator(); err == nil {
+					checks, _ := orc.CheckRoutes(cmd.Context(), svc.Name)
+					cleanup()
+					for _, c := range checks {
+						fmt.Fprintf(w, "Reach:       %s → %s\n", c.Route, describeRouteCheck(c, svc.Maintenance))
+					}
+				}
+				if line := trafficStatus(svc.Name); line != "" {
+					fmt.Fprintf(w, "Traffic:     %s\n", line)
+				}
+			}
+			if svc.SocketActivated {
+				socket := "inactive"
+				if listening, _ := sys.IsSocketActive(cmd.Context(), svc.Name); listening {
+					socket = fmt.Sprintf("listening on 127.0.0.1:%d", svc.Port)
+				}
+				fmt.Fprintf(w, "Socket:      gc-%s.socket (%s)\n", svc.Name, socket)
+			}
+			if svc.Schedule != "" {
+				fmt.Fprintf(w, "Schedule:    %s\n", svc.Schedule)
+				if job, err := sys.JobStatus(cmd.Context(), svc.Name); err == nil {
+					fmt.Fprintf(w, "Last run:    %s\n", orNever(job.LastRun))
+					fmt.Fprintf(w, "Next run:    %s\n", orNever(job.NextRun))
+					if job.LastRun != "" {
+						fmt.Fprintf(w, "Last result: %s (exit code %d)\n", job.Result, job.ExitStatus)
+					}
+				}
+			}
+			if len(svc.PreStart) > 0 {
+				fmt.Fprintf(w, "Pre-start:   %s\n", strings.Join(svc.PreStart, " "))
+			}
+			hardening := svc.Hardening
+			if score, err := sys.SecurityScore(cmd.Context(), svc.Name); err == nil {
+				hardening = fmt.Sprintf("%s (exposure %.1f %s)", hardening, score.Exposure, score.Rating)
+			}
+			fmt.Fprintf(w, "Hardening:   %s\n", hardening)
 			fmt.Fprintf(w, "Deployed:    %s\n", svc.DeployedAt.Format("2006-01-02 15:04:05"))
 			fmt.Fprintf(w, "Updated:     %s\n", svc.UpdatedAt.Format("2006-01-02 15:04:05"))
 
@@ -57,3 +118,82 @@ func statusCmd() *cobra.Command {
 		},
 	}
 }
+
+// tlsStatus describes a route's certificate and when it expires.
+func tlsStatus(mode, certPath string) string {
+	notAfter, err := certs.NotAfter(certPath)
+	if err != nil {
+		if mode == "acme" {
+			return "acme (not issued yet; serving HTTP)"
+		}
+		return fmt.Sprintf("%s (%v)", mode, err)
+	}
+	return fmt.Sprintf("%s (expires %s)", mode, notAfter.Local().Format("2006-01-02"))
+}
+
+// describeRouteCheck reports what a request to a route got back.
+func describeRouteCheck(c orchestrator.RouteCheck, maintenance bool) string {
+	switch {
+	case c.Err != nil:
+		return "✗ " + c.Err.Error()
+	case c.Reached && c.Upstream != "":
+		return fmt.Sprintf("%d in %s via %s", c.Status, formatLatency(c.Latency), c.Upstream)
+	case c.Reached:
+		return fmt.Sprintf("%d in %s", c.Status, formatLatency(c.Latency))
+	case c.Protected:
+		return fmt.Sprintf("%d, refused by its access control (not checked further)", c.Status)
+	case maintenance && c.Status == http.StatusServiceUnavailable:
+		return "503 (maintenance)"
+	case c.Upstream != "":
+		return fmt.Sprintf("✗ %d from %s, not this service", c.Status, c.Upstream)
+	}
+	return fmt.Sprintf("✗ %d, not forwarded to the service", c.Status)
+}
+
+// orNever renders an empty job timestamp.
+func orNever(ts string) string {
+	if ts == "" {
+		return "never"
+	}
+	return ts
+}
+
+// proxyOptions summarizes a service's non-default route protocol and tuning.
+func proxyOptions(svc *state.Service) string {
+	var opts []string
+	if svc.Proto != "" && svc.Proto != "http" {
+		opts = append(opts, svc.Proto)
+	}
+	if svc.Keepalive > 0 {
+		opts = append(opts, fmt.Sprintf("keepalive %d", svc.Keepalive))
+	}
+	if svc.ReadTimeout != "" {
+		opts = append(opts, "read timeout "+svc.ReadTimeout)
+	}
+	if svc.MaxBodySize != "" {
+		opts = append(opts, "max body "+svc.MaxBodySize)
+	}
+	return strings.Join(opts, ", ")
+}
+
+// accessPolicy summarizes the access control on a service's routes.
+func accessPolicy(svc *state.Service, users int) string {
+	var parts []string
+	if len(svc.Allow) > 0 {
+		parts = append(parts, "allow "+strings.Join(svc.Allow, " "))
+	}
+	if len(svc.Deny) > 0 {
+		parts = append(parts, "deny "+strings.Join(svc.Deny, " "))
+	}
+	if users > 0 {
+		parts = append(parts, fmt.Sprintf("basic auth (%d users)", users))
+	}
+	if svc.RateLimit != "" {
+		limit := "rate " + svc.RateLimit
+		if svc.RateBurst > 0 {
+			limit += fmt.Sprintf(" burst %d", svc.RateBurst)
+		}
+		parts = append(parts, limit)
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func tlsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tls",
+		Short: "Manage TLS certificates for subdomain routes",
+	}
+	cmd.AddCommand(tlsRenewCmd())
+	return cmd
+}
+
+func tlsRenewCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "renew",
+		Short: "Renew ACME certificates that expire within 30 days",
+		Long: `Renew the ACME certificates of all routes deployed with --tls that are
+missing or expire within 30 days, and reload nginx. Runs daily from
+gophercaptain-renew.timer, which deploy installs with the first ACME route.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			results, err := orc.RenewCertificates(cmd.Context(), force)
+			if err != nil {
+				return err
+			}
+
+			w := cmd.OutOrStdout()
+			if len(results) == 0 {
+				fmt.Fprintln(w, "No ACME routes.")
+				return nil
+			}
+			failed := 0
+			for _, r := range results {
+				switch {
+				case r.Err != nil:
+					failed++
+					fmt.Fprintf(w, "✗ %s (%s): %v\n", strings.Join(r.Domains, ", "), r.Name, r.Err)
+				case r.Renewed:
+					fmt.Fprintf(w, "✓ %s (%s) renewed, expires %s\n", strings.Join(r.Domains, ", "), r.Name, r.NotAfter.Local().Format("2006-01-02"))
+				default:
+					fmt.Fprintf(w, "  %s (%s) valid until %s\n", strings.Join(r.Domains, ", "), r.Name, r.NotAfter.Local().Format("2006-01-02"))
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d certificate(s) failed to renew", failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "Renew every ACME certificate regardless of expiry")
+
+	return cmd
+}
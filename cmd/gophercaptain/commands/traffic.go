@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ecairns22/GopherCaptain/internal/config"
+	"github.com/ecairns22/GopherCaptain/internal/nginx"
+	"github.com/ecairns22/GopherCaptain/internal/traffic"
+	"github.com/spf13/cobra"
+)
+
+func trafficCmd() *cobra.Command {
+	var window time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "traffic <service>",
+		Short: "Request rate, status codes and latency of a service's routes",
+		Long: `Summarize a service's nginx access log over a window: requests and
+their rate, the breakdown by status code, and p50/p95 upstream latency.
+Each routed service logs to [nginx] log_dir/gc-<name>.access.log in JSON.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("loading config: %w", err)
+			}
+			if cfg.Proxy.Kind != "nginx" {
+				return fmt.Errorf("traffic reads nginx access logs; [proxy] kind is %q", cfg.Proxy.Kind)
+			}
+
+			store, err := buildStateOnly()
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			svc, err := store.GetService(cmd.Context(), name)
+			if err != nil {
+				return fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", name)
+			}
+			if len(svc.Routes) == 0 {
+				return fmt.Errorf("%s has no routes, so nginx logs no requests for it", name)
+			}
+
+			summary, err := traffic.Summarize(nginx.AccessLogPath(cfg.Nginx.LogDir, name), window, time.Now())
+			if err != nil {
+				return err
+			}
+			printTraffic(cmd.OutOrStdout(), name, summary)
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&window, "window", time.Hour, "How far back to look")
+	return cmd
+}
+
+// printTraffic writes a summary in the layout of status.
+func printTraffic(w io.Writer, name string, s *traffic.Summary) {
+	fmt.Fprintf(w, "Service:     %s\n", name)
+	fmt.Fprintf(w, "Window:      last %s\n", formatWindow(s.Window))
+	fmt.Fprintf(w, "Requests:    %d (%.2f/s)\n", s.Requests, s.Rate())
+	if s.Requests == 0 {
+		return
+	}
+	var classes []string
+	for class := 1; class <= 5; class++ {
+		if n := s.Class(class); n > 0 {
+			classes = append(classes, fmt.Sprintf("%dxx %d (%.1f%%)", class, n, s.Share(n)))
+		}
+	}
+	fmt.Fprintf(w, "Status:      %s\n", strings.Join(classes, ", "))
+	var codes []string
+	for _, code := range s.SortedCodes() {
+		codes = append(codes, fmt.Sprintf("%d (%d)", code, s.Codes[code]))
+	}
+	fmt.Fprintf(w, "Codes:       %s\n", strings.Join(codes, ", "))
+	if s.P95 > 0 {
+		fmt.Fprintf(w, "Upstream:    p50 %s, p95 %s\n", formatLatency(s.P50), formatLatency(s.P95))
+	}
+}
+
+// trafficStatus summarizes the last hour of a service's requests on one
+// line for status, or "" when its log cannot be read.
+func trafficStatus(name string) string {
+	cfg, err := config.Load()
+	if err != nil || cfg.Proxy.Kind != "nginx" {
+		return ""
+	}
+	s, err := traffic.Summarize(nginx.AccessLogPath(cfg.Nginx.LogDir, name), time.Hour, time.Now())
+	if err != nil {
+		return ""
+	}
+	if s.Requests == 0 {
+		return "no requests in the last hour"
+	}
+	line := fmt.Sprintf("%.2f req/s over the last hour, 5xx %.1f%%", s.Rate(), s.Share(s.Class(5)))
+	if s.P95 > 0 {
+		line += fmt.Sprintf(", p50 %s, p95 %s", formatLatency(s.P50), formatLatency(s.P95))
+	}
+	return line
+}
+
+// formatWindow drops the zero units of a duration, e.g. "1h" not "1h0m0s".
+func formatWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// formatLatency rounds a latency to a readable precision.
+func formatLatency(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(10 * time.Millisecond).String()
+}
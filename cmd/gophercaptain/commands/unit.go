@@ -0,0 +1,93 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/ecairns22/GopherCaptain/internal/systemd"
+	"github.com/spf13/cobra"
+)
+
+func unitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unit",
+		Short: "Manage systemd settings beyond the generated unit",
+	}
+	cmd.AddCommand(unitOverrideCmd())
+	return cmd
+}
+
+func unitOverrideCmd() *cobra.Command {
+	var (
+		section string
+		remove  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "override <service> [name] [Key=Value...]",
+		Short: "List, set or remove systemd drop-in overrides",
+		Long: `Manage drop-ins in /etc/systemd/system/gc-<service>.service.d/.
+
+With only a service, lists its overrides. With a name and directives, writes
+<name>.conf and restarts the service. With --remove, deletes <name>.conf.
+Overrides are kept in state and survive upgrades and rollbacks.
+
+Example:
+  gophercaptain unit override myapi limits LimitNOFILE=65536
+  gophercaptain unit override myapi env Environment=GOMAXPROCS=2
+  gophercaptain unit override myapi limits --remove`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			orc, cleanup, err := buildOrchestrator()
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			w := cmd.OutOrStdout()
+
+			if len(args) == 1 {
+				overrides, err := orc.Overrides(cmd.Context(), name)
+				if err != nil {
+					return err
+				}
+				if len(overrides) == 0 {
+					fmt.Fprintf(w, "No overrides for %s.\n", name)
+					return nil
+				}
+				for _, ov := range overrides {
+					fmt.Fprintf(w, "=== %s ===\n%s\n", ov.Name, ov.Content)
+				}
+				return nil
+			}
+
+			dropIn := args[1]
+			if remove {
+				if len(args) > 2 {
+					return fmt.Errorf("--remove takes no directives")
+				}
+				if err := orc.RemoveOverride(cmd.Context(), name, dropIn); err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "✓ %s override %q removed\n", name, dropIn)
+				return nil
+			}
+
+			content, err := systemd.RenderDropIn(section, args[2:])
+			if err != nil {
+				return err
+			}
+			if err := orc.SetOverride(cmd.Context(), name, dropIn, content); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "✓ %s override %q applied\n", name, dropIn)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&section, "section", "Service", "Unit file section for the directives, e.g. Unit or Service")
+	cmd.Flags().BoolVar(&remove, "remove", false, "Remove the named override")
+
+	return cmd
+}
@@ -2,13 +2,21 @@ package commands
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/ecairns22/GopherCaptain/internal/orchestrator"
 	"github.com/spf13/cobra"
 )
 
 func upgradeCmd() *cobra.Command {
-	var version string
+	var (
+		version     string
+		observe     time.Duration
+		maxRestarts int
+		noObserve   bool
+		checkRoutes bool
+	)
 
 	cmd := &cobra.Command{
 		Use:   "upgrade <service>",
@@ -23,9 +31,22 @@ func upgradeCmd() *cobra.Command {
 			}
 			defer cleanup()
 
+			// 0 tolerates no restarts, so only an unset flag defers to the config
+			if !cmd.Flags().Changed("max-restarts") {
+				maxRestarts = -1
+			}
+
+			w := cmd.OutOrStdout()
 			req := orchestrator.UpgradeRequest{
-				Name:    name,
-				Version: version,
+				Name:        name,
+				Version:     version,
+				Observe:     observe,
+				MaxRestarts: maxRestarts,
+				NoObserve:   noObserve,
+				CheckRoutes: checkRoutes,
+				Progress: func(msg string) {
+					fmt.Fprintf(w, "… %s\n", msg)
+				},
 			}
 
 			result, err := orc.Upgrade(cmd.Context(), req)
@@ -33,9 +54,11 @@ func upgradeCmd() *cobra.Command {
 				return err
 			}
 
-			w := cmd.OutOrStdout()
 			if result.RolledBack {
 				fmt.Fprintf(w, "✗ %s upgrade to %s failed: %s\n", result.Name, result.NewVersion, result.RollbackMsg)
+				if result.Journal != "" {
+					fmt.Fprintf(w, "\nJournal:\n%s\n", strings.TrimRight(result.Journal, "\n"))
+				}
 				return fmt.Errorf("upgrade failed, rolled back to %s", result.OldVersion)
 			}
 
@@ -45,6 +68,10 @@ func upgradeCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVarP(&version, "version", "v", "latest", "Target version (default: latest)")
+	cmd.Flags().DurationVar(&observe, "observe", 0, "Watch for crash loops this long after the health check (default: [upgrade] observe)")
+	cmd.Flags().IntVar(&maxRestarts, "max-restarts", 0, "Roll back after more restarts than this while observing (default: [upgrade] max_restarts)")
+	cmd.Flags().BoolVar(&noObserve, "no-observe", false, "Skip the post-upgrade crash-loop watch")
+	cmd.Flags().BoolVar(&checkRoutes, "check-routes", false, "Roll back unless every route reaches the new version through the proxy (default: [upgrade] check_routes)")
 
 	return cmd
 }
@@ -0,0 +1,231 @@
+package caddy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ecairns22/GopherCaptain/internal/nginx"
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+)
+
+// Manager handles the Caddy site files of services. The main Caddyfile
+// must contain "import <dir>/*.caddy" for them to be served.
+type Manager struct {
+	runner    runner.CommandRunner
+	caddyfile string // main Caddyfile, validated with the generated sites
+	dir       string // site files; handle files of path routes in dir/hosts/<host>/
+}
+
+// New creates a Caddy manager.
+func New(r runner.CommandRunner, caddyfile, dir string) *Manager {
+	return &Manager{runner: r, caddyfile: caddyfile, dir: dir}
+}
+
+func siteName(name string) string {
+	return fmt.Sprintf("gc-%s.caddy", name)
+}
+
+// hostSiteName is the shared site file for path routes on a host.
+func hostSiteName(host string) string {
+	return fmt.Sprintf("gophercaptain-host-%s.caddy", host)
+}
+
+// handlePath is where a service's handle file for a host lives. It is
+// outside the imported glob, so only its host's site imports it.
+func (m *Manager) handlePath(host, name string) string {
+	return filepath.Join(m.dir, "hosts", host, siteName(name))
+}
+
+// WriteConfig renders the site of a service's subdomain routes and the
+// handle files of its path routes, validates the Caddyfile and reloads
+// Caddy. If validation fails the service's previous files are restored.
+func (m *Manager) WriteConfig(ctx context.Context, params nginx.RouteParams) error {
+	var site string
+	if len(params.Domains()) > 0 {
+		var err error
+		if site, err = RenderSite(params); err != nil {
+			return err
+		}
+	}
+	handles := make(map[string]string)
+	for _, host := range params.Hosts() {
+		if host == "" {
+			path := params.Paths("")[0]
+			return fmt.Errorf("path route %q has no host; use --route example.com%s or set [nginx] default_host", path, path)
+		}
+		content, err := RenderHandle(params, host)
+		if err != nil {
+			return err
+		}
+		handles[host] = content
+	}
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", m.dir, err)
+	}
+	sitePath := filepath.Join(m.dir, siteName(params.Name))
+
+	// Keep what is there now so a failed validation can put it back
+	previous := make(map[string][]byte)
+	stale, _ := filepath.Glob(filepath.Join(m.dir, "hosts", "*", siteName(params.Name)))
+	for _, path := range append(stale, sitePath) {
+		if data, err := os.ReadFile(path); err == nil {
+			previous[path] = data
+		}
+	}
+	hosts := params.Hosts()
+	for _, path := range stale {
+		if host := filepath.Base(filepath.Dir(path)); !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	rollback := func() {
+		os.Remove(sitePath)
+		for host := range handles {
+			os.Remove(m.handlePath(host, params.Name))
+		}
+		for path, data := range previous {
+			os.MkdirAll(filepath.Dir(path), 0755)
+			os.WriteFile(path, data, 0644)
+		}
+		for _, host := range hosts {
+			m.syncHost(host)
+		}
+	}
+
+	if site == "" {
+		os.Remove(sitePath)
+	} else if err := os.WriteFile(sitePath, []byte(site), 0644); err != nil {
+		rollback()
+		return fmt.Errorf("writing caddy site %s: %w", sitePath, err)
+	}
+	for _, path := range stale {
+		os.Remove(path)
+	}
+	for _, host := range hosts {
+		var err error
+		if content, ok := handles[host]; ok {
+			path := m.handlePath(host, params.Name)
+			err = os.MkdirAll(filepath.Dir(path), 0755)
+			if err == nil {
+				err = os.WriteFile(path, []byte(content), 0644)
+			}
+		}
+		if err == nil {
+			err = m.syncHost(host)
+		}
+		if err != nil {
+			rollback()
+			return fmt.Errorf("writing handles for %s on %s: %w", params.Name, host, err)
+		}
+	}
+
+	if err := m.Test(ctx); err != nil {
+		rollback()
+		return fmt.Errorf("%w (config rolled back)", err)
+	}
+	return m.Reload(ctx)
+}
+
+// RemoveConfig removes a service's site and handle files, regenerating
+// the sites of its hosts, then reloads Caddy.
+func (m *Manager) RemoveConfig(ctx context.Context, name string) error {
+	os.Remove(filepath.Join(m.dir, siteName(name)))
+	handles, _ := filepath.Glob(filepath.Join(m.dir, "hosts", "*", siteName(name)))
+	for _, path := range handles {
+		os.Remove(path)
+		m.syncHost(filepath.Base(filepath.Dir(path)))
+	}
+	return m.Reload(ctx)
+}
+
+// Test validates the main Caddyfile with everything it imports.
+func (m *Manager) Test(ctx context.Context) error {
+	_, stderr, err := m.runner.Run(ctx, "caddy", "validate", "--config", m.caddyfile, "--adapter", "caddyfile")
+	if err != nil {
+		return fmt.Errorf("caddy config validation failed: %s", strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// Reload makes Caddy pick up config changes.
+func (m *Manager) Reload(ctx context.Context) error {
+	_, stderr, err := m.runner.Run(ctx, "systemctl", "reload", "caddy")
+	if err != nil {
+		return fmt.Errorf("reloading caddy: %s: %w", strings.TrimSpace(stderr), err)
+	}
+	return nil
+}
+
+// syncHost regenerates a host's site from the handle files in its
+// directory, or removes it along with the directory once none are left.
+func (m *Manager) syncHost(host string) error {
+	sitePath := filepath.Join(m.dir, hostSiteName(host))
+	imports, err := filepath.Glob(filepath.Join(m.dir, "hosts", host, "*.caddy"))
+	if err != nil {
+		return err
+	}
+	if len(imports) == 0 {
+		os.Remove(sitePath)
+		os.Remove(filepath.Join(m.dir, "hosts", host))
+		return nil
+	}
+	sort.Strings(imports)
+
+	content, err := RenderHost(host, imports)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(sitePath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing caddy site %s: %w", sitePath, err)
+	}
+	return nil
+}
+
+// ForeignServerNames returns the hosts of the sites in the main Caddyfile,
+// which GopherCaptain does not manage. Imported files are not followed.
+func (m *Manager) ForeignServerNames() []nginx.ServerName {
+	data, err := os.ReadFile(m.caddyfile)
+	if err != nil {
+		return nil
+	}
+	var names []nginx.ServerName
+	for _, host := range parseSiteHosts(string(data)) {
+		names = append(names, nginx.ServerName{Name: host, File: m.caddyfile})
+	}
+	return names
+}
+
+// parseSiteHosts returns the hosts of the top-level site addresses in a
+// Caddyfile, skipping the global options block, snippets and addresses
+// without a host such as ":8080".
+func parseSiteHosts(caddyfile string) []string {
+	var hosts []string
+	depth := 0
+	for _, line := range strings.Split(caddyfile, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.TrimSpace(line)
+		if depth == 0 && strings.HasSuffix(line, "{") && !strings.HasPrefix(line, "(") {
+			for _, addr := range strings.FieldsFunc(strings.TrimSuffix(line, "{"), func(r rune) bool {
+				return r == ',' || r == ' ' || r == '\t'
+			}) {
+				if i := strings.Index(addr, "://"); i >= 0 {
+					addr = addr[i+3:]
+				}
+				addr, _, _ = strings.Cut(addr, "/")
+				addr, _, _ = strings.Cut(addr, ":")
+				if addr != "" {
+					hosts = append(hosts, strings.ToLower(addr))
+				}
+			}
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+	}
+	return hosts
+}
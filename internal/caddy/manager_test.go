@@ -0,0 +1,262 @@
+package caddy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ecairns22/GopherCaptain/internal/nginx"
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+)
+
+func TestSubdomainSite(t *testing.T) {
+	dir := t.TempDir()
+	fake := runner.NewFakeRunner()
+	mgr := New(fake, "/etc/caddy/Caddyfile", dir)
+
+	params := nginx.RouteParams{
+		Name:   "api",
+		Routes: []nginx.Route{{Type: "subdomain", Value: "api.example.com"}, {Type: "subdomain", Value: "api.example.org"}},
+		Port:   3000,
+	}
+	if err := mgr.WriteConfig(context.Background(), params); err != nil {
+		t.Fatalf("WriteConfig: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "gc-api.caddy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	for _, want := range []string{"http://api.example.com, http://api.example.org {", "reverse_proxy 127.0.0.1:3000"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("missing %q in:\n%s", want, content)
+		}
+	}
+
+	want := []string{
+		"caddy validate --config /etc/caddy/Caddyfile --adapter caddyfile",
+		"systemctl reload caddy",
+	}
+	if len(fake.Calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", fake.Calls, want)
+	}
+	for i, c := range fake.Calls {
+		if c.String() != want[i] {
+			t.Errorf("call %d = %q, want %q", i, c.String(), want[i])
+		}
+	}
+}
+
+func TestRenderSiteTLS(t *testing.T) {
+	params := nginx.RouteParams{
+		Name:        "api",
+		Routes:      []nginx.Route{{Type: "subdomain", Value: "api.example.com"}},
+		Port:        3000,
+		ACMEWebroot: "/var/lib/gophercaptain/acme",
+	}
+	content, err := RenderSite(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, "\napi.example.com {") || strings.Contains(content, "tls ") {
+		t.Errorf("ACME routes should use Caddy's automatic HTTPS:\n%s", content)
+	}
+
+	params.ACMEWebroot = ""
+	params.TLSCert, params.TLSKey = "/etc/ssl/api.pem", "/etc/ssl/api.key"
+	content, err = RenderSite(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, "\napi.example.com {") || !strings.Contains(content, "tls /etc/ssl/api.pem /etc/ssl/api.key") {
+		t.Errorf("expected supplied certificate:\n%s", content)
+	}
+}
+
+func TestRenderOptions(t *testing.T) {
+	params := nginx.RouteParams{
+		Name:        "api",
+		Routes:      []nginx.Route{{Type: "subdomain", Value: "api.example.com"}},
+		Ports:       []int{3000, 3001},
+		Proto:       "grpc",
+		ReadTimeout: "3600",
+		MaxBodySize: "50m",
+		Allow:       []string{"10.0.0.0/8"},
+		Deny:        []string{"10.0.0.13"},
+	}
+	content, err := RenderSite(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"@gc-api-denied remote_ip 10.0.0.13",
+		"respond @gc-api-denied 403",
+		"@gc-api-outside not remote_ip 10.0.0.0/8",
+		"max_size 50MB",
+		"reverse_proxy h2c://127.0.0.1:3000 h2c://127.0.0.1:3001 {",
+		"read_timeout 3600s",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("missing %q in:\n%s", want, content)
+		}
+	}
+
+	params.MaxBodySize = "0"
+	content, _ = RenderSite(params)
+	if strings.Contains(content, "max_size") {
+		t.Errorf("0 should leave the body size unlimited:\n%s", content)
+	}
+}
+
+func TestRenderUnsupported(t *testing.T) {
+	base := nginx.RouteParams{Name: "api", Routes: []nginx.Route{{Type: "subdomain", Value: "api.example.com"}}, Port: 3000}
+
+	auth := base
+	auth.AuthFile = "/etc/gophercaptain/htpasswd/api"
+	if _, err := RenderSite(auth); err == nil || !strings.Contains(err.Error(), "basic auth") {
+		t.Errorf("expected basic auth error, got %v", err)
+	}
+	rate := base
+	rate.RateLimit = "10r/s"
+	if _, err := RenderSite(rate); err == nil || !strings.Contains(err.Error(), "rate limits") {
+		t.Errorf("expected rate limit error, got %v", err)
+	}
+}
+
+func TestRenderMaintenance(t *testing.T) {
+	params := nginx.RouteParams{
+		Name:            "api",
+		Routes:          []nginx.Route{{Type: "path", Value: "example.com/api"}},
+		Port:            3000,
+		Maintenance:     true,
+		MaintenancePage: "/etc/gophercaptain/maintenance.html",
+		RetryAfter:      30,
+	}
+	content, err := RenderHandle(params, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"handle /api* {",
+		"header Retry-After 30",
+		"root * /etc/gophercaptain",
+		"rewrite * /maintenance.html",
+		"status 503",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("missing %q in:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "reverse_proxy") {
+		t.Errorf("maintenance should not proxy:\n%s", content)
+	}
+}
+
+func TestPathRoutesShareHost(t *testing.T) {
+	dir := t.TempDir()
+	mgr := New(runner.NewFakeRunner(), "/etc/caddy/Caddyfile", dir)
+	ctx := context.Background()
+
+	for i, name := range []string{"api", "auth"} {
+		params := nginx.RouteParams{
+			Name:   name,
+			Routes: []nginx.Route{{Type: "path", Value: "example.com/" + name}},
+			Port:   3000 + i,
+		}
+		if err := mgr.WriteConfig(ctx, params); err != nil {
+			t.Fatalf("WriteConfig %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gc-api.caddy")); !os.IsNotExist(err) {
+		t.Error("path-only services should have no site file")
+	}
+
+	hostPath := filepath.Join(dir, "gophercaptain-host-example.com.caddy")
+	data, err := os.ReadFile(hostPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"http://example.com {",
+		"import " + filepath.Join(dir, "hosts", "example.com", "gc-api.caddy"),
+		"import " + filepath.Join(dir, "hosts", "example.com", "gc-auth.caddy"),
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("missing %q in:\n%s", want, data)
+		}
+	}
+
+	mgr.RemoveConfig(ctx, "api")
+	mgr.RemoveConfig(ctx, "auth")
+	if _, err := os.Stat(hostPath); !os.IsNotExist(err) {
+		t.Error("host site should be removed with its last path route")
+	}
+}
+
+func TestValidationFailureRestoresPrevious(t *testing.T) {
+	dir := t.TempDir()
+	fake := runner.NewFakeRunner()
+	mgr := New(fake, "/etc/caddy/Caddyfile", dir)
+	ctx := context.Background()
+
+	params := nginx.RouteParams{Name: "api", Routes: []nginx.Route{{Type: "subdomain", Value: "api.example.com"}}, Port: 3000}
+	if err := mgr.WriteConfig(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(filepath.Join(dir, "gc-api.caddy"))
+
+	fake.SetResponse("caddy validate --config /etc/caddy/Caddyfile --adapter caddyfile", runner.Response{
+		Stderr: "ambiguous site definition: api.example.org",
+		Err:    fmt.Errorf("exit status 1"),
+	})
+	params.Routes = []nginx.Route{{Type: "subdomain", Value: "api.example.org"}}
+	err := mgr.WriteConfig(ctx, params)
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("expected rollback error, got %v", err)
+	}
+	after, _ := os.ReadFile(filepath.Join(dir, "gc-api.caddy"))
+	if string(after) != string(before) {
+		t.Errorf("site not restored:\n%s", after)
+	}
+}
+
+func TestForeignServerNames(t *testing.T) {
+	caddyfile := filepath.Join(t.TempDir(), "Caddyfile")
+	os.WriteFile(caddyfile, []byte(`{
+	email admin@example.com
+}
+
+(common) {
+	encode gzip
+}
+
+example.com, www.example.com {
+	root * /srv/www
+	file_server
+}
+
+https://shop.example.com:8443/store {
+	respond "{http.request.host}"
+}
+
+:9000 {
+	respond "health"
+}
+
+import /etc/caddy/gophercaptain/*.caddy
+`), 0644)
+
+	mgr := New(runner.NewFakeRunner(), caddyfile, t.TempDir())
+	var got []string
+	for _, n := range mgr.ForeignServerNames() {
+		got = append(got, n.Name)
+	}
+	want := []string{"example.com", "www.example.com", "shop.example.com"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,206 @@
+// Package caddy serves routes through Caddy instead of nginx, from site
+// files that the main Caddyfile imports.
+package caddy
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/ecairns22/GopherCaptain/internal/nginx"
+)
+
+// siteTemplate serves the subdomain routes of a service as one site.
+const siteTemplate = `# Managed by GopherCaptain
+{{.Addresses}} {
+{{- if .TLSCert}}
+	tls {{.TLSCert}} {{.TLSKey}}
+{{- end}}
+{{- range .Directives}}
+	{{.}}
+{{- end}}
+}
+`
+
+// handleTemplate holds a service's path routes on one host, imported by
+// the host's site.
+const handleTemplate = `{{range $i, $path := .Paths}}{{if $i}}
+{{end}}handle {{$path}}* {
+{{- range $.Directives}}
+	{{.}}
+{{- end}}
+}
+{{end}}`
+
+// hostTemplate is the shared site for all path routes on a host. Like
+// nginx's host server it is plain HTTP.
+const hostTemplate = `# Managed by GopherCaptain; regenerated when path routes on {{.Host}} change
+http://{{.Host}} {
+{{- range .Imports}}
+	import {{.}}
+{{- end}}
+}
+`
+
+var (
+	parsedSiteTemplate   = template.Must(template.New("site").Parse(siteTemplate))
+	parsedHandleTemplate = template.Must(template.New("handle").Parse(handleTemplate))
+	parsedHostTemplate   = template.Must(template.New("host").Parse(hostTemplate))
+)
+
+type siteData struct {
+	Addresses  string
+	TLSCert    string
+	TLSKey     string
+	Paths      []string
+	Directives []string
+}
+
+// RenderSite renders gc-<name>.caddy, the site of a service's subdomain
+// routes. ACME routes are left to Caddy's automatic HTTPS, routes with a
+// supplied certificate use it, and the rest are served over plain HTTP.
+func RenderSite(params nginx.RouteParams) (string, error) {
+	lines, err := directives(params)
+	if err != nil {
+		return "", err
+	}
+	data := siteData{Directives: lines}
+	var addresses []string
+	for _, domain := range params.Domains() {
+		if params.ACMEWebroot == "" && !params.TLS() {
+			domain = "http://" + domain
+		}
+		addresses = append(addresses, domain)
+	}
+	data.Addresses = strings.Join(addresses, ", ")
+	if params.ACMEWebroot == "" && params.TLS() {
+		data.TLSCert, data.TLSKey = params.TLSCert, params.TLSKey
+	}
+	return execute(parsedSiteTemplate, data)
+}
+
+// RenderHandle renders the handle blocks of a service's path routes on
+// host.
+func RenderHandle(params nginx.RouteParams, host string) (string, error) {
+	lines, err := directives(params)
+	if err != nil {
+		return "", err
+	}
+	data := siteData{Paths: params.Paths(host), Directives: lines}
+	if len(data.Paths) == 0 {
+		return "", fmt.Errorf("%s has no path routes on %s", params.Name, host)
+	}
+	return execute(parsedHandleTemplate, data)
+}
+
+// RenderHost renders the site for a host that imports the given handle
+// files.
+func RenderHost(host string, imports []string) (string, error) {
+	return execute(parsedHostTemplate, struct {
+		Host    string
+		Imports []string
+	}{host, imports})
+}
+
+func execute(t *template.Template, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering caddy %s: %w", t.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+// directives returns the lines of a service's site or handle block. Basic
+// auth and rate limits have no Caddy equivalent without plugins (Caddy's
+// basicauth takes bcrypt, not the $apr1$ hashes kept in state), so they
+// are refused rather than silently dropped. Keepalive needs nothing:
+// Caddy pools upstream connections by default.
+func directives(p nginx.RouteParams) ([]string, error) {
+	for _, r := range p.Routes {
+		if r.Type != "subdomain" && r.Type != "path" {
+			return nil, fmt.Errorf("unknown route type %q; must be 'subdomain' or 'path'", r.Type)
+		}
+	}
+	if err := nginx.ValidateProxyOptions(p.Proto, p.Keepalive, p.ReadTimeout, p.MaxBodySize); err != nil {
+		return nil, err
+	}
+	if p.AuthFile != "" {
+		return nil, fmt.Errorf("basic auth is not supported by the caddy proxy")
+	}
+	if p.RateLimit != "" {
+		return nil, fmt.Errorf("rate limits are not supported by the caddy proxy")
+	}
+
+	if p.Maintenance {
+		lines := []string{fmt.Sprintf("header Retry-After %d", p.RetryAfter)}
+		if p.MaintenancePage == "" {
+			return append(lines, "respond 503"), nil
+		}
+		return append(lines,
+			"root * "+filepath.Dir(p.MaintenancePage),
+			"rewrite * /"+filepath.Base(p.MaintenancePage),
+			"file_server {",
+			"\tstatus 503",
+			"}",
+		), nil
+	}
+
+	// Matchers are named per service, as path routes of several services
+	// share their host's site
+	var lines []string
+	if len(p.Deny) > 0 {
+		matcher := "@gc-" + p.Name + "-denied"
+		lines = append(lines, matcher+" remote_ip "+strings.Join(p.Deny, " "), "respond "+matcher+" 403")
+	}
+	if len(p.Allow) > 0 {
+		matcher := "@gc-" + p.Name + "-outside"
+		lines = append(lines, matcher+" not remote_ip "+strings.Join(p.Allow, " "), "respond "+matcher+" 403")
+	}
+	if size := maxSize(p.MaxBodySize); size != "" {
+		lines = append(lines, "request_body {", "\tmax_size "+size, "}")
+	}
+
+	scheme := ""
+	if p.Proto == "grpc" {
+		scheme = "h2c://"
+	}
+	upstreams := make([]string, 0, len(p.Servers()))
+	for _, port := range p.Servers() {
+		upstreams = append(upstreams, fmt.Sprintf("%s127.0.0.1:%d", scheme, port))
+	}
+	proxy := "reverse_proxy " + strings.Join(upstreams, " ")
+	if p.ReadTimeout == "" {
+		return append(lines, proxy), nil
+	}
+	return append(lines,
+		proxy+" {",
+		"\ttransport http {",
+		"\t\tread_timeout "+duration(p.ReadTimeout),
+		"\t}",
+		"}",
+	), nil
+}
+
+// duration converts an nginx time, whose default unit is seconds, to a
+// Caddy duration.
+func duration(t string) string {
+	if strings.TrimLeft(t, "0123456789") == "" {
+		return t + "s"
+	}
+	return t
+}
+
+// maxSize converts an nginx size to a Caddy one; nginx's "0" (no limit)
+// is Caddy's default and renders nothing.
+func maxSize(size string) string {
+	if strings.TrimLeft(size, "0") == "" {
+		return ""
+	}
+	switch unit := strings.ToUpper(size[len(size)-1:]); unit {
+	case "K", "M", "G":
+		return size[:len(size)-1] + unit + "B"
+	}
+	return size
+}
@@ -0,0 +1,214 @@
+package certs
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+)
+
+// Issuer obtains certificates from an ACME CA using the HTTP-01 challenge.
+// Challenge responses are written below a webroot that nginx serves at
+// /.well-known/acme-challenge/ on port 80.
+type Issuer struct {
+	directory  string
+	email      string
+	accountKey string // path of the PEM account key, created on first use
+	webroot    string
+	httpClient *http.Client
+}
+
+// NewIssuer creates an Issuer for the given ACME directory URL.
+func NewIssuer(directory, email, accountKey, webroot string) *Issuer {
+	return &Issuer{
+		directory:  directory,
+		email:      email,
+		accountKey: accountKey,
+		webroot:    webroot,
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to talk to the CA, e.g. one that
+// trusts a test CA's certificate.
+func (i *Issuer) WithHTTPClient(c *http.Client) *Issuer {
+	i.httpClient = c
+	return i
+}
+
+// Obtain registers the account if needed, proves control of the domains
+// over HTTP-01 and returns the issued certificate chain and a new private
+// key, both PEM-encoded. The first domain is the certificate's common name;
+// all of them are in its subject alternative names.
+func (i *Issuer) Obtain(ctx context.Context, domains ...string) (certPEM, keyPEM []byte, err error) {
+	if len(domains) == 0 {
+		return nil, nil, fmt.Errorf("no domains to obtain a certificate for")
+	}
+	domain := strings.Join(domains, ", ")
+	key, err := loadOrCreateKey(i.accountKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := &acme.Client{Key: key, DirectoryURL: i.directory, HTTPClient: i.httpClient}
+
+	account := &acme.Account{}
+	if i.email != "" {
+		account.Contact = []string{"mailto:" + i.email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, nil, fmt.Errorf("registering ACME account: %w", err)
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domains...))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating order for %s: %w", domain, err)
+	}
+	for _, url := range order.AuthzURLs {
+		if err := i.authorize(ctx, client, url); err != nil {
+			return nil, nil, fmt.Errorf("authorizing %s: %w", domain, err)
+		}
+	}
+	orderURL := order.URI
+	if order, err = client.WaitOrder(ctx, orderURL); err != nil {
+		return nil, nil, fmt.Errorf("waiting for order for %s: %w", domain, err)
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating certificate key: %w", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, certKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating certificate request: %w", err)
+	}
+	chain, err := finalize(ctx, client, order.FinalizeURL, orderURL, csr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("finalizing order for %s: %w", domain, err)
+	}
+
+	for _, der := range chain {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyPEM, err = encodeKey(certKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return certPEM, keyPEM, nil
+}
+
+// finalize submits the CSR and returns the issued chain. A CA that is still
+// processing may answer without the order's URL, which CreateOrderCert then
+// cannot poll; in that case the order is polled here instead.
+func finalize(ctx context.Context, client *acme.Client, finalizeURL, orderURL string, csr []byte) ([][]byte, error) {
+	chain, _, err := client.CreateOrderCert(ctx, finalizeURL, csr, true)
+	if err == nil {
+		return chain, nil
+	}
+	order, werr := client.WaitOrder(ctx, orderURL)
+	if werr != nil || order.Status != acme.StatusValid || order.CertURL == "" {
+		return nil, err
+	}
+	return client.FetchCert(ctx, order.CertURL, true)
+}
+
+// authorize completes the HTTP-01 challenge of one authorization. The
+// challenge file is removed again once the CA has checked it.
+func (i *Issuer) authorize(ctx context.Context, client *acme.Client, url string) error {
+	authz, err := client.GetAuthorization(ctx, url)
+	if err != nil {
+		return err
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "http-01" {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("CA offered no http-01 challenge")
+	}
+
+	response, err := client.HTTP01ChallengeResponse(chal.Token)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(i.webroot, client.HTTP01ChallengePath(chal.Token))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating challenge dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(response), 0644); err != nil {
+		return fmt.Errorf("writing challenge response: %w", err)
+	}
+	defer os.Remove(path)
+
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return fmt.Errorf("accepting challenge: %w", err)
+	}
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return err
+	}
+	return nil
+}
+
+// loadOrCreateKey reads the PEM account key at path, generating and saving
+// a new one if the file does not exist.
+func loadOrCreateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("account key %s is not PEM", path)
+		}
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing account key %s: %w", path, err)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading account key %s: %w", path, err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating account key: %w", err)
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("creating account key dir: %w", err)
+	}
+	if err := os.WriteFile(path, keyPEM, 0600); err != nil {
+		return nil, fmt.Errorf("writing account key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("encoding key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,63 @@
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestObtainPebble runs the HTTP-01 flow against a local Pebble test CA.
+// It is skipped unless GC_PEBBLE_DIRECTORY is set, e.g.
+//
+//	pebble -config test/config/pebble-config.json &
+//	GC_PEBBLE_DIRECTORY=https://localhost:14000/dir go test ./internal/certs
+//
+// Pebble validates challenges on GC_PEBBLE_HTTP_PORT (default 5002) of
+// GC_PEBBLE_DOMAIN (default localhost), which this test serves itself.
+func TestObtainPebble(t *testing.T) {
+	directory := os.Getenv("GC_PEBBLE_DIRECTORY")
+	if directory == "" {
+		t.Skip("GC_PEBBLE_DIRECTORY not set")
+	}
+	domain := envOr("GC_PEBBLE_DOMAIN", "localhost")
+	port := envOr("GC_PEBBLE_HTTP_PORT", "5002")
+
+	webroot := t.TempDir()
+	srv := &http.Server{Addr: ":" + port, Handler: http.FileServer(http.Dir(webroot))}
+	go srv.ListenAndServe()
+	defer srv.Close()
+
+	// Pebble's API certificate is self-signed
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	issuer := NewIssuer(directory, "test@example.com", t.TempDir()+"/account.key", webroot).WithHTTPClient(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	certPEM, keyPEM, err := issuer.Obtain(ctx, domain)
+	if err != nil {
+		t.Fatalf("Obtain: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := Write(dir, domain, certPEM, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath := Paths(dir, domain)
+	if err := Validate(certPath, keyPath); err != nil {
+		t.Errorf("issued certificate does not match its key: %v", err)
+	}
+	if NeedsRenewal(certPath, time.Now()) {
+		t.Error("freshly issued certificate should not need renewal")
+	}
+	t.Logf("issued certificate for %s", domain)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,85 @@
+// Package certs obtains, stores and checks the TLS certificates that nginx
+// serves for subdomain routes.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RenewBefore is how long before expiry an ACME certificate is renewed.
+const RenewBefore = 30 * 24 * time.Hour
+
+// Paths returns the certificate chain and key paths for a domain under dir.
+func Paths(dir, domain string) (cert, key string) {
+	return filepath.Join(dir, domain, "fullchain.pem"), filepath.Join(dir, domain, "privkey.pem")
+}
+
+// Write stores a certificate chain and key for a domain under dir. The key
+// is written before the chain so nginx never sees a chain without its key.
+func Write(dir, domain string, certPEM, keyPEM []byte) error {
+	certPath, keyPath := Paths(dir, domain)
+	if err := os.MkdirAll(filepath.Dir(certPath), 0700); err != nil {
+		return fmt.Errorf("creating cert dir: %w", err)
+	}
+	if err := writeFile(keyPath, keyPEM, 0600); err != nil {
+		return err
+	}
+	return writeFile(certPath, certPEM, 0644)
+}
+
+// writeFile replaces path atomically so a reload never reads a partial file.
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks that a supplied certificate and key exist and belong together.
+func Validate(certPath, keyPath string) error {
+	if !filepath.IsAbs(certPath) || !filepath.IsAbs(keyPath) {
+		return fmt.Errorf("certificate and key paths must be absolute")
+	}
+	if _, err := tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+		return fmt.Errorf("loading certificate %s: %w", certPath, err)
+	}
+	return nil
+}
+
+// NotAfter returns the expiry of the first certificate in a PEM chain.
+func NotAfter(certPath string) (time.Time, error) {
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading certificate %s: %w", certPath, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return time.Time{}, fmt.Errorf("certificate %s is not PEM", certPath)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing certificate %s: %w", certPath, err)
+	}
+	return cert.NotAfter, nil
+}
+
+// NeedsRenewal reports whether the certificate at certPath is missing or
+// expires within RenewBefore of now.
+func NeedsRenewal(certPath string, now time.Time) bool {
+	notAfter, err := NotAfter(certPath)
+	if err != nil {
+		return true
+	}
+	return notAfter.Sub(now) < RenewBefore
+}
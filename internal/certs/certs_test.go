@@ -0,0 +1,125 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// selfSigned returns a PEM certificate for domain valid until notAfter,
+// and its PEM key.
+func selfSigned(t *testing.T, domain string, notAfter time.Time) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err = encodeKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM
+}
+
+func TestWriteAndValidate(t *testing.T) {
+	dir := t.TempDir()
+	certPEM, keyPEM := selfSigned(t, "api.example.com", time.Now().Add(90*24*time.Hour))
+
+	if err := Write(dir, "api.example.com", certPEM, keyPEM); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	certPath, keyPath := Paths(dir, "api.example.com")
+	if certPath != filepath.Join(dir, "api.example.com", "fullchain.pem") {
+		t.Errorf("cert path = %s", certPath)
+	}
+	info, err := os.Stat(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("key mode = %o, want 600", info.Mode().Perm())
+	}
+
+	if err := Validate(certPath, keyPath); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	// A key from another certificate does not match
+	_, otherKey := selfSigned(t, "other.example.com", time.Now().Add(time.Hour))
+	other := filepath.Join(dir, "other.pem")
+	os.WriteFile(other, otherKey, 0600)
+	if err := Validate(certPath, other); err == nil {
+		t.Error("expected mismatched key to fail validation")
+	}
+	if err := Validate("cert.pem", "key.pem"); err == nil {
+		t.Error("expected relative paths to fail validation")
+	}
+}
+
+func TestNeedsRenewal(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	fresh, key := selfSigned(t, "fresh.example.com", now.Add(60*24*time.Hour))
+	Write(dir, "fresh.example.com", fresh, key)
+	expiring, key := selfSigned(t, "old.example.com", now.Add(10*24*time.Hour))
+	Write(dir, "old.example.com", expiring, key)
+
+	freshPath, _ := Paths(dir, "fresh.example.com")
+	oldPath, _ := Paths(dir, "old.example.com")
+	missingPath, _ := Paths(dir, "missing.example.com")
+
+	if NeedsRenewal(freshPath, now) {
+		t.Error("certificate valid for 60 days should not need renewal")
+	}
+	if !NeedsRenewal(oldPath, now) {
+		t.Error("certificate expiring in 10 days should need renewal")
+	}
+	if !NeedsRenewal(missingPath, now) {
+		t.Error("missing certificate should need renewal")
+	}
+
+	notAfter, err := NotAfter(freshPath)
+	if err != nil {
+		t.Fatalf("NotAfter: %v", err)
+	}
+	if notAfter.Before(now.Add(59 * 24 * time.Hour)) {
+		t.Errorf("NotAfter = %s", notAfter)
+	}
+}
+
+func TestAccountKeyReused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acme", "account.key")
+
+	first, err := loadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("creating key: %v", err)
+	}
+	second, err := loadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("loading key: %v", err)
+	}
+	if !first.(*ecdsa.PrivateKey).Equal(second) {
+		t.Error("account key should be reused across calls")
+	}
+}
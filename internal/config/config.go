@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	toml "github.com/pelletier/go-toml/v2"
 )
@@ -12,11 +13,16 @@ const defaultConfigPath = "/etc/gophercaptain/gophercaptain.conf"
 const envOverride = "GOPHERCAPTAIN_CONFIG"
 
 type Config struct {
-	GitHub   GitHubConfig   `toml:"github"`
-	Ports    PortsConfig    `toml:"ports"`
-	MariaDB  MariaDBConfig  `toml:"mariadb"`
-	Nginx    NginxConfig    `toml:"nginx"`
-	Releases ReleasesConfig `toml:"releases"`
+	GitHub    GitHubConfig    `toml:"github"`
+	Ports     PortsConfig     `toml:"ports"`
+	MariaDB   MariaDBConfig   `toml:"mariadb"`
+	Postgres  PostgresConfig  `toml:"postgres"`
+	Nginx     NginxConfig     `toml:"nginx"`
+	Proxy     ProxyConfig     `toml:"proxy"`
+	Releases  ReleasesConfig  `toml:"releases"`
+	Upgrade   UpgradeConfig   `toml:"upgrade"`
+	Backups   BackupsConfig   `toml:"backups"`
+	Templates TemplatesConfig `toml:"templates"`
 }
 
 type GitHubConfig struct {
@@ -37,15 +43,70 @@ type MariaDBConfig struct {
 	AdminPassword     string `toml:"-"` // resolved at load time, never serialized
 }
 
+// PostgresConfig is the server that databases of services deployed with
+// --db postgres live on.
+type PostgresConfig struct {
+	Host              string `toml:"host"`
+	Port              int    `toml:"port"`
+	AdminUser         string `toml:"admin_user"`
+	AdminPasswordFile string `toml:"admin_password_file"`
+	SSLMode           string `toml:"sslmode"` // libpq sslmode of admin and service connections
+	AdminPassword     string `toml:"-"`       // resolved at load time, never serialized
+}
+
 type NginxConfig struct {
-	SitesDir   string `toml:"sites_dir"`
-	EnabledDir string `toml:"enabled_dir"`
+	SitesDir      string `toml:"sites_dir"`
+	EnabledDir    string `toml:"enabled_dir"`
+	SnippetsDir   string `toml:"snippets_dir"`   // location snippets of path routes, per host
+	DefaultHost   string `toml:"default_host"`   // host for path routes given as "/api"
+	TLS           string `toml:"tls"`            // "acme" enables HTTPS for subdomain routes by default
+	ACMEEmail     string `toml:"acme_email"`     // account contact, optional
+	ACMEDirectory string `toml:"acme_directory"` // ACME directory URL
+	ACMEWebroot   string `toml:"acme_webroot"`   // served at /.well-known/acme-challenge/
+	CertDir       string `toml:"cert_dir"`       // issued certificates, one directory per domain
+	HtpasswdDir   string `toml:"htpasswd_dir"`   // basic auth users, one file per service
+	LogDir        string `toml:"log_dir"`        // JSON access logs, one file per service
+
+	MaintenancePage string `toml:"maintenance_page"` // HTML served with 503s in maintenance, empty = nginx's own
+	RetryAfter      int    `toml:"retry_after"`      // Retry-After seconds of maintenance 503s
+}
+
+// ProxyConfig selects the reverse proxy that serves routes. The [nginx]
+// route, TLS and access settings apply whichever proxy is used.
+type ProxyConfig struct {
+	Kind          string `toml:"kind"`           // "nginx" (default), "caddy" or "haproxy"
+	Caddyfile     string `toml:"caddyfile"`      // main Caddyfile; must import <caddy_dir>/*.caddy
+	CaddyDir      string `toml:"caddy_dir"`      // generated Caddy sites
+	HAProxyConfig string `toml:"haproxy_config"` // main haproxy.cfg, checked with the generated one
+	HAProxyDir    string `toml:"haproxy_dir"`    // generated gophercaptain.cfg and its parts
 }
 
 type ReleasesConfig struct {
 	AssetPattern string `toml:"asset_pattern"`
 }
 
+// UpgradeConfig controls the crash-loop watch after an upgrade passes its
+// health check. An observe window of "0s" disables it.
+type UpgradeConfig struct {
+	Observe       string        `toml:"observe"`      // e.g. "60s"
+	MaxRestarts   int           `toml:"max_restarts"` // roll back above this many restarts; 0 = any restart
+	CheckRoutes   bool          `toml:"check_routes"` // roll back unless every route reaches the new version
+	ObserveWindow time.Duration `toml:"-"`            // parsed from Observe at load time
+}
+
+// BackupsConfig controls where database backups are kept and how many of
+// them each service keeps.
+type BackupsConfig struct {
+	Dir  string `toml:"dir"`  // one directory per service
+	Keep int    `toml:"keep"` // newest backups kept per service, older ones are pruned
+}
+
+// TemplatesConfig locates override templates for generated nginx and
+// systemd config, in Dir or Dir/<service>.
+type TemplatesConfig struct {
+	Dir string `toml:"dir"`
+}
+
 // DefaultPath returns the default configuration file path.
 func DefaultPath() string {
 	if p := os.Getenv(envOverride); p != "" {
@@ -67,6 +128,10 @@ func LoadFrom(path string) (*Config, error) {
 	}
 
 	var cfg Config
+	// Defaults for which 0 is a valid setting go in before parsing, so
+	// only a value in the file replaces them
+	cfg.Upgrade.MaxRestarts = 2
+	cfg.Backups.Keep = 7
 	if err := toml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("parsing config %s: %w", path, err)
 	}
@@ -87,15 +152,72 @@ func LoadFrom(path string) (*Config, error) {
 	if cfg.MariaDB.AdminUser == "" {
 		cfg.MariaDB.AdminUser = "root"
 	}
+	if cfg.Postgres.Host == "" {
+		cfg.Postgres.Host = "127.0.0.1"
+	}
+	if cfg.Postgres.Port == 0 {
+		cfg.Postgres.Port = 5432
+	}
+	if cfg.Postgres.AdminUser == "" {
+		cfg.Postgres.AdminUser = "postgres"
+	}
+	if cfg.Postgres.SSLMode == "" {
+		cfg.Postgres.SSLMode = "disable"
+	}
 	if cfg.Nginx.SitesDir == "" {
 		cfg.Nginx.SitesDir = "/etc/nginx/sites-available"
 	}
 	if cfg.Nginx.EnabledDir == "" {
 		cfg.Nginx.EnabledDir = "/etc/nginx/sites-enabled"
 	}
+	if cfg.Nginx.SnippetsDir == "" {
+		cfg.Nginx.SnippetsDir = "/etc/nginx/gophercaptain"
+	}
+	if cfg.Nginx.ACMEDirectory == "" {
+		cfg.Nginx.ACMEDirectory = "https://acme-v02.api.letsencrypt.org/directory"
+	}
+	if cfg.Nginx.ACMEWebroot == "" {
+		cfg.Nginx.ACMEWebroot = "/var/lib/gophercaptain/acme"
+	}
+	if cfg.Nginx.CertDir == "" {
+		cfg.Nginx.CertDir = "/etc/gophercaptain/certs"
+	}
+	if cfg.Templates.Dir == "" {
+		cfg.Templates.Dir = "/etc/gophercaptain/templates"
+	}
+	if cfg.Nginx.HtpasswdDir == "" {
+		cfg.Nginx.HtpasswdDir = "/etc/gophercaptain/htpasswd"
+	}
+	if cfg.Nginx.LogDir == "" {
+		cfg.Nginx.LogDir = "/var/log/nginx"
+	}
+	if cfg.Nginx.RetryAfter == 0 {
+		cfg.Nginx.RetryAfter = 60
+	}
+	if cfg.Proxy.Kind == "" {
+		cfg.Proxy.Kind = "nginx"
+	}
+	if cfg.Proxy.Caddyfile == "" {
+		cfg.Proxy.Caddyfile = "/etc/caddy/Caddyfile"
+	}
+	if cfg.Proxy.CaddyDir == "" {
+		cfg.Proxy.CaddyDir = "/etc/caddy/gophercaptain"
+	}
+	if cfg.Proxy.HAProxyConfig == "" {
+		cfg.Proxy.HAProxyConfig = "/etc/haproxy/haproxy.cfg"
+	}
+	if cfg.Proxy.HAProxyDir == "" {
+		cfg.Proxy.HAProxyDir = "/etc/haproxy/gophercaptain"
+	}
 	if cfg.Releases.AssetPattern == "" {
 		cfg.Releases.AssetPattern = "{{.Name}}-linux-amd64"
 	}
+	if cfg.Upgrade.Observe == "" {
+		cfg.Upgrade.Observe = "60s"
+	}
+	if cfg.Backups.Dir == "" {
+		cfg.Backups.Dir = "/var/lib/gophercaptain/backups"
+	}
 
 	// Validate required fields
 	if cfg.GitHub.Token == "" {
@@ -105,6 +227,29 @@ func LoadFrom(path string) (*Config, error) {
 		return nil, fmt.Errorf("config: github.owner is required")
 	}
 
+	if cfg.Nginx.TLS != "" && cfg.Nginx.TLS != "acme" {
+		return nil, fmt.Errorf("config: nginx.tls %q must be \"acme\" or empty", cfg.Nginx.TLS)
+	}
+	switch cfg.Proxy.Kind {
+	case "nginx", "caddy", "haproxy":
+	default:
+		return nil, fmt.Errorf("config: proxy.kind %q must be \"nginx\", \"caddy\" or \"haproxy\"", cfg.Proxy.Kind)
+	}
+
+	if cfg.Backups.Keep < 1 {
+		return nil, fmt.Errorf("config: backups.keep %d must be at least 1", cfg.Backups.Keep)
+	}
+
+	if cfg.Upgrade.MaxRestarts < 0 {
+		return nil, fmt.Errorf("config: upgrade.max_restarts %d must not be negative", cfg.Upgrade.MaxRestarts)
+	}
+
+	window, err := time.ParseDuration(cfg.Upgrade.Observe)
+	if err != nil || window < 0 {
+		return nil, fmt.Errorf("config: upgrade.observe %q is not a valid duration", cfg.Upgrade.Observe)
+	}
+	cfg.Upgrade.ObserveWindow = window
+
 	// Resolve MariaDB admin password from file (optional — empty password is valid)
 	if cfg.MariaDB.AdminPasswordFile != "" {
 		pwData, err := os.ReadFile(cfg.MariaDB.AdminPasswordFile)
@@ -113,6 +258,13 @@ func LoadFrom(path string) (*Config, error) {
 		}
 		cfg.MariaDB.AdminPassword = strings.TrimSpace(string(pwData))
 	}
+	if cfg.Postgres.AdminPasswordFile != "" {
+		pwData, err := os.ReadFile(cfg.Postgres.AdminPasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading postgres admin password from %s: %w", cfg.Postgres.AdminPasswordFile, err)
+		}
+		cfg.Postgres.AdminPassword = strings.TrimSpace(string(pwData))
+	}
 
 	return &cfg, nil
 }
@@ -133,11 +285,39 @@ port     = 3306
 admin_user = "root"
 admin_password_file = "/root/.mariadb_password"
 
+[postgres]
+# For services deployed with --db postgres
+# host = "127.0.0.1"
+# port = 5432
+# admin_user = "postgres"
+# admin_password_file = "/root/.postgres_password"
+
 [nginx]
 sites_dir   = "/etc/nginx/sites-available"
 enabled_dir = "/etc/nginx/sites-enabled"
+# default_host = "example.com"        # host for path routes given as --route /api
+# tls        = "acme"                 # HTTPS via Let's Encrypt for subdomain routes
+# acme_email = "you@example.com"
+# maintenance_page = "/etc/gophercaptain/maintenance.html"  # served with 503s during upgrades
+# retry_after = 60
+# log_dir = "/var/log/nginx"           # JSON access logs read by gophercaptain traffic
+
+[proxy]
+# kind = "nginx"                     # or "caddy" (sites imported from /etc/caddy/gophercaptain/*.caddy) or "haproxy"
 
 [releases]
 asset_pattern = "{{.Name}}-linux-amd64"
+
+[upgrade]
+observe      = "60s"   # watch for crash loops this long after an upgrade ("0s" disables)
+max_restarts = 2       # roll back if the service restarts more often than this
+# check_routes = true  # roll back unless every route reaches the new version through the proxy
+
+[backups]
+# dir  = "/var/lib/gophercaptain/backups"  # gophercaptain db backup writes <dir>/<service>/
+# keep = 7                                  # newest backups kept per service
+
+[templates]
+# dir = "/etc/gophercaptain/templates"  # overrides: nginx.conf.tmpl, nginx-location.conf.tmpl, unit.service.tmpl
 `
 }
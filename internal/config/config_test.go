@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func writeTestConfig(t *testing.T, dir, content string) string {
@@ -175,9 +176,131 @@ admin_password_file = "%s"
 	if cfg.MariaDB.Port != 3306 {
 		t.Errorf("default port = %d, want 3306", cfg.MariaDB.Port)
 	}
+	if cfg.Postgres.Host != "127.0.0.1" || cfg.Postgres.Port != 5432 || cfg.Postgres.AdminUser != "postgres" || cfg.Postgres.SSLMode != "disable" {
+		t.Errorf("default postgres = %+v", cfg.Postgres)
+	}
 	if cfg.Releases.AssetPattern != "{{.Name}}-linux-amd64" {
 		t.Errorf("default asset_pattern = %q", cfg.Releases.AssetPattern)
 	}
+	if cfg.Upgrade.ObserveWindow != time.Minute {
+		t.Errorf("default upgrade.observe = %s, want 1m0s", cfg.Upgrade.ObserveWindow)
+	}
+	if cfg.Upgrade.MaxRestarts != 2 {
+		t.Errorf("default upgrade.max_restarts = %d, want 2", cfg.Upgrade.MaxRestarts)
+	}
+	if cfg.Nginx.TLS != "" {
+		t.Errorf("default nginx.tls = %q, want empty", cfg.Nginx.TLS)
+	}
+	if cfg.Nginx.ACMEDirectory != "https://acme-v02.api.letsencrypt.org/directory" {
+		t.Errorf("default acme_directory = %q", cfg.Nginx.ACMEDirectory)
+	}
+	if cfg.Nginx.CertDir != "/etc/gophercaptain/certs" {
+		t.Errorf("default cert_dir = %q", cfg.Nginx.CertDir)
+	}
+	if cfg.Templates.Dir != "/etc/gophercaptain/templates" {
+		t.Errorf("default templates.dir = %q", cfg.Templates.Dir)
+	}
+	if cfg.Nginx.HtpasswdDir != "/etc/gophercaptain/htpasswd" {
+		t.Errorf("default htpasswd_dir = %q", cfg.Nginx.HtpasswdDir)
+	}
+	if cfg.Nginx.LogDir != "/var/log/nginx" {
+		t.Errorf("default log_dir = %q", cfg.Nginx.LogDir)
+	}
+	if cfg.Nginx.RetryAfter != 60 {
+		t.Errorf("default retry_after = %d", cfg.Nginx.RetryAfter)
+	}
+	if cfg.Proxy.Kind != "nginx" {
+		t.Errorf("default proxy.kind = %q, want nginx", cfg.Proxy.Kind)
+	}
+	if cfg.Proxy.CaddyDir != "/etc/caddy/gophercaptain" || cfg.Proxy.HAProxyDir != "/etc/haproxy/gophercaptain" {
+		t.Errorf("default proxy dirs = %q, %q", cfg.Proxy.CaddyDir, cfg.Proxy.HAProxyDir)
+	}
+	if cfg.Backups.Dir != "/var/lib/gophercaptain/backups" || cfg.Backups.Keep != 7 {
+		t.Errorf("default backups = %q, keep %d", cfg.Backups.Dir, cfg.Backups.Keep)
+	}
+}
+
+func TestInvalidProxyKind(t *testing.T) {
+	dir := t.TempDir()
+	pwFile := writePasswordFile(t, dir, "secret123")
+	content := strings.ReplaceAll(`[github]
+token = "ghp_test"
+owner = "testowner"
+
+[mariadb]
+admin_password_file = "%s"
+
+[proxy]
+kind = "traefik"
+`, "%s", pwFile)
+
+	path := writeTestConfig(t, dir, content)
+	if _, err := LoadFrom(path); err == nil || !strings.Contains(err.Error(), "proxy.kind") {
+		t.Errorf("expected proxy.kind error, got %v", err)
+	}
+}
+
+func TestZeroMaxRestarts(t *testing.T) {
+	dir := t.TempDir()
+	pwFile := writePasswordFile(t, dir, "secret123")
+	content := strings.ReplaceAll(`[github]
+token = "ghp_test"
+owner = "testowner"
+
+[mariadb]
+admin_password_file = "%s"
+
+[upgrade]
+max_restarts = 0
+`, "%s", pwFile)
+
+	cfg, err := LoadFrom(writeTestConfig(t, dir, content))
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if cfg.Upgrade.MaxRestarts != 0 {
+		t.Errorf("upgrade.max_restarts = %d, want an explicit 0 kept", cfg.Upgrade.MaxRestarts)
+	}
+}
+
+func TestInvalidObserveWindow(t *testing.T) {
+	dir := t.TempDir()
+	pwFile := writePasswordFile(t, dir, "secret123")
+	content := strings.ReplaceAll(`[github]
+token = "ghp_test"
+owner = "testowner"
+
+[mariadb]
+admin_password_file = "%s"
+
+[upgrade]
+observe = "a minute"
+`, "%s", pwFile)
+
+	path := writeTestConfig(t, dir, content)
+	if _, err := LoadFrom(path); err == nil || !strings.Contains(err.Error(), "upgrade.observe") {
+		t.Errorf("expected upgrade.observe error, got %v", err)
+	}
+}
+
+func TestZeroBackupsKeep(t *testing.T) {
+	dir := t.TempDir()
+	pwFile := writePasswordFile(t, dir, "secret123")
+	content := strings.ReplaceAll(`[github]
+token = "ghp_test"
+owner = "testowner"
+
+[mariadb]
+admin_password_file = "%s"
+
+[backups]
+keep = 0
+`, "%s", pwFile)
+
+	path := writeTestConfig(t, dir, content)
+	if _, err := LoadFrom(path); err == nil || !strings.Contains(err.Error(), "backups.keep") {
+		t.Errorf("expected backups.keep error, got %v", err)
+	}
 }
 
 func TestTemplateConfig(t *testing.T) {
@@ -2,55 +2,95 @@ package db
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
+	"io"
+	"os"
 	"regexp"
+	"strings"
 
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/ecairns22/GopherCaptain/internal/config"
-	"github.com/ecairns22/GopherCaptain/internal/creds"
+	"github.com/ecairns22/GopherCaptain/internal/runner"
 )
 
 var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
 
-// Manager handles MariaDB database and user lifecycle.
-type Manager struct {
-	db *sql.DB
+// Providers of service databases, as recorded in state.
+const (
+	MariaDBProvider  = "mariadb"
+	PostgresProvider = "postgres"
+)
+
+// Provider handles the database and user lifecycle of services on one
+// database server. The database and its user are both named gc_<name>.
+type Provider interface {
+	Ping(ctx context.Context) error
+	DatabaseExists(ctx context.Context, name string) (bool, error)
+	CreateDatabase(ctx context.Context, name string) (*CreateResult, error)
+	DropDatabase(ctx context.Context, name string) error
+	// Dump writes a plain SQL dump of the database to w.
+	Dump(ctx context.Context, name string, w io.Writer) error
+	// Restore replaces the contents of the database with a dump read from r.
+	Restore(ctx context.Context, name string, r io.Reader) error
+	Close() error
 }
 
+var (
+	_ Provider = (*MariaDB)(nil)
+	_ Provider = (*Postgres)(nil)
+)
+
 // CreateResult holds the details of a newly created database.
 type CreateResult struct {
 	DBName   string
 	DBUser   string
 	Password string
+	Host     string
+	Port     int
+	URL      string // connection URL for DATABASE_URL, empty if the provider has no convention for one
 }
 
-// New creates a DB manager connecting to MariaDB with the given admin credentials.
-func New(host string, port int, user, password string) (*Manager, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/", user, password, host, port)
-	db, err := sql.Open("mysql", dsn)
+// Manager holds the configured providers and hands out the one a service
+// uses. Connections are opened lazily, so an unused provider costs nothing.
+type Manager struct {
+	providers map[string]Provider
+}
+
+// NewFromConfig creates a DB manager with a provider per database server
+// in the tool configuration. Dump and restore tools run through r.
+func NewFromConfig(cfg *config.Config, r runner.CommandRunner) (*Manager, error) {
+	mariadb, err := NewMariaDB(r, cfg.MariaDB.Host, cfg.MariaDB.Port, cfg.MariaDB.AdminUser, cfg.MariaDB.AdminPassword)
 	if err != nil {
-		return nil, fmt.Errorf("connecting to MariaDB: %w", err)
+		return nil, err
 	}
-	return &Manager{db: db}, nil
+	postgres, err := NewPostgres(r, cfg.Postgres.Host, cfg.Postgres.Port, cfg.Postgres.AdminUser, cfg.Postgres.AdminPassword, cfg.Postgres.SSLMode)
+	if err != nil {
+		mariadb.Close()
+		return nil, err
+	}
+	return &Manager{providers: map[string]Provider{
+		MariaDBProvider:  mariadb,
+		PostgresProvider: postgres,
+	}}, nil
 }
 
-// NewFromConfig creates a DB manager from the tool configuration.
-func NewFromConfig(cfg *config.Config) (*Manager, error) {
-	return New(cfg.MariaDB.Host, cfg.MariaDB.Port, cfg.MariaDB.AdminUser, cfg.MariaDB.AdminPassword)
+// Provider returns the provider called kind.
+func (m *Manager) Provider(kind string) (Provider, error) {
+	p, ok := m.providers[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown database provider %q: must be %q or %q", kind, MariaDBProvider, PostgresProvider)
+	}
+	return p, nil
 }
 
-// Close closes the underlying database connection.
+// Close closes the connections of every provider.
 func (m *Manager) Close() error {
-	return m.db.Close()
-}
-
-// Ping tests the MariaDB connection.
-func (m *Manager) Ping(ctx context.Context) error {
-	if err := m.db.PingContext(ctx); err != nil {
-		return fmt.Errorf("MariaDB connection failed: %w; check that MariaDB is running (systemctl status mariadb) and credentials are correct", err)
+	var first error
+	for _, p := range m.providers {
+		if err := p.Close(); err != nil && first == nil {
+			first = err
+		}
 	}
-	return nil
+	return first
 }
 
 // ValidateServiceName checks that a name is safe to use as a DB/user identifier.
@@ -61,93 +101,40 @@ func ValidateServiceName(name string) error {
 	return nil
 }
 
-// DatabaseExists checks if the database gc_<name> already exists.
-func (m *Manager) DatabaseExists(ctx context.Context, name string) (bool, error) {
-	dbName := "gc_" + name
-	var result string
-	err := m.db.QueryRowContext(ctx,
-		"SELECT SCHEMA_NAME FROM INFORMATION_SCHEMA.SCHEMATA WHERE SCHEMA_NAME = ?", dbName).Scan(&result)
-	if err == sql.ErrNoRows {
-		return false, nil
-	}
-	if err != nil {
-		return false, fmt.Errorf("checking database existence: %w", err)
-	}
-	return true, nil
-}
-
-// CreateDatabase creates database gc_<name>, user gc_<name>@localhost with a generated password,
-// and grants all privileges.
-func (m *Manager) CreateDatabase(ctx context.Context, name string) (*CreateResult, error) {
-	if err := ValidateServiceName(name); err != nil {
-		return nil, err
-	}
-
-	dbName := "gc_" + name
-	dbUser := "gc_" + name
-
-	// Check if database already exists
-	exists, err := m.DatabaseExists(ctx, name)
+// writeSecretFile writes content to a new temporary file readable only by
+// the current user, for credentials handed to client tools. The returned
+// function removes it.
+func writeSecretFile(pattern, content string) (string, func(), error) {
+	f, err := os.CreateTemp("", pattern)
 	if err != nil {
-		return nil, err
-	}
-	if exists {
-		return nil, fmt.Errorf("database %q already exists; use --name to choose a different service name", dbName)
+		return "", nil, fmt.Errorf("creating credentials file: %w", err)
 	}
+	cleanup := func() { os.Remove(f.Name()) }
 
-	// Generate password
-	password, err := creds.Generate(32)
-	if err != nil {
-		return nil, fmt.Errorf("generating database password: %w", err)
+	// CreateTemp already uses 0600, but be explicit about it
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("securing credentials file: %w", err)
 	}
-
-	// Create database — name is validated by regex, safe for identifier use
-	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE `%s`", dbName)); err != nil {
-		return nil, fmt.Errorf("creating database %s: %w", dbName, err)
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("writing credentials file: %w", err)
 	}
-
-	// Create user
-	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("CREATE USER '%s'@'localhost' IDENTIFIED BY '%s'", dbUser, password)); err != nil {
-		// Rollback: drop database
-		m.db.ExecContext(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", dbName))
-		return nil, fmt.Errorf("creating user %s: %w", dbUser, err)
-	}
-
-	// Grant privileges
-	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("GRANT ALL PRIVILEGES ON `%s`.* TO '%s'@'localhost'", dbName, dbUser)); err != nil {
-		// Rollback
-		m.db.ExecContext(ctx, fmt.Sprintf("DROP USER IF EXISTS '%s'@'localhost'", dbUser))
-		m.db.ExecContext(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", dbName))
-		return nil, fmt.Errorf("granting privileges: %w", err)
+	if err := f.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("writing credentials file: %w", err)
 	}
-
-	if _, err := m.db.ExecContext(ctx, "FLUSH PRIVILEGES"); err != nil {
-		return nil, fmt.Errorf("flushing privileges: %w", err)
-	}
-
-	return &CreateResult{
-		DBName:   dbName,
-		DBUser:   dbUser,
-		Password: password,
-	}, nil
+	return f.Name(), cleanup, nil
 }
 
-// DropDatabase drops the database gc_<name> and user gc_<name>@localhost.
-func (m *Manager) DropDatabase(ctx context.Context, name string) error {
-	if err := ValidateServiceName(name); err != nil {
-		return err
-	}
-
-	dbName := "gc_" + name
-	dbUser := "gc_" + name
-
-	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("DROP USER IF EXISTS '%s'@'localhost'", dbUser)); err != nil {
-		return fmt.Errorf("dropping user %s: %w", dbUser, err)
-	}
-
-	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", dbName)); err != nil {
-		return fmt.Errorf("dropping database %s: %w", dbName, err)
+// stderrSuffix formats a client tool's error output for appending to an
+// error message.
+func stderrSuffix(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if stderr == "" {
+		return ""
 	}
-
-	return nil
+	return ": " + stderr
 }
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ecairns22/GopherCaptain/internal/creds"
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// MariaDB handles MariaDB database and user lifecycle.
+type MariaDB struct {
+	db       *sql.DB
+	runner   runner.CommandRunner
+	host     string
+	port     int
+	user     string
+	password string
+}
+
+// NewMariaDB creates a provider connecting to MariaDB with the given admin
+// credentials. Dumps and restores run mysqldump and mysql through r.
+func NewMariaDB(r runner.CommandRunner, host string, port int, user, password string) (*MariaDB, error) {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/", user, password, host, port)
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to MariaDB: %w", err)
+	}
+	return &MariaDB{db: db, runner: r, host: host, port: port, user: user, password: password}, nil
+}
+
+// Close closes the underlying database connection.
+func (m *MariaDB) Close() error {
+	return m.db.Close()
+}
+
+// Ping tests the MariaDB connection.
+func (m *MariaDB) Ping(ctx context.Context) error {
+	if err := m.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("MariaDB connection failed: %w; check that MariaDB is running (systemctl status mariadb) and credentials are correct", err)
+	}
+	return nil
+}
+
+// DatabaseExists checks if the database gc_<name> already exists.
+func (m *MariaDB) DatabaseExists(ctx context.Context, name string) (bool, error) {
+	dbName := "gc_" + name
+	var result string
+	err := m.db.QueryRowContext(ctx,
+		"SELECT SCHEMA_NAME FROM INFORMATION_SCHEMA.SCHEMATA WHERE SCHEMA_NAME = ?", dbName).Scan(&result)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("checking database existence: %w", err)
+	}
+	return true, nil
+}
+
+// CreateDatabase creates database gc_<name>, user gc_<name>@localhost with a generated password,
+// and grants all privileges.
+func (m *MariaDB) CreateDatabase(ctx context.Context, name string) (*CreateResult, error) {
+	if err := ValidateServiceName(name); err != nil {
+		return nil, err
+	}
+
+	dbName := "gc_" + name
+	dbUser := "gc_" + name
+
+	// Check if database already exists
+	exists, err := m.DatabaseExists(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("database %q already exists; use --name to choose a different service name", dbName)
+	}
+
+	// Generate password
+	password, err := creds.Generate(32)
+	if err != nil {
+		return nil, fmt.Errorf("generating database password: %w", err)
+	}
+
+	// Create database — name is validated by regex, safe for identifier use
+	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE `%s`", dbName)); err != nil {
+		return nil, fmt.Errorf("creating database %s: %w", dbName, err)
+	}
+
+	// Create user
+	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("CREATE USER '%s'@'localhost' IDENTIFIED BY '%s'", dbUser, password)); err != nil {
+		// Rollback: drop database
+		m.db.ExecContext(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", dbName))
+		return nil, fmt.Errorf("creating user %s: %w", dbUser, err)
+	}
+
+	// Grant privileges
+	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("GRANT ALL PRIVILEGES ON `%s`.* TO '%s'@'localhost'", dbName, dbUser)); err != nil {
+		// Rollback
+		m.db.ExecContext(ctx, fmt.Sprintf("DROP USER IF EXISTS '%s'@'localhost'", dbUser))
+		m.db.ExecContext(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", dbName))
+		return nil, fmt.Errorf("granting privileges: %w", err)
+	}
+
+	if _, err := m.db.ExecContext(ctx, "FLUSH PRIVILEGES"); err != nil {
+		return nil, fmt.Errorf("flushing privileges: %w", err)
+	}
+
+	return &CreateResult{
+		DBName:   dbName,
+		DBUser:   dbUser,
+		Password: password,
+		Host:     m.host,
+		Port:     m.port,
+	}, nil
+}
+
+// DropDatabase drops the database gc_<name> and user gc_<name>@localhost.
+func (m *MariaDB) DropDatabase(ctx context.Context, name string) error {
+	if err := ValidateServiceName(name); err != nil {
+		return err
+	}
+
+	dbName := "gc_" + name
+	dbUser := "gc_" + name
+
+	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("DROP USER IF EXISTS '%s'@'localhost'", dbUser)); err != nil {
+		return fmt.Errorf("dropping user %s: %w", dbUser, err)
+	}
+
+	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", dbName)); err != nil {
+		return fmt.Errorf("dropping database %s: %w", dbName, err)
+	}
+
+	return nil
+}
+
+// Dump writes an SQL dump of the database gc_<name> to w using mysqldump.
+func (m *MariaDB) Dump(ctx context.Context, name string, w io.Writer) error {
+	if err := ValidateServiceName(name); err != nil {
+		return err
+	}
+	dbName := "gc_" + name
+
+	optFile, cleanup, err := writeSecretFile("gophercaptain-my-*.cnf", m.optionFile())
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var stderr strings.Builder
+	err = m.runner.Pipe(ctx, nil, w, &stderr, "mysqldump",
+		"--defaults-extra-file="+optFile,
+		"--single-transaction", "--routines", "--triggers", dbName)
+	if err != nil {
+		return fmt.Errorf("dumping database %s: %w%s", dbName, err, stderrSuffix(stderr.String()))
+	}
+	return nil
+}
+
+// Restore loads an SQL dump read from r into the database gc_<name> using
+// the mysql client.
+func (m *MariaDB) Restore(ctx context.Context, name string, r io.Reader) error {
+	if err := ValidateServiceName(name); err != nil {
+		return err
+	}
+	dbName := "gc_" + name
+
+	optFile, cleanup, err := writeSecretFile("gophercaptain-my-*.cnf", m.optionFile())
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var stderr strings.Builder
+	if err := m.runner.Pipe(ctx, r, io.Discard, &stderr, "mysql",
+		"--defaults-extra-file="+optFile, dbName); err != nil {
+		return fmt.Errorf("restoring database %s: %w%s", dbName, err, stderrSuffix(stderr.String()))
+	}
+	return nil
+}
+
+// optionFile renders a client option file holding the admin credentials,
+// so the password never appears on a command line.
+func (m *MariaDB) optionFile() string {
+	quote := func(v string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	}
+	return "[client]\n" +
+		"user=" + quote(m.user) + "\n" +
+		"password=" + quote(m.password) + "\n" +
+		"host=" + quote(m.host) + "\n" +
+		"port=" + strconv.Itoa(m.port) + "\n"
+}
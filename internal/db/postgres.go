@@ -0,0 +1,224 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ecairns22/GopherCaptain/internal/creds"
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+	"github.com/lib/pq"
+)
+
+// Postgres handles PostgreSQL database and role lifecycle. Each service
+// gets a login role and a database owned by it.
+type Postgres struct {
+	db       *sql.DB
+	runner   runner.CommandRunner
+	host     string
+	port     int
+	user     string
+	password string
+	sslMode  string
+}
+
+// NewPostgres creates a provider connecting to PostgreSQL with the given
+// admin credentials. Dumps and restores run pg_dump and psql through r.
+func NewPostgres(r runner.CommandRunner, host string, port int, user, password, sslMode string) (*Postgres, error) {
+	dsn := connURL(host, port, user, password, "postgres", sslMode)
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to PostgreSQL: %w", err)
+	}
+	return &Postgres{db: db, runner: r, host: host, port: port, user: user, password: password, sslMode: sslMode}, nil
+}
+
+// connURL builds a libpq connection URL.
+func connURL(host string, port int, user, password, dbName, sslMode string) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(host, strconv.Itoa(port)),
+		Path:     "/" + dbName,
+		RawQuery: url.Values{"sslmode": {sslMode}}.Encode(),
+	}
+	return u.String()
+}
+
+// Close closes the underlying database connection.
+func (p *Postgres) Close() error {
+	return p.db.Close()
+}
+
+// Ping tests the PostgreSQL connection.
+func (p *Postgres) Ping(ctx context.Context) error {
+	if err := p.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("PostgreSQL connection failed: %w; check that PostgreSQL is running (systemctl status postgresql) and the [postgres] credentials are correct", err)
+	}
+	return nil
+}
+
+// DatabaseExists checks if the database gc_<name> already exists.
+func (p *Postgres) DatabaseExists(ctx context.Context, name string) (bool, error) {
+	var result int
+	err := p.db.QueryRowContext(ctx, "SELECT 1 FROM pg_database WHERE datname = $1", "gc_"+name).Scan(&result)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("checking database existence: %w", err)
+	}
+	return true, nil
+}
+
+// CreateDatabase creates login role gc_<name> with a generated password and
+// database gc_<name> owned by it, which no other role may connect to.
+func (p *Postgres) CreateDatabase(ctx context.Context, name string) (*CreateResult, error) {
+	if err := ValidateServiceName(name); err != nil {
+		return nil, err
+	}
+
+	dbName := "gc_" + name
+	dbUser := "gc_" + name
+
+	exists, err := p.DatabaseExists(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("database %q already exists; use --name to choose a different service name", dbName)
+	}
+
+	password, err := creds.Generate(32)
+	if err != nil {
+		return nil, fmt.Errorf("generating database password: %w", err)
+	}
+
+	// Names may contain "-", so they are always quoted
+	role, database := pq.QuoteIdentifier(dbUser), pq.QuoteIdentifier(dbName)
+
+	if _, err := p.db.ExecContext(ctx, fmt.Sprintf("CREATE ROLE %s LOGIN PASSWORD %s", role, pq.QuoteLiteral(password))); err != nil {
+		return nil, fmt.Errorf("creating role %s: %w", dbUser, err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE %s OWNER %s", database, role)); err != nil {
+		// Rollback: drop role
+		p.db.ExecContext(ctx, "DROP ROLE IF EXISTS "+role)
+		return nil, fmt.Errorf("creating database %s: %w", dbName, err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, fmt.Sprintf("REVOKE ALL ON DATABASE %s FROM PUBLIC", database)); err != nil {
+		// Rollback
+		p.db.ExecContext(ctx, "DROP DATABASE IF EXISTS "+database)
+		p.db.ExecContext(ctx, "DROP ROLE IF EXISTS "+role)
+		return nil, fmt.Errorf("revoking public access to %s: %w", dbName, err)
+	}
+
+	return &CreateResult{
+		DBName:   dbName,
+		DBUser:   dbUser,
+		Password: password,
+		Host:     p.host,
+		Port:     p.port,
+		URL:      connURL(p.host, p.port, dbUser, password, dbName, p.sslMode),
+	}, nil
+}
+
+// DropDatabase drops the database gc_<name> and then its owner, role gc_<name>.
+func (p *Postgres) DropDatabase(ctx context.Context, name string) error {
+	if err := ValidateServiceName(name); err != nil {
+		return err
+	}
+
+	dbName := "gc_" + name
+	dbUser := "gc_" + name
+
+	if _, err := p.db.ExecContext(ctx, "DROP DATABASE IF EXISTS "+pq.QuoteIdentifier(dbName)); err != nil {
+		return fmt.Errorf("dropping database %s: %w", dbName, err)
+	}
+
+	if _, err := p.db.ExecContext(ctx, "DROP ROLE IF EXISTS "+pq.QuoteIdentifier(dbUser)); err != nil {
+		return fmt.Errorf("dropping role %s: %w", dbUser, err)
+	}
+
+	return nil
+}
+
+// Dump writes an SQL dump of the database gc_<name> to w using pg_dump.
+// Ownership and grants are left out, since a restore recreates every object
+// as role gc_<name>.
+func (p *Postgres) Dump(ctx context.Context, name string, w io.Writer) error {
+	if err := ValidateServiceName(name); err != nil {
+		return err
+	}
+	dbName := "gc_" + name
+
+	passFile, cleanup, err := writeSecretFile("gophercaptain-pgpass-*", p.passFile())
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var stderr strings.Builder
+	err = p.runner.Pipe(ctx, nil, w, &stderr, "pg_dump",
+		"--clean", "--if-exists", "--no-owner", "--no-privileges",
+		"--dbname="+p.connInfo(dbName, passFile))
+	if err != nil {
+		return fmt.Errorf("dumping database %s: %w%s", dbName, err, stderrSuffix(stderr.String()))
+	}
+	return nil
+}
+
+// Restore loads an SQL dump read from r into the database gc_<name> using
+// psql, in a single transaction run as role gc_<name>.
+func (p *Postgres) Restore(ctx context.Context, name string, r io.Reader) error {
+	if err := ValidateServiceName(name); err != nil {
+		return err
+	}
+	dbName := "gc_" + name
+
+	passFile, cleanup, err := writeSecretFile("gophercaptain-pgpass-*", p.passFile())
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var stderr strings.Builder
+	err = p.runner.Pipe(ctx, r, io.Discard, &stderr, "psql",
+		"--quiet", "--single-transaction", "--set", "ON_ERROR_STOP=1",
+		"--dbname="+p.connInfo(dbName, passFile),
+		"--command", "SET ROLE "+pq.QuoteIdentifier(dbName),
+		"--file", "-")
+	if err != nil {
+		return fmt.Errorf("restoring database %s: %w%s", dbName, err, stderrSuffix(stderr.String()))
+	}
+	return nil
+}
+
+// connInfo builds a libpq keyword/value connection string for the admin
+// user, reading the password from passFile.
+func (p *Postgres) connInfo(dbName, passFile string) string {
+	quote := func(v string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(v) + "'"
+	}
+	return strings.Join([]string{
+		"host=" + quote(p.host),
+		"port=" + strconv.Itoa(p.port),
+		"user=" + quote(p.user),
+		"dbname=" + quote(dbName),
+		"sslmode=" + quote(p.sslMode),
+		"passfile=" + quote(passFile),
+	}, " ")
+}
+
+// passFile renders a password file entry for the admin user, so the
+// password never appears on a command line.
+func (p *Postgres) passFile() string {
+	escape := strings.NewReplacer(`\`, `\\`, ":", `\:`).Replace
+	return fmt.Sprintf("%s:%d:*:%s:%s\n", escape(p.host), p.port, escape(p.user), escape(p.password))
+}
@@ -70,8 +70,9 @@ func (c *Client) ResolveVersion(ctx context.Context, owner, repo, version string
 	return version, nil
 }
 
-// DownloadAsset downloads the matching release asset to /opt/gophercaptain/bin/<name>/<name>-<version>,
-// makes it executable, and creates a symlink <name> -> <name>-<version>.
+// DownloadAsset downloads the matching release asset to /opt/gophercaptain/bin/<name>/<name>-<version>
+// and makes it executable. It does not touch the <name> symlink: the caller
+// activates the new version once it is ready.
 func (c *Client) DownloadAsset(ctx context.Context, owner, repo, version, serviceName string) (string, error) {
 	if owner == "" {
 		owner = c.defaultOwner
@@ -136,12 +137,5 @@ func (c *Client) DownloadAsset(ctx context.Context, owner, repo, version, servic
 		return "", fmt.Errorf("chmod %s: %w", destPath, err)
 	}
 
-	// Create/update symlink
-	symlinkPath := filepath.Join(dir, serviceName)
-	os.Remove(symlinkPath) // remove existing symlink if any
-	if err := os.Symlink(filename, symlinkPath); err != nil {
-		return "", fmt.Errorf("creating symlink %s -> %s: %w", symlinkPath, filename, err)
-	}
-
 	return destPath, nil
 }
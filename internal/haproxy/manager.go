@@ -0,0 +1,202 @@
+package haproxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ecairns22/GopherCaptain/internal/nginx"
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+)
+
+// Manager handles the HAProxy config of services. HAProxy must load the
+// generated file as well as its main config, e.g. with an extra
+// "-f <dir>/gophercaptain.cfg" in its unit.
+type Manager struct {
+	runner runner.CommandRunner
+	config string // main haproxy.cfg, checked together with the generated file
+	dir    string // gophercaptain.cfg; per-service parts in services/, certificates in certs/
+}
+
+// New creates an HAProxy manager.
+func New(r runner.CommandRunner, config, dir string) *Manager {
+	return &Manager{runner: r, config: config, dir: dir}
+}
+
+// ConfigPath is the generated file holding every service's routes.
+func (m *Manager) ConfigPath() string {
+	return filepath.Join(m.dir, "gophercaptain.cfg")
+}
+
+// paths returns the backend section, route list and combined certificate
+// files of a service.
+func (m *Manager) paths(name string) (backend, routes, pem string) {
+	base := filepath.Join(m.dir, "services", "gc-"+name)
+	return base + ".cfg", base + ".json", filepath.Join(m.dir, "certs", "gc-"+name+".pem")
+}
+
+// WriteConfig renders a service's backend, regenerates the config with
+// its routes, checks it and reloads HAProxy. If the check fails the
+// service's previous parts are restored.
+func (m *Manager) WriteConfig(ctx context.Context, params nginx.RouteParams) error {
+	backend, err := RenderBackend(params)
+	if err != nil {
+		return err
+	}
+	for _, host := range params.Hosts() {
+		if host == "" {
+			path := params.Paths("")[0]
+			return fmt.Errorf("path route %q has no host; use --route example.com%s or set [nginx] default_host", path, path)
+		}
+	}
+	site, err := json.Marshal(Site{Name: params.Name, Routes: params.Routes, TLS: params.TLS()})
+	if err != nil {
+		return err
+	}
+	var pem []byte
+	if params.TLS() {
+		// HAProxy wants the chain and key in one file
+		cert, err := os.ReadFile(params.TLSCert)
+		if err != nil {
+			return fmt.Errorf("reading certificate: %w", err)
+		}
+		key, err := os.ReadFile(params.TLSKey)
+		if err != nil {
+			return fmt.Errorf("reading certificate key: %w", err)
+		}
+		pem = append(append(cert, '\n'), key...)
+	}
+
+	backendPath, sitePath, pemPath := m.paths(params.Name)
+	for _, dir := range []string{filepath.Dir(backendPath), filepath.Dir(pemPath)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating %s: %w", dir, err)
+		}
+	}
+
+	// Keep what is there now so a failed check can put it back
+	previous := make(map[string][]byte)
+	for _, path := range []string{backendPath, sitePath, pemPath} {
+		if data, err := os.ReadFile(path); err == nil {
+			previous[path] = data
+		}
+	}
+	rollback := func() {
+		for _, path := range []string{backendPath, sitePath, pemPath} {
+			if data, ok := previous[path]; ok {
+				perm := os.FileMode(0644)
+				if path == pemPath {
+					perm = 0600
+				}
+				os.WriteFile(path, data, perm)
+			} else {
+				os.Remove(path)
+			}
+		}
+		m.generate()
+	}
+
+	if err := os.WriteFile(backendPath, []byte(backend), 0644); err != nil {
+		rollback()
+		return fmt.Errorf("writing haproxy backend %s: %w", backendPath, err)
+	}
+	if err := os.WriteFile(sitePath, site, 0644); err != nil {
+		rollback()
+		return fmt.Errorf("writing haproxy routes %s: %w", sitePath, err)
+	}
+	if pem == nil {
+		os.Remove(pemPath)
+	} else if err := os.WriteFile(pemPath, pem, 0600); err != nil {
+		rollback()
+		return fmt.Errorf("writing certificate %s: %w", pemPath, err)
+	}
+	if err := m.generate(); err != nil {
+		rollback()
+		return err
+	}
+
+	if err := m.Test(ctx); err != nil {
+		rollback()
+		return fmt.Errorf("%w (config rolled back)", err)
+	}
+	return m.Reload(ctx)
+}
+
+// RemoveConfig removes a service's parts, regenerates the config and
+// reloads HAProxy.
+func (m *Manager) RemoveConfig(ctx context.Context, name string) error {
+	backendPath, sitePath, pemPath := m.paths(name)
+	os.Remove(backendPath)
+	os.Remove(sitePath)
+	os.Remove(pemPath)
+	if err := m.generate(); err != nil {
+		return err
+	}
+	return m.Reload(ctx)
+}
+
+// Test checks the main config together with the generated one.
+func (m *Manager) Test(ctx context.Context) error {
+	_, stderr, err := m.runner.Run(ctx, "haproxy", "-c", "-f", m.config, "-f", m.ConfigPath())
+	if err != nil {
+		return fmt.Errorf("haproxy config check failed: %s", strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// Reload makes HAProxy pick up config changes.
+func (m *Manager) Reload(ctx context.Context) error {
+	_, stderr, err := m.runner.Run(ctx, "systemctl", "reload", "haproxy")
+	if err != nil {
+		return fmt.Errorf("reloading haproxy: %s: %w", strings.TrimSpace(stderr), err)
+	}
+	return nil
+}
+
+// ForeignServerNames returns nothing: HAProxy routes by ACLs, not server
+// names, and the one frontend per port is GopherCaptain's.
+func (m *Manager) ForeignServerNames() []nginx.ServerName {
+	return nil
+}
+
+// generate writes gophercaptain.cfg from the parts of every service, in
+// name order.
+func (m *Manager) generate() error {
+	files, err := filepath.Glob(filepath.Join(m.dir, "services", "gc-*.json"))
+	if err != nil {
+		return err
+	}
+	var sites []Site
+	var backends []string
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading haproxy routes %s: %w", path, err)
+		}
+		var site Site
+		if err := json.Unmarshal(data, &site); err != nil {
+			return fmt.Errorf("parsing haproxy routes %s: %w", path, err)
+		}
+		backend, err := os.ReadFile(strings.TrimSuffix(path, ".json") + ".cfg")
+		if err != nil {
+			return fmt.Errorf("reading haproxy backend for %s: %w", site.Name, err)
+		}
+		sites = append(sites, site)
+		backends = append(backends, string(backend))
+	}
+
+	content, err := RenderConfig(sites, backends, filepath.Join(m.dir, "certs")+"/")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", m.dir, err)
+	}
+	if err := os.WriteFile(m.ConfigPath(), []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing haproxy config %s: %w", m.ConfigPath(), err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package haproxy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ecairns22/GopherCaptain/internal/nginx"
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+)
+
+func TestWriteConfig(t *testing.T) {
+	dir := t.TempDir()
+	fake := runner.NewFakeRunner()
+	mgr := New(fake, "/etc/haproxy/haproxy.cfg", dir)
+	ctx := context.Background()
+
+	if err := mgr.WriteConfig(ctx, nginx.RouteParams{
+		Name:   "api",
+		Routes: []nginx.Route{{Type: "subdomain", Value: "api.example.com"}, {Type: "subdomain", Value: "api.example.org"}},
+		Ports:  []int{3000, 3001},
+	}); err != nil {
+		t.Fatalf("WriteConfig api: %v", err)
+	}
+	if err := mgr.WriteConfig(ctx, nginx.RouteParams{
+		Name:   "web",
+		Routes: []nginx.Route{{Type: "path", Value: "example.com/web"}, {Type: "path", Value: "example.com/w"}},
+		Port:   3002,
+	}); err != nil {
+		t.Fatalf("WriteConfig web: %v", err)
+	}
+
+	data, err := os.ReadFile(mgr.ConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	want := []string{
+		"frontend gophercaptain-http",
+		"bind :80",
+		"use_backend gc-web if { hdr(host),field(1,:) -i example.com } { path_beg /web }",
+		"use_backend gc-web if { hdr(host),field(1,:) -i example.com } { path_beg /w }",
+		"use_backend gc-api if { hdr(host),field(1,:) -i api.example.com api.example.org }",
+		"\nbackend gc-api",
+		"server gc-api-3000 127.0.0.1:3000",
+		"server gc-api-3001 127.0.0.1:3001",
+		"\nbackend gc-web",
+		"server gc-web-3002 127.0.0.1:3002",
+	}
+	last := -1
+	for _, w := range want {
+		i := strings.Index(content, w)
+		if i < last {
+			t.Errorf("%q missing or out of order in:\n%s", w, content)
+		}
+		last = i
+	}
+	if strings.Contains(content, "gophercaptain-https") {
+		t.Errorf("no HTTPS frontend expected without certificates:\n%s", content)
+	}
+
+	if got := fake.Calls[0].String(); got != "haproxy -c -f /etc/haproxy/haproxy.cfg -f "+mgr.ConfigPath() {
+		t.Errorf("first call = %q", got)
+	}
+
+	if err := mgr.RemoveConfig(ctx, "api"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(mgr.ConfigPath())
+	if strings.Contains(string(data), "gc-api") {
+		t.Errorf("api should be gone:\n%s", data)
+	}
+}
+
+func TestWriteConfigTLS(t *testing.T) {
+	dir := t.TempDir()
+	mgr := New(runner.NewFakeRunner(), "/etc/haproxy/haproxy.cfg", dir)
+
+	certs := t.TempDir()
+	cert, key := filepath.Join(certs, "api.pem"), filepath.Join(certs, "api.key")
+	os.WriteFile(cert, []byte("CERT"), 0644)
+	os.WriteFile(key, []byte("KEY"), 0600)
+
+	if err := mgr.WriteConfig(context.Background(), nginx.RouteParams{
+		Name:    "api",
+		Routes:  []nginx.Route{{Type: "subdomain", Value: "api.example.com"}},
+		Port:    3000,
+		TLSCert: cert,
+		TLSKey:  key,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(mgr.ConfigPath())
+	content := string(data)
+	for _, want := range []string{
+		"http-request redirect scheme https code 301 if { hdr(host),field(1,:) -i api.example.com }",
+		"frontend gophercaptain-https",
+		"bind :443 ssl crt " + filepath.Join(dir, "certs") + "/",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("missing %q in:\n%s", want, content)
+		}
+	}
+	https := content[strings.Index(content, "frontend gophercaptain-https"):]
+	if !strings.Contains(https, "use_backend gc-api") {
+		t.Errorf("HTTPS frontend should route to api:\n%s", content)
+	}
+
+	pem, err := os.ReadFile(filepath.Join(dir, "certs", "gc-api.pem"))
+	if err != nil || string(pem) != "CERT\nKEY" {
+		t.Errorf("combined pem = %q, %v", pem, err)
+	}
+}
+
+func TestRenderBackendOptions(t *testing.T) {
+	content, err := RenderBackend(nginx.RouteParams{
+		Name:        "chat",
+		Routes:      []nginx.Route{{Type: "subdomain", Value: "chat.example.com"}},
+		Port:        3000,
+		Proto:       "websocket",
+		ReadTimeout: "3600",
+		Allow:       []string{"10.0.0.0/8", "192.168.0.0/16"},
+		Deny:        []string{"10.0.0.13"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"http-request deny deny_status 403 if { src 10.0.0.13 }",
+		"http-request deny deny_status 403 unless { src 10.0.0.0/8 192.168.0.0/16 }",
+		"timeout server 3600s",
+		"timeout tunnel 3600s",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("missing %q in:\n%s", want, content)
+		}
+	}
+
+	content, err = RenderBackend(nginx.RouteParams{
+		Name:            "chat",
+		Routes:          []nginx.Route{{Type: "subdomain", Value: "chat.example.com"}},
+		Port:            3000,
+		Deny:            []string{"10.0.0.13"},
+		Maintenance:     true,
+		MaintenancePage: "/etc/gophercaptain/maintenance.html",
+		RetryAfter:      60,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, "http-request return status 503 hdr Retry-After 60 content-type text/html file /etc/gophercaptain/maintenance.html") {
+		t.Errorf("expected maintenance return:\n%s", content)
+	}
+	if strings.Contains(content, "deny") {
+		t.Errorf("maintenance should replace access rules:\n%s", content)
+	}
+}
+
+func TestRenderBackendUnsupported(t *testing.T) {
+	base := nginx.RouteParams{Name: "api", Routes: []nginx.Route{{Type: "subdomain", Value: "api.example.com"}}, Port: 3000}
+	tests := []struct {
+		name string
+		set  func(*nginx.RouteParams)
+		want string
+	}{
+		{"acme", func(p *nginx.RouteParams) { p.ACMEWebroot = "/var/lib/gophercaptain/acme" }, "ACME"},
+		{"grpc", func(p *nginx.RouteParams) { p.Proto = "grpc" }, "gRPC"},
+		{"auth", func(p *nginx.RouteParams) { p.AuthFile = "/etc/gophercaptain/htpasswd/api" }, "basic auth"},
+		{"rate", func(p *nginx.RouteParams) { p.RateLimit = "10r/s" }, "rate limits"},
+		{"body", func(p *nginx.RouteParams) { p.MaxBodySize = "10m" }, "body size"},
+	}
+	for _, tt := range tests {
+		p := base
+		tt.set(&p)
+		if _, err := RenderBackend(p); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected %q error, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	p := base
+	p.MaxBodySize = "0"
+	if _, err := RenderBackend(p); err != nil {
+		t.Errorf("unlimited body size should be accepted: %v", err)
+	}
+}
+
+func TestCheckFailureRestoresPrevious(t *testing.T) {
+	dir := t.TempDir()
+	fake := runner.NewFakeRunner()
+	mgr := New(fake, "/etc/haproxy/haproxy.cfg", dir)
+	ctx := context.Background()
+
+	params := nginx.RouteParams{Name: "api", Routes: []nginx.Route{{Type: "subdomain", Value: "api.example.com"}}, Port: 3000}
+	if err := mgr.WriteConfig(ctx, params); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(mgr.ConfigPath())
+
+	fake.SetResponse("haproxy -c -f /etc/haproxy/haproxy.cfg -f "+mgr.ConfigPath(), runner.Response{
+		Stderr: "[ALERT] parsing error",
+		Err:    fmt.Errorf("exit status 1"),
+	})
+	params.Routes = []nginx.Route{{Type: "subdomain", Value: "api.example.org"}}
+	err := mgr.WriteConfig(ctx, params)
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("expected rollback error, got %v", err)
+	}
+	after, _ := os.ReadFile(mgr.ConfigPath())
+	if string(after) != string(before) {
+		t.Errorf("config not restored:\n%s", after)
+	}
+}
@@ -0,0 +1,195 @@
+// Package haproxy serves routes through HAProxy instead of nginx. HAProxy
+// takes one frontend per port, so the per-service parts are assembled into
+// a single config file that haproxy loads next to its main one.
+package haproxy
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/ecairns22/GopherCaptain/internal/nginx"
+)
+
+// backendTemplate forwards to the instances of a service.
+const backendTemplate = `backend gc-{{.Name}}
+    mode http
+    balance roundrobin
+    option forwardfor
+    http-request set-header X-Forwarded-Proto https if { ssl_fc }
+    http-request set-header X-Forwarded-Proto http unless { ssl_fc }
+{{- range .Directives}}
+    {{.}}
+{{- end}}
+{{- range .Servers}}
+    server gc-{{$.Name}}-{{.}} 127.0.0.1:{{.}}
+{{- end}}
+`
+
+// configTemplate routes to every service's backend by Host header and,
+// for path routes, path prefix. Hosts with a certificate redirect to the
+// HTTPS frontend, which loads every certificate in the certs directory.
+const configTemplate = `# Managed by GopherCaptain; regenerated whenever a service's routes change
+frontend gophercaptain-http
+    mode http
+    bind :80
+{{- range .Sites}}{{if .TLS}}
+    http-request redirect scheme https code 301 if { hdr(host),field(1,:) -i {{join .Domains " "}} }
+{{- end}}{{end}}
+{{- range .Rules}}
+    {{.}}
+{{- end}}
+{{- if .TLS}}
+
+frontend gophercaptain-https
+    mode http
+    bind :443 ssl crt {{.CertDir}}
+{{- range .TLSRules}}
+    {{.}}
+{{- end}}
+{{- end}}
+{{range .Backends}}
+{{.}}{{end}}`
+
+var (
+	funcs                 = template.FuncMap{"join": strings.Join}
+	parsedBackendTemplate = template.Must(template.New("backend").Parse(backendTemplate))
+	parsedConfigTemplate  = template.Must(template.New("config").Funcs(funcs).Parse(configTemplate))
+)
+
+// Site is what the frontends need to know about a service's routes.
+type Site struct {
+	Name   string
+	Routes []nginx.Route
+	TLS    bool // subdomain routes have a certificate
+}
+
+// Domains returns the hosts of the subdomain routes.
+func (s Site) Domains() []string {
+	return nginx.RouteParams{Routes: s.Routes}.Domains()
+}
+
+// RenderBackend renders the backend section of a service. Features HAProxy
+// cannot provide from the state GopherCaptain keeps are refused rather
+// than silently dropped: basic auth (HAProxy's userlists rely on the
+// system crypt(3), which lacks $apr1$), rate limits, body size limits,
+// gRPC, and ACME, whose challenges HAProxy cannot serve from a webroot.
+func RenderBackend(p nginx.RouteParams) (string, error) {
+	for _, r := range p.Routes {
+		if r.Type != "subdomain" && r.Type != "path" {
+			return "", fmt.Errorf("unknown route type %q; must be 'subdomain' or 'path'", r.Type)
+		}
+	}
+	if err := nginx.ValidateProxyOptions(p.Proto, p.Keepalive, p.ReadTimeout, p.MaxBodySize); err != nil {
+		return "", err
+	}
+	switch {
+	case p.ACMEWebroot != "":
+		return "", fmt.Errorf("ACME certificates are not supported by the haproxy proxy; use --tls-cert and --tls-key")
+	case p.TLS() && len(p.Domains()) == 0:
+		return "", fmt.Errorf("TLS is only supported for subdomain routes")
+	case p.Proto == "grpc":
+		return "", fmt.Errorf("gRPC routes are not supported by the haproxy proxy")
+	case p.AuthFile != "":
+		return "", fmt.Errorf("basic auth is not supported by the haproxy proxy")
+	case p.RateLimit != "":
+		return "", fmt.Errorf("rate limits are not supported by the haproxy proxy")
+	case strings.TrimLeft(p.MaxBodySize, "0") != "":
+		return "", fmt.Errorf("body size limits are not supported by the haproxy proxy")
+	}
+
+	var lines []string
+	if p.Maintenance {
+		line := fmt.Sprintf("http-request return status 503 hdr Retry-After %d", p.RetryAfter)
+		if p.MaintenancePage != "" {
+			line += " content-type text/html file " + p.MaintenancePage
+		}
+		lines = append(lines, line)
+	} else {
+		for _, cidr := range p.Deny {
+			lines = append(lines, "http-request deny deny_status 403 if { src "+cidr+" }")
+		}
+		if len(p.Allow) > 0 {
+			lines = append(lines, "http-request deny deny_status 403 unless { src "+strings.Join(p.Allow, " ")+" }")
+		}
+	}
+	if p.ReadTimeout != "" {
+		lines = append(lines, "timeout server "+duration(p.ReadTimeout))
+		if p.Proto == "websocket" {
+			lines = append(lines, "timeout tunnel "+duration(p.ReadTimeout))
+		}
+	}
+
+	var buf bytes.Buffer
+	data := struct {
+		Name       string
+		Directives []string
+		Servers    []int
+	}{p.Name, lines, p.Servers()}
+	if err := parsedBackendTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering haproxy backend: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// RenderConfig assembles the frontends for sites, followed by their
+// backends in the same order. Path rules come first, longest prefix first,
+// so they win over a subdomain route on the same host.
+func RenderConfig(sites []Site, backends []string, certDir string) (string, error) {
+	type rule struct {
+		path string
+		line string
+	}
+	var rules, tlsRules []rule
+	tls := false
+	for _, s := range sites {
+		host := "{ hdr(host),field(1,:) -i %s }"
+		if domains := s.Domains(); len(domains) > 0 {
+			r := rule{line: fmt.Sprintf("use_backend gc-%s if "+host, s.Name, strings.Join(domains, " "))}
+			if s.TLS {
+				tls = true
+				tlsRules = append(tlsRules, r)
+			} else {
+				rules = append(rules, r)
+			}
+		}
+		for _, r := range s.Routes {
+			if r.Type == "path" {
+				rules = append(rules, rule{r.Path(), fmt.Sprintf("use_backend gc-%s if "+host+" { path_beg %s }", s.Name, r.Host(), r.Path())})
+			}
+		}
+	}
+	lines := func(rules []rule) []string {
+		sort.SliceStable(rules, func(i, j int) bool { return len(rules[i].path) > len(rules[j].path) })
+		out := make([]string, len(rules))
+		for i, r := range rules {
+			out[i] = r.line
+		}
+		return out
+	}
+
+	var buf bytes.Buffer
+	data := struct {
+		Sites    []Site
+		Rules    []string
+		TLS      bool
+		TLSRules []string
+		CertDir  string
+		Backends []string
+	}{sites, lines(rules), tls, lines(tlsRules), certDir, backends}
+	if err := parsedConfigTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering haproxy config: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// duration converts an nginx time, whose default unit is seconds, to an
+// HAProxy one, whose default unit is milliseconds.
+func duration(t string) string {
+	if strings.TrimLeft(t, "0123456789") == "" {
+		return t + "s"
+	}
+	return t
+}
@@ -0,0 +1,58 @@
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// RouteProbe is the response to a request sent through the local proxy.
+type RouteProbe struct {
+	Status  int
+	Latency time.Duration // until the response headers arrived
+	Header  http.Header
+}
+
+// ProbeRoute requests url from the proxy listening on this host, wherever
+// the URL's host resolves, so the proxy picks the route by its Host header
+// (and SNI over https) as it would for a real client. Redirects are not
+// followed and certificates are not verified: the probe checks routing,
+// not the certificate.
+func ProbeRoute(ctx context.Context, url string, timeout time.Duration) (*RouteProbe, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				_, port, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, err
+				}
+				return dialer.DialContext(ctx, network, net.JoinHostPort("127.0.0.1", port))
+			},
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	latency := time.Since(start)
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	return &RouteProbe{Status: resp.StatusCode, Latency: latency, Header: resp.Header}, nil
+}
@@ -0,0 +1,39 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestProbeRoute(t *testing.T) {
+	var gotHost, gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost, gotPath = r.Host, r.URL.Path
+		w.Header().Set("X-GopherCaptain-Upstream", "127.0.0.1:3000")
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	// The route's host does not resolve; the probe still goes to this host
+	probe, err := ProbeRoute(context.Background(), "http://api.example.invalid:"+u.Port()+"/api", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotHost != "api.example.invalid:"+u.Port() || gotPath != "/api" {
+		t.Errorf("request went to %s%s", gotHost, gotPath)
+	}
+	if probe.Status != http.StatusFound {
+		t.Errorf("status = %d, want the redirect itself", probe.Status)
+	}
+	if probe.Header.Get("X-GopherCaptain-Upstream") != "127.0.0.1:3000" {
+		t.Errorf("header = %v", probe.Header)
+	}
+	if probe.Latency <= 0 {
+		t.Errorf("latency = %v", probe.Latency)
+	}
+}
@@ -0,0 +1,99 @@
+package nginx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ServerName is a server_name found in a server block GopherCaptain does
+// not manage.
+type ServerName struct {
+	Name string
+	File string
+}
+
+// Conflict reports whether nginx would serve two routes ambiguously: both
+// claim the same host in separate server blocks, which nginx resolves by
+// picking one, or they are path routes with the same location on one host.
+// Nested paths such as /api and /api/v2 are fine, since nginx sends each
+// request to the longest matching prefix.
+func Conflict(a, b Route) bool {
+	if !strings.EqualFold(a.Host(), b.Host()) {
+		return false
+	}
+	if a.Type != "path" || b.Type != "path" {
+		// Only path routes share their host's server block
+		return true
+	}
+	return a.Path() == b.Path()
+}
+
+// ForeignServerNames returns the server names of enabled sites that
+// GopherCaptain did not write, in file order. The catch-all "_" and regex
+// names are skipped.
+func (m *Manager) ForeignServerNames() []ServerName {
+	entries, err := os.ReadDir(m.enabledDir)
+	if err != nil {
+		return nil
+	}
+	var names []ServerName
+	for _, e := range entries {
+		file := e.Name()
+		if strings.HasPrefix(file, "gc-") || strings.HasPrefix(file, "gophercaptain-host-") {
+			continue
+		}
+		path := filepath.Join(m.enabledDir, file)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, name := range parseServerNames(string(data)) {
+			names = append(names, ServerName{Name: name, File: path})
+		}
+	}
+	return names
+}
+
+// parseServerNames returns the arguments of every server_name directive in
+// an nginx config.
+func parseServerNames(config string) []string {
+	var b strings.Builder
+	for _, line := range strings.Split(config, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		b.WriteString(line + "\n")
+	}
+	r := strings.NewReplacer(";", " ; ", "{", " { ", "}", " } ")
+
+	var names []string
+	in := false
+	for _, tok := range strings.Fields(r.Replace(b.String())) {
+		switch {
+		case tok == "server_name":
+			in = true
+		case !in:
+		case tok == ";" || tok == "{" || tok == "}":
+			in = false
+		case tok == "_" || tok == `""` || strings.HasPrefix(tok, "~"):
+		default:
+			names = append(names, strings.ToLower(tok))
+		}
+	}
+	return names
+}
+
+// MatchServerName reports whether nginx would serve host from a server
+// block with the given server_name: exactly, by a leading "*." or "."
+// wildcard, or by a trailing ".*" wildcard.
+func MatchServerName(name, host string) bool {
+	name, host = strings.ToLower(name), strings.ToLower(host)
+	switch {
+	case strings.HasPrefix(name, "*."):
+		return strings.HasSuffix(host, name[1:])
+	case strings.HasPrefix(name, "."):
+		return host == name[1:] || strings.HasSuffix(host, name)
+	case strings.HasSuffix(name, ".*"):
+		return strings.HasPrefix(host, name[:len(name)-1])
+	}
+	return name == host
+}
@@ -0,0 +1,90 @@
+package nginx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+)
+
+func TestConflict(t *testing.T) {
+	tests := []struct {
+		a, b Route
+		want bool
+	}{
+		{Route{"subdomain", "api.example.com"}, Route{"subdomain", "api.example.com"}, true},
+		{Route{"subdomain", "api.example.com"}, Route{"subdomain", "API.example.com"}, true},
+		{Route{"subdomain", "api.example.com"}, Route{"subdomain", "www.example.com"}, false},
+		{Route{"subdomain", "example.com"}, Route{"path", "example.com/api"}, true},
+		{Route{"path", "example.com/api"}, Route{"path", "example.com/api"}, true},
+		{Route{"path", "example.com/api"}, Route{"path", "example.com/api/v2"}, false},
+		{Route{"path", "example.com/apiv2"}, Route{"path", "example.com/api"}, false},
+		{Route{"path", "example.com/api"}, Route{"path", "example.com/apidocs"}, false},
+		{Route{"path", "example.com/api"}, Route{"path", "example.com/api/"}, false},
+		{Route{"path", "example.com/api"}, Route{"path", "example.com/auth"}, false},
+		{Route{"path", "example.com/api"}, Route{"path", "example.org/api"}, false},
+	}
+	for _, tt := range tests {
+		if got := Conflict(tt.a, tt.b); got != tt.want {
+			t.Errorf("Conflict(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := Conflict(tt.b, tt.a); got != tt.want {
+			t.Errorf("Conflict(%v, %v) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestMatchServerName(t *testing.T) {
+	tests := []struct {
+		name, host string
+		want       bool
+	}{
+		{"api.example.com", "api.example.com", true},
+		{"api.example.com", "www.example.com", false},
+		{"*.example.com", "api.example.com", true},
+		{"*.example.com", "example.com", false},
+		{".example.com", "example.com", true},
+		{".example.com", "api.example.com", true},
+		{".example.com", "badexample.com", false},
+		{"www.example.*", "www.example.org", true},
+		{"www.example.*", "api.example.org", false},
+	}
+	for _, tt := range tests {
+		if got := MatchServerName(tt.name, tt.host); got != tt.want {
+			t.Errorf("MatchServerName(%q, %q) = %v, want %v", tt.name, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestForeignServerNames(t *testing.T) {
+	enabled := t.TempDir()
+	m := New(runner.NewFakeRunner(), t.TempDir(), enabled, t.TempDir())
+
+	foreign := `# www.ignored.com in a comment
+server {
+    listen 80 default_server;
+    server_name _;
+}
+
+server {
+    listen 80;
+    server_name example.com www.example.com;
+    server_name ~^shop\d+\.example\.com$;
+}
+`
+	os.WriteFile(filepath.Join(enabled, "default"), []byte(foreign), 0644)
+	os.WriteFile(filepath.Join(enabled, "gc-api.conf"), []byte("server { server_name api.example.com; }"), 0644)
+	os.WriteFile(filepath.Join(enabled, "gophercaptain-host-example.org.conf"), []byte("server { server_name example.org; }"), 0644)
+
+	names := m.ForeignServerNames()
+	want := []string{"example.com", "www.example.com"}
+	if len(names) != len(want) {
+		t.Fatalf("got %v, want %v", names, want)
+	}
+	for i, n := range names {
+		if n.Name != want[i] || n.File != filepath.Join(enabled, "default") {
+			t.Errorf("names[%d] = %+v, want %s in default", i, n, want[i])
+		}
+	}
+}
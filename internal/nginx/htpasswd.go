@@ -0,0 +1,146 @@
+package nginx
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// apr1Alphabet is the base-64 alphabet of crypt(3)-style hashes.
+const apr1Alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// HashPassword returns an Apache MD5 ($apr1$) hash of password with a
+// random salt. nginx verifies this scheme itself, so unlike bcrypt it does
+// not depend on what the system crypt(3) supports.
+func HashPassword(password string) (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generating salt: %w", err)
+	}
+	salt := make([]byte, 8)
+	for i, b := range buf {
+		salt[i] = apr1Alphabet[int(b)%len(apr1Alphabet)]
+	}
+	return apr1(password, string(salt)), nil
+}
+
+// apr1 computes the $apr1$ hash of password with an 8-character salt.
+func apr1(password, salt string) string {
+	const magic = "$apr1$"
+
+	alt := md5.Sum([]byte(password + salt + password))
+	ctx := md5.New()
+	ctx.Write([]byte(password + magic + salt))
+	for n := len(password); n > 0; n -= 16 {
+		ctx.Write(alt[:min(n, 16)])
+	}
+	for n := len(password); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write([]byte{password[0]})
+		}
+	}
+	sum := ctx.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		ctx := md5.New()
+		if i&1 != 0 {
+			ctx.Write([]byte(password))
+		} else {
+			ctx.Write(sum)
+		}
+		if i%3 != 0 {
+			ctx.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			ctx.Write([]byte(password))
+		}
+		if i&1 != 0 {
+			ctx.Write(sum)
+		} else {
+			ctx.Write([]byte(password))
+		}
+		sum = ctx.Sum(nil)
+	}
+
+	var out strings.Builder
+	encode := func(a, b, c byte, n int) {
+		v := uint(a)<<16 | uint(b)<<8 | uint(c)
+		for ; n > 0; n-- {
+			out.WriteByte(apr1Alphabet[v&0x3f])
+			v >>= 6
+		}
+	}
+	encode(sum[0], sum[6], sum[12], 4)
+	encode(sum[1], sum[7], sum[13], 4)
+	encode(sum[2], sum[8], sum[14], 4)
+	encode(sum[3], sum[9], sum[15], 4)
+	encode(sum[4], sum[10], sum[5], 4)
+	encode(0, 0, sum[11], 2)
+	return magic + salt + "$" + out.String()
+}
+
+// ValidateAuthUser checks that a user name can be written to an htpasswd file.
+func ValidateAuthUser(name string) error {
+	if name == "" || strings.ContainsAny(name, ": \t\r\n") {
+		return fmt.Errorf("invalid user %q: must be non-empty without colons or whitespace", name)
+	}
+	return nil
+}
+
+// WriteHtpasswd writes user:hash lines to path, sorted by user, replacing
+// the file atomically. The file is readable by nginx's worker group when
+// that group exists, and by no one else but root.
+func WriteHtpasswd(path string, hashes map[string]string) error {
+	users := make([]string, 0, len(hashes))
+	for u := range hashes {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+
+	var b strings.Builder
+	for _, u := range users {
+		fmt.Fprintf(&b, "%s:%s\n", u, hashes[u])
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating htpasswd dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	perm := os.FileMode(0644)
+	gid := workerGroup()
+	if gid >= 0 {
+		perm = 0640
+	}
+	if err := os.WriteFile(tmp, []byte(b.String()), perm); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if gid >= 0 {
+		os.Chown(tmp, -1, gid)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// workerGroup returns the gid nginx workers run as on Debian ("www-data")
+// or Red Hat ("nginx") systems, or -1 if neither exists.
+func workerGroup() int {
+	for _, name := range []string{"www-data", "nginx"} {
+		if g, err := user.LookupGroup(name); err == nil {
+			if gid, err := strconv.Atoi(g.Gid); err == nil {
+				return gid
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,52 @@
+package nginx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPR1(t *testing.T) {
+	// Expected hashes from openssl passwd -apr1 -salt 'xY9./abc'
+	tests := []struct{ password, want string }{
+		{"a", "$apr1$xY9./abc$d057VDs6hdL6Hwn0ClSH//"},
+		{"abcdefghijklmnopqrstu", "$apr1$xY9./abc$.JRQDfQxpgw16QZHfDyw8/"},
+		{"p@ss word", "$apr1$xY9./abc$imcpwoEB0bWSOVKCYbbQW."},
+	}
+	for _, tt := range tests {
+		if got := apr1(tt.password, "xY9./abc"); got != tt.want {
+			t.Errorf("apr1(%q) = %q, want %q", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestHashPasswordSalts(t *testing.T) {
+	a, _ := HashPassword("secret")
+	b, _ := HashPassword("secret")
+	if a == b || !strings.HasPrefix(a, "$apr1$") {
+		t.Errorf("hashes should be salted apr1, got %q and %q", a, b)
+	}
+}
+
+func TestWriteHtpasswd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "htpasswd", "admin")
+	if err := WriteHtpasswd(path, map[string]string{"carol": "h1", "alice": "h2"}); err != nil {
+		t.Fatalf("WriteHtpasswd: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "alice:h2\ncarol:h1\n" {
+		t.Errorf("htpasswd = %q", data)
+	}
+}
+
+func TestValidateAuthUser(t *testing.T) {
+	for _, name := range []string{"", "a:b", "a b"} {
+		if ValidateAuthUser(name) == nil {
+			t.Errorf("ValidateAuthUser(%q) should fail", name)
+		}
+	}
+	if err := ValidateAuthUser("alice"); err != nil {
+		t.Errorf("ValidateAuthUser(alice) = %v", err)
+	}
+}
@@ -5,74 +5,254 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 
 	"github.com/ecairns22/GopherCaptain/internal/runner"
+	"github.com/ecairns22/GopherCaptain/internal/templates"
 )
 
 // Manager handles nginx config lifecycle operations.
 type Manager struct {
-	runner     runner.CommandRunner
-	sitesDir   string
-	enabledDir string
+	runner      runner.CommandRunner
+	sitesDir    string
+	enabledDir  string
+	snippetsDir string // location snippets of path routes, one directory per host
+	templates   *templates.Dir
 }
 
 // New creates an nginx manager.
-func New(r runner.CommandRunner, sitesDir, enabledDir string) *Manager {
+func New(r runner.CommandRunner, sitesDir, enabledDir, snippetsDir string) *Manager {
 	return &Manager{
-		runner:     r,
-		sitesDir:   sitesDir,
-		enabledDir: enabledDir,
+		runner:      r,
+		sitesDir:    sitesDir,
+		enabledDir:  enabledDir,
+		snippetsDir: snippetsDir,
 	}
 }
 
+// WithTemplates makes the manager render with overrides from d where they
+// exist, instead of the built-in templates.
+func (m *Manager) WithTemplates(d *templates.Dir) *Manager {
+	m.templates = d
+	return m
+}
+
+// LogFormat names the JSON log_format of service access logs, which the
+// traffic command reads back.
+const LogFormat = "gophercaptain"
+
+// logFormatName is the file defining LogFormat. It sorts ahead of the
+// service configs that use it.
+const logFormatName = "00-gophercaptain-log-format.conf"
+
+const logFormatConfig = `# Managed by GopherCaptain: the access log format of its services
+log_format ` + LogFormat + ` escape=json '{"time":"$time_iso8601","remote_addr":"$remote_addr","host":"$host",'
+    '"method":"$request_method","uri":"$request_uri","status":$status,"bytes":$body_bytes_sent,'
+    '"request_time":$request_time,"upstream_time":"$upstream_response_time","upstream":"$upstream_addr"}';
+`
+
+// UpstreamHeader names the response header that tells a request from this
+// host which upstream address nginx forwarded it to, so route checks can
+// see they reached the right service. Other clients never get it.
+const UpstreamHeader = "X-GopherCaptain-Upstream"
+
+// upstreamHeaderName is the file defining the header's value, which is
+// empty (so the header is left out) unless the client is local.
+const upstreamHeaderName = "00-gophercaptain-upstream.conf"
+
+const upstreamHeaderConfig = `# Managed by GopherCaptain: reveals the upstream to local route checks only
+map $remote_addr $gophercaptain_upstream {
+    default   "";
+    127.0.0.1 $upstream_addr;
+    ::1       $upstream_addr;
+}
+`
+
+// AccessLogPath is where a service's requests are logged in logDir.
+func AccessLogPath(logDir, name string) string {
+	return filepath.Join(logDir, fmt.Sprintf("gc-%s.access.log", name))
+}
+
 func configName(name string) string {
 	return fmt.Sprintf("gc-%s.conf", name)
 }
 
+// hostConfigName is the shared server file for path routes on a host. The
+// prefix differs from service configs so the two can never collide.
+func hostConfigName(host string) string {
+	return fmt.Sprintf("gophercaptain-host-%s.conf", host)
+}
+
+// snippetPath is where a service's location snippet for a host lives.
+func (m *Manager) snippetPath(host, name string) string {
+	return filepath.Join(m.snippetsDir, host, configName(name))
+}
+
 // WriteConfig renders the nginx config, writes it, creates the enabled symlink,
-// tests with nginx -t, and reloads nginx. On test failure, rolls back the config.
+// tests with nginx -t, and reloads nginx. On test failure the service's
+// previous config, if any, is restored.
+//
+// Path routes get one location snippet per host, and each host's server
+// file is regenerated to include it. Snippets on hosts the service is no
+// longer routed on are removed.
 func (m *Manager) WriteConfig(ctx context.Context, params RouteParams) error {
-	content, err := RenderConfig(params)
+	t, source, err := m.templates.Load(parsedConfigTemplate, params.Name, templates.NginxConfig)
 	if err != nil {
 		return err
 	}
+	content, err := renderConfig(t, source, params)
+	if err != nil {
+		return err
+	}
+
+	locations := make(map[string]string)
+	if len(params.Hosts()) > 0 {
+		t, source, err := m.templates.Load(parsedLocationTemplate, params.Name, templates.NginxLocation)
+		if err != nil {
+			return err
+		}
+		for _, host := range params.Hosts() {
+			if host == "" {
+				path := params.Paths("")[0]
+				return fmt.Errorf("path route %q has no host; use --route example.com%s or set [nginx] default_host", path, path)
+			}
+			if locations[host], err = renderLocation(t, source, params, host); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := m.writeShared(upstreamHeaderName, upstreamHeaderConfig); err != nil {
+		return err
+	}
+	if params.AccessLog != "" {
+		if err := m.writeShared(logFormatName, logFormatConfig); err != nil {
+			return err
+		}
+	}
 
 	filename := configName(params.Name)
 	sitesPath := filepath.Join(m.sitesDir, filename)
 	enabledPath := filepath.Join(m.enabledDir, filename)
 
+	// Keep what is there now so a failed test can put it back
+	previous := make(map[string][]byte)
+	stale, _ := filepath.Glob(filepath.Join(m.snippetsDir, "*", filename))
+	for _, path := range append(stale, sitesPath) {
+		if data, err := os.ReadFile(path); err == nil {
+			previous[path] = data
+		}
+	}
+	hosts := params.Hosts()
+	for _, path := range stale {
+		if host := filepath.Base(filepath.Dir(path)); !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	rollback := func() {
+		os.Remove(enabledPath)
+		os.Remove(sitesPath)
+		for _, path := range stale {
+			os.Remove(path)
+		}
+		for host := range locations {
+			os.Remove(m.snippetPath(host, params.Name))
+		}
+		for path, data := range previous {
+			os.MkdirAll(filepath.Dir(path), 0755)
+			os.WriteFile(path, data, 0644)
+		}
+		if _, ok := previous[sitesPath]; ok {
+			os.Symlink(sitesPath, enabledPath)
+		}
+		for _, host := range hosts {
+			m.syncHost(host)
+		}
+	}
+
 	// Write config to sites-available
 	if err := os.WriteFile(sitesPath, []byte(content), 0644); err != nil {
+		rollback()
 		return fmt.Errorf("writing nginx config %s: %w", sitesPath, err)
 	}
 
 	// Create symlink in sites-enabled
 	os.Remove(enabledPath) // remove stale symlink if any
 	if err := os.Symlink(sitesPath, enabledPath); err != nil {
-		os.Remove(sitesPath)
+		rollback()
 		return fmt.Errorf("creating symlink %s: %w", enabledPath, err)
 	}
 
-	// Test config
+	for _, path := range stale {
+		os.Remove(path)
+	}
+	for _, host := range hosts {
+		var err error
+		if location, ok := locations[host]; ok {
+			path := m.snippetPath(host, params.Name)
+			err = os.MkdirAll(filepath.Dir(path), 0755)
+			if err == nil {
+				err = os.WriteFile(path, []byte(location), 0644)
+			}
+		}
+		if err == nil {
+			err = m.syncHost(host)
+		}
+		if err != nil {
+			rollback()
+			return fmt.Errorf("writing locations for %s on %s: %w", params.Name, host, err)
+		}
+	}
+
+	if err := m.Test(ctx); err != nil {
+		rollback()
+		return fmt.Errorf("%w (config rolled back)", err)
+	}
+	return m.Reload(ctx)
+}
+
+// writeShared writes and enables an http-level file that service configs
+// rely on, unless it is already in place. The file stays once written,
+// since other services use it too.
+func (m *Manager) writeShared(filename, content string) error {
+	sitesPath := filepath.Join(m.sitesDir, filename)
+	enabledPath := filepath.Join(m.enabledDir, filename)
+	if data, err := os.ReadFile(sitesPath); err != nil || string(data) != content {
+		if err := os.WriteFile(sitesPath, []byte(content), 0644); err != nil {
+			return fmt.Errorf("writing nginx config %s: %w", sitesPath, err)
+		}
+	}
+	if _, err := os.Lstat(enabledPath); os.IsNotExist(err) {
+		if err := os.Symlink(sitesPath, enabledPath); err != nil {
+			return fmt.Errorf("creating symlink %s: %w", enabledPath, err)
+		}
+	}
+	return nil
+}
+
+// Test checks the whole nginx config with nginx -t.
+func (m *Manager) Test(ctx context.Context) error {
 	_, stderr, err := m.runner.Run(ctx, "nginx", "-t")
 	if err != nil {
-		// Rollback: remove config and symlink
-		os.Remove(enabledPath)
-		os.Remove(sitesPath)
-		return fmt.Errorf("nginx config test failed (config rolled back): %s", strings.TrimSpace(stderr))
+		return fmt.Errorf("nginx config test failed: %s", strings.TrimSpace(stderr))
 	}
+	return nil
+}
 
-	// Reload nginx
-	_, stderr, err = m.runner.Run(ctx, "systemctl", "reload", "nginx")
+// Reload makes nginx pick up config changes.
+func (m *Manager) Reload(ctx context.Context) error {
+	_, stderr, err := m.runner.Run(ctx, "systemctl", "reload", "nginx")
 	if err != nil {
 		return fmt.Errorf("reloading nginx: %s: %w", strings.TrimSpace(stderr), err)
 	}
-
 	return nil
 }
 
-// RemoveConfig removes the nginx config and symlink, then reloads nginx.
+// RemoveConfig removes the nginx config and symlink, and any location
+// snippets with their hosts' server files regenerated, then reloads nginx.
 func (m *Manager) RemoveConfig(ctx context.Context, name string) error {
 	filename := configName(name)
 	enabledPath := filepath.Join(m.enabledDir, filename)
@@ -81,20 +261,65 @@ func (m *Manager) RemoveConfig(ctx context.Context, name string) error {
 	os.Remove(enabledPath)
 	os.Remove(sitesPath)
 
-	_, stderr, err := m.runner.Run(ctx, "systemctl", "reload", "nginx")
+	snippets, _ := filepath.Glob(filepath.Join(m.snippetsDir, "*", filename))
+	for _, path := range snippets {
+		os.Remove(path)
+		m.syncHost(filepath.Base(filepath.Dir(path)))
+	}
+
+	return m.Reload(ctx)
+}
+
+// syncHost regenerates a host's server file from the location snippets in
+// its directory, or removes it along with the directory once none are left.
+func (m *Manager) syncHost(host string) error {
+	filename := hostConfigName(host)
+	sitesPath := filepath.Join(m.sitesDir, filename)
+	enabledPath := filepath.Join(m.enabledDir, filename)
+
+	includes, err := filepath.Glob(filepath.Join(m.snippetsDir, host, "*.conf"))
+	if err != nil {
+		return err
+	}
+	if len(includes) == 0 {
+		os.Remove(enabledPath)
+		os.Remove(sitesPath)
+		os.Remove(filepath.Join(m.snippetsDir, host))
+		return nil
+	}
+	sort.Strings(includes)
+
+	content, err := RenderHost(host, includes)
 	if err != nil {
-		return fmt.Errorf("reloading nginx after removing %s: %s: %w", name, strings.TrimSpace(stderr), err)
+		return err
+	}
+	if err := os.WriteFile(sitesPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing nginx config %s: %w", sitesPath, err)
+	}
+	if _, err := os.Lstat(enabledPath); os.IsNotExist(err) {
+		if err := os.Symlink(sitesPath, enabledPath); err != nil {
+			return fmt.Errorf("creating symlink %s: %w", enabledPath, err)
+		}
 	}
 	return nil
 }
 
-// InferRouteType returns "subdomain" if value contains a dot, "path" if it starts with "/".
+// SplitRoute splits a route value into its host and path. Subdomain routes
+// are a bare host ("api.example.com"); path routes are a host followed by
+// the path ("example.com/api"), or just the path when recorded without one.
+func SplitRoute(value string) (host, path string) {
+	i := strings.Index(value, "/")
+	if i < 0 {
+		return value, ""
+	}
+	return value[:i], value[i:]
+}
+
+// InferRouteType returns "path" if value contains a "/" (e.g. "/api" or
+// "example.com/api"), and "subdomain" otherwise.
 func InferRouteType(value string) string {
-	if strings.HasPrefix(value, "/") {
+	if strings.Contains(value, "/") {
 		return "path"
 	}
-	if strings.Contains(value, ".") {
-		return "subdomain"
-	}
-	return "subdomain" // default
+	return "subdomain"
 }
@@ -9,19 +9,19 @@ import (
 	"testing"
 
 	"github.com/ecairns22/GopherCaptain/internal/runner"
+	"github.com/ecairns22/GopherCaptain/internal/templates"
 )
 
 func TestSubdomainConfig(t *testing.T) {
 	sitesDir := t.TempDir()
 	enabledDir := t.TempDir()
 	fake := runner.NewFakeRunner()
-	mgr := New(fake, sitesDir, enabledDir)
+	mgr := New(fake, sitesDir, enabledDir, t.TempDir())
 
 	params := RouteParams{
-		Name:       "api",
-		RouteType:  "subdomain",
-		RouteValue: "api.example.com",
-		Port:       3000,
+		Name:   "api",
+		Routes: []Route{{Type: "subdomain", Value: "api.example.com"}},
+		Port:   3000,
 	}
 
 	if err := mgr.WriteConfig(context.Background(), params); err != nil {
@@ -59,27 +59,107 @@ func TestSubdomainConfig(t *testing.T) {
 func TestPathConfig(t *testing.T) {
 	sitesDir := t.TempDir()
 	enabledDir := t.TempDir()
+	snippetsDir := t.TempDir()
 	fake := runner.NewFakeRunner()
-	mgr := New(fake, sitesDir, enabledDir)
+	mgr := New(fake, sitesDir, enabledDir, snippetsDir)
 
 	params := RouteParams{
-		Name:       "auth",
-		RouteType:  "path",
-		RouteValue: "/auth",
-		Port:       3001,
+		Name:   "auth",
+		Routes: []Route{{Type: "path", Value: "example.com/auth"}},
+		Port:   3001,
 	}
 
 	if err := mgr.WriteConfig(context.Background(), params); err != nil {
 		t.Fatalf("WriteConfig: %v", err)
 	}
 
+	// The service's own file holds no location at http level
 	data, _ := os.ReadFile(filepath.Join(sitesDir, "gc-auth.conf"))
+	if strings.Contains(string(data), "location") {
+		t.Errorf("gc-auth.conf should not contain a location, got:\n%s", data)
+	}
+
+	snippet := filepath.Join(snippetsDir, "example.com", "gc-auth.conf")
+	data, _ = os.ReadFile(snippet)
 	content := string(data)
 	if !strings.Contains(content, "location /auth") {
-		t.Error("config should contain location /auth")
+		t.Error("snippet should contain location /auth")
 	}
 	if !strings.Contains(content, "proxy_pass http://127.0.0.1:3001") {
-		t.Error("config should contain proxy_pass with port 3001")
+		t.Error("snippet should contain proxy_pass with port 3001")
+	}
+
+	data, _ = os.ReadFile(filepath.Join(sitesDir, "gophercaptain-host-example.com.conf"))
+	host := string(data)
+	if !strings.Contains(host, "server_name example.com;") || !strings.Contains(host, "include "+snippet+";") {
+		t.Errorf("host server should include the snippet, got:\n%s", host)
+	}
+	if _, err := os.Lstat(filepath.Join(enabledDir, "gophercaptain-host-example.com.conf")); err != nil {
+		t.Errorf("host server should be enabled: %v", err)
+	}
+}
+
+func TestPathRoutesShareHost(t *testing.T) {
+	sitesDir := t.TempDir()
+	enabledDir := t.TempDir()
+	snippetsDir := t.TempDir()
+	fake := runner.NewFakeRunner()
+	mgr := New(fake, sitesDir, enabledDir, snippetsDir)
+	ctx := context.Background()
+
+	for i, name := range []string{"api", "auth"} {
+		params := RouteParams{Name: name, Routes: []Route{{Type: "path", Value: "example.com/" + name}}, Port: 3000 + i}
+		if err := mgr.WriteConfig(ctx, params); err != nil {
+			t.Fatalf("WriteConfig %s: %v", name, err)
+		}
+	}
+
+	hostPath := filepath.Join(sitesDir, "gophercaptain-host-example.com.conf")
+	data, _ := os.ReadFile(hostPath)
+	if strings.Count(string(data), "include ") != 2 || strings.Count(string(data), "server {") != 1 {
+		t.Errorf("host should have one server including both routes, got:\n%s", data)
+	}
+
+	mgr.RemoveConfig(ctx, "api")
+	data, _ = os.ReadFile(hostPath)
+	if strings.Contains(string(data), "gc-api.conf") || !strings.Contains(string(data), "gc-auth.conf") {
+		t.Errorf("host should only include auth after removing api, got:\n%s", data)
+	}
+
+	mgr.RemoveConfig(ctx, "auth")
+	if _, err := os.Stat(hostPath); !os.IsNotExist(err) {
+		t.Error("host server should be removed with its last route")
+	}
+	if _, err := os.Lstat(filepath.Join(enabledDir, "gophercaptain-host-example.com.conf")); !os.IsNotExist(err) {
+		t.Error("host server symlink should be removed with its last route")
+	}
+}
+
+func TestPathRouteNeedsHost(t *testing.T) {
+	mgr := New(runner.NewFakeRunner(), t.TempDir(), t.TempDir(), t.TempDir())
+	err := mgr.WriteConfig(context.Background(), RouteParams{Name: "auth", Routes: []Route{{Type: "path", Value: "/auth"}}, Port: 3001})
+	if err == nil || !strings.Contains(err.Error(), "default_host") {
+		t.Errorf("expected missing host error, got %v", err)
+	}
+}
+
+func TestPathTestFailureRestoresHost(t *testing.T) {
+	sitesDir := t.TempDir()
+	enabledDir := t.TempDir()
+	snippetsDir := t.TempDir()
+	fake := runner.NewFakeRunner()
+	mgr := New(fake, sitesDir, enabledDir, snippetsDir)
+	ctx := context.Background()
+
+	mgr.WriteConfig(ctx, RouteParams{Name: "api", Routes: []Route{{Type: "path", Value: "example.com/api"}}, Port: 3000})
+	fake.SetResponse("nginx -t", runner.Response{Stderr: "duplicate location", Err: fmt.Errorf("exit status 1")})
+
+	if err := mgr.WriteConfig(ctx, RouteParams{Name: "auth", Routes: []Route{{Type: "path", Value: "example.com/api"}}, Port: 3001}); err == nil {
+		t.Fatal("expected error for failed nginx -t")
+	}
+	data, _ := os.ReadFile(filepath.Join(sitesDir, "gophercaptain-host-example.com.conf"))
+	if strings.Contains(string(data), "gc-auth.conf") || !strings.Contains(string(data), "gc-api.conf") {
+		t.Errorf("host should be restored without the failed route, got:\n%s", data)
 	}
 }
 
@@ -91,13 +171,12 @@ func TestTestFailureRollback(t *testing.T) {
 		Stderr: "nginx: configuration file syntax is invalid",
 		Err:    fmt.Errorf("exit status 1"),
 	})
-	mgr := New(fake, sitesDir, enabledDir)
+	mgr := New(fake, sitesDir, enabledDir, t.TempDir())
 
 	params := RouteParams{
-		Name:       "bad",
-		RouteType:  "subdomain",
-		RouteValue: "bad.example.com",
-		Port:       3002,
+		Name:   "bad",
+		Routes: []Route{{Type: "subdomain", Value: "bad.example.com"}},
+		Port:   3002,
 	}
 
 	err := mgr.WriteConfig(context.Background(), params)
@@ -121,7 +200,7 @@ func TestRemoveConfig(t *testing.T) {
 	sitesDir := t.TempDir()
 	enabledDir := t.TempDir()
 	fake := runner.NewFakeRunner()
-	mgr := New(fake, sitesDir, enabledDir)
+	mgr := New(fake, sitesDir, enabledDir, t.TempDir())
 
 	// Create files to remove
 	sitesPath := filepath.Join(sitesDir, "gc-api.conf")
@@ -152,6 +231,7 @@ func TestInferRouteType(t *testing.T) {
 		{"api.example.com", "subdomain"},
 		{"/api", "path"},
 		{"/auth/v2", "path"},
+		{"example.com/api", "path"},
 		{"sub.domain.io", "subdomain"},
 	}
 	for _, tt := range tests {
@@ -166,10 +246,9 @@ func TestInferRouteType(t *testing.T) {
 
 func TestRenderSubdomainOutput(t *testing.T) {
 	content, err := RenderConfig(RouteParams{
-		Name:       "myapi",
-		RouteType:  "subdomain",
-		RouteValue: "myapi.example.com",
-		Port:       3000,
+		Name:   "myapi",
+		Routes: []Route{{Type: "subdomain", Value: "myapi.example.com"}},
+		Port:   3000,
 	})
 	if err != nil {
 		t.Fatal(err)
@@ -183,12 +262,11 @@ func TestRenderSubdomainOutput(t *testing.T) {
 }
 
 func TestRenderPathOutput(t *testing.T) {
-	content, err := RenderConfig(RouteParams{
-		Name:       "myapi",
-		RouteType:  "path",
-		RouteValue: "/api",
-		Port:       3000,
-	})
+	content, err := RenderLocation(RouteParams{
+		Name:   "myapi",
+		Routes: []Route{{Type: "path", Value: "example.com/api"}},
+		Port:   3000,
+	}, "example.com")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -200,3 +278,580 @@ func TestRenderPathOutput(t *testing.T) {
 		t.Error("path template should not contain listen directive")
 	}
 }
+
+func TestRenderPathUpstream(t *testing.T) {
+	params := RouteParams{
+		Name:   "myapi",
+		Routes: []Route{{Type: "path", Value: "example.com/api"}},
+		Port:   3000,
+		Ports:  []int{3000, 3004},
+	}
+	config, err := RenderConfig(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(config, "upstream gc-myapi {") || strings.Contains(config, "location") {
+		t.Errorf("path config should hold only the upstream, got:\n%s", config)
+	}
+	location, err := RenderLocation(params, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(location, "upstream") || !strings.Contains(location, "proxy_pass http://gc-myapi;") {
+		t.Errorf("location should proxy to the upstream, got:\n%s", location)
+	}
+}
+
+func TestRenderUpstreamForInstances(t *testing.T) {
+	content, err := RenderConfig(RouteParams{
+		Name:   "myapi",
+		Routes: []Route{{Type: "subdomain", Value: "myapi.example.com"}},
+		Port:   3000,
+		Ports:  []int{3000, 3004, 3005},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "upstream gc-myapi {\n    server 127.0.0.1:3000;\n    server 127.0.0.1:3004;\n    server 127.0.0.1:3005;\n}\n"
+	if !strings.HasPrefix(content, want) {
+		t.Errorf("config should start with the upstream block, got:\n%s", content)
+	}
+	if !strings.Contains(content, "proxy_pass http://gc-myapi;") {
+		t.Error("should proxy to the upstream")
+	}
+}
+
+func TestRenderSingleInstanceHasNoUpstream(t *testing.T) {
+	params := RouteParams{
+		Name:   "myapi",
+		Routes: []Route{{Type: "path", Value: "example.com/api"}},
+		Port:   3000,
+	}
+	content, err := RenderConfig(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(content, "upstream") {
+		t.Error("single-instance config should not have an upstream")
+	}
+	content, _ = RenderLocation(params, "example.com")
+	if !strings.Contains(content, "proxy_pass http://127.0.0.1:3000;") {
+		t.Error("should proxy straight to the port")
+	}
+}
+
+func TestRenderTLS(t *testing.T) {
+	content, err := RenderConfig(RouteParams{
+		Name:        "myapi",
+		Routes:      []Route{{Type: "subdomain", Value: "myapi.example.com"}},
+		Port:        3000,
+		TLSCert:     "/etc/gophercaptain/certs/myapi.example.com/fullchain.pem",
+		TLSKey:      "/etc/gophercaptain/certs/myapi.example.com/privkey.pem",
+		ACMEWebroot: "/var/lib/gophercaptain/acme",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"listen 443 ssl http2;",
+		"ssl_certificate     /etc/gophercaptain/certs/myapi.example.com/fullchain.pem;",
+		"ssl_certificate_key /etc/gophercaptain/certs/myapi.example.com/privkey.pem;",
+		`add_header Strict-Transport-Security "max-age=31536000" always;`,
+		"return 301 https://$host$request_uri;",
+		"location /.well-known/acme-challenge/ {\n        root /var/lib/gophercaptain/acme;",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("config should contain %q, got:\n%s", want, content)
+		}
+	}
+
+	// The redirect must not swallow challenges, and only the HTTPS server proxies
+	if strings.Count(content, "proxy_pass") != 1 {
+		t.Errorf("expected a single proxy_pass, got:\n%s", content)
+	}
+	if strings.Index(content, "acme-challenge") > strings.Index(content, "return 301") {
+		t.Error("challenge location should precede the redirect")
+	}
+}
+
+func TestRenderTLSRejectsPathRoutes(t *testing.T) {
+	_, err := RenderConfig(RouteParams{
+		Name:    "myapi",
+		Routes:  []Route{{Type: "path", Value: "/api"}},
+		Port:    3000,
+		TLSCert: "/c/fullchain.pem",
+		TLSKey:  "/c/privkey.pem",
+	})
+	if err == nil {
+		t.Error("expected TLS on a path route to fail")
+	}
+}
+
+func TestRenderAliases(t *testing.T) {
+	content, err := RenderConfig(RouteParams{
+		Name: "myapi",
+		Routes: []Route{
+			{Type: "subdomain", Value: "api.example.com"},
+			{Type: "subdomain", Value: "api.example.org"},
+			{Type: "path", Value: "example.com/api"},
+		},
+		Port: 3000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, "server_name api.example.com api.example.org;") {
+		t.Errorf("aliases should share one server_name, got:\n%s", content)
+	}
+	if strings.Count(content, "server {") != 1 || strings.Contains(content, "location /api") {
+		t.Errorf("expected one server and no path location, got:\n%s", content)
+	}
+}
+
+func TestMultipleRoutes(t *testing.T) {
+	sitesDir := t.TempDir()
+	enabledDir := t.TempDir()
+	snippetsDir := t.TempDir()
+	mgr := New(runner.NewFakeRunner(), sitesDir, enabledDir, snippetsDir)
+	ctx := context.Background()
+
+	params := RouteParams{
+		Name: "api",
+		Routes: []Route{
+			{Type: "subdomain", Value: "api.example.com"},
+			{Type: "path", Value: "example.com/api"},
+			{Type: "path", Value: "example.com/v1"},
+			{Type: "path", Value: "example.org/api"},
+		},
+		Port: 3000,
+	}
+	if err := mgr.WriteConfig(ctx, params); err != nil {
+		t.Fatalf("WriteConfig: %v", err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(snippetsDir, "example.com", "gc-api.conf"))
+	if !strings.Contains(string(data), "location /api") || !strings.Contains(string(data), "location /v1") {
+		t.Errorf("snippet should hold both paths on the host, got:\n%s", data)
+	}
+	for _, host := range []string{"example.com", "example.org"} {
+		if _, err := os.Stat(filepath.Join(sitesDir, "gophercaptain-host-"+host+".conf")); err != nil {
+			t.Errorf("host server for %s should exist: %v", host, err)
+		}
+	}
+
+	// Dropping a host removes this service's snippet and the then-empty host
+	params.Routes = params.Routes[:2]
+	if err := mgr.WriteConfig(ctx, params); err != nil {
+		t.Fatalf("WriteConfig: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(sitesDir, "gophercaptain-host-example.org.conf")); !os.IsNotExist(err) {
+		t.Error("host server for example.org should be removed")
+	}
+	data, _ = os.ReadFile(filepath.Join(snippetsDir, "example.com", "gc-api.conf"))
+	if strings.Contains(string(data), "location /v1") {
+		t.Errorf("snippet should only hold /api now, got:\n%s", data)
+	}
+}
+
+func TestTestFailureRestoresPrevious(t *testing.T) {
+	sitesDir := t.TempDir()
+	enabledDir := t.TempDir()
+	snippetsDir := t.TempDir()
+	fake := runner.NewFakeRunner()
+	mgr := New(fake, sitesDir, enabledDir, snippetsDir)
+	ctx := context.Background()
+
+	before := RouteParams{Name: "api", Routes: []Route{{Type: "subdomain", Value: "api.example.com"}, {Type: "path", Value: "example.com/api"}}, Port: 3000}
+	if err := mgr.WriteConfig(ctx, before); err != nil {
+		t.Fatalf("WriteConfig: %v", err)
+	}
+	fake.SetResponse("nginx -t", runner.Response{Stderr: "bad", Err: fmt.Errorf("exit status 1")})
+
+	after := RouteParams{Name: "api", Routes: []Route{{Type: "subdomain", Value: "api.example.org"}}, Port: 3000}
+	if err := mgr.WriteConfig(ctx, after); err == nil {
+		t.Fatal("expected error for failed nginx -t")
+	}
+	data, _ := os.ReadFile(filepath.Join(sitesDir, "gc-api.conf"))
+	if !strings.Contains(string(data), "server_name api.example.com;") {
+		t.Errorf("previous config should be restored, got:\n%s", data)
+	}
+	if _, err := os.Lstat(filepath.Join(enabledDir, "gc-api.conf")); err != nil {
+		t.Errorf("previous config should stay enabled: %v", err)
+	}
+	data, _ = os.ReadFile(filepath.Join(sitesDir, "gophercaptain-host-example.com.conf"))
+	if !strings.Contains(string(data), "gc-api.conf") {
+		t.Errorf("previous path route should be restored, got:\n%s", data)
+	}
+}
+
+func TestRenderWebSocket(t *testing.T) {
+	content, err := RenderConfig(RouteParams{
+		Name:        "chat",
+		Routes:      []Route{{Type: "subdomain", Value: "chat.example.com"}},
+		Port:        3000,
+		Proto:       "websocket",
+		ReadTimeout: "3600s",
+		MaxBodySize: "50m",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"proxy_http_version 1.1;",
+		"proxy_set_header Upgrade $http_upgrade;",
+		`proxy_set_header Connection "upgrade";`,
+		"proxy_read_timeout 3600s;",
+		"client_max_body_size 50m;",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("config should contain %q, got:\n%s", want, content)
+		}
+	}
+}
+
+func TestRenderGRPC(t *testing.T) {
+	content, err := RenderLocation(RouteParams{
+		Name:        "rpc",
+		Routes:      []Route{{Type: "path", Value: "example.com/rpc.Greeter"}},
+		Port:        3000,
+		Proto:       "grpc",
+		ReadTimeout: "1h",
+	}, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, "grpc_pass grpc://127.0.0.1:3000;") || !strings.Contains(content, "grpc_read_timeout 1h;") {
+		t.Errorf("location should use grpc_pass, got:\n%s", content)
+	}
+	if strings.Contains(content, "proxy_pass") {
+		t.Errorf("gRPC location should not use proxy directives, got:\n%s", content)
+	}
+}
+
+func TestRenderKeepalive(t *testing.T) {
+	content, err := RenderConfig(RouteParams{
+		Name:      "myapi",
+		Routes:    []Route{{Type: "subdomain", Value: "myapi.example.com"}},
+		Port:      3000,
+		Keepalive: 16,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"upstream gc-myapi {\n    server 127.0.0.1:3000;\n    keepalive 16;\n}",
+		"proxy_pass http://gc-myapi;",
+		"proxy_http_version 1.1;",
+		`proxy_set_header Connection "";`,
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("config should contain %q, got:\n%s", want, content)
+		}
+	}
+}
+
+func TestValidateProxyOptions(t *testing.T) {
+	tests := []struct {
+		proto, timeout, size string
+		keepalive            int
+		ok                   bool
+	}{
+		{"", "", "", 0, true},
+		{"websocket", "60s", "10m", 8, true},
+		{"grpc", "1h", "0", 0, true},
+		{"h3", "", "", 0, false},
+		{"http", "60 s", "", 0, false},
+		{"http", "", "10mb", 0, false},
+		{"http", "", "", -1, false},
+	}
+	for _, tt := range tests {
+		err := ValidateProxyOptions(tt.proto, tt.keepalive, tt.timeout, tt.size)
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidateProxyOptions(%q, %d, %q, %q) = %v, want ok=%v", tt.proto, tt.keepalive, tt.timeout, tt.size, err, tt.ok)
+		}
+	}
+}
+
+func TestRenderAccess(t *testing.T) {
+	params := RouteParams{
+		Name:      "admin",
+		Routes:    []Route{{Type: "subdomain", Value: "admin.example.com"}, {Type: "path", Value: "example.com/admin"}},
+		Port:      3000,
+		Allow:     []string{"10.0.0.0/8"},
+		Deny:      []string{"10.0.0.13"},
+		AuthFile:  "/etc/gophercaptain/htpasswd/admin",
+		RateLimit: "10r/s",
+		RateBurst: 20,
+	}
+	content, err := RenderConfig(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(content, "limit_req_zone $binary_remote_addr zone=gc-admin:10m rate=10r/s;\n") {
+		t.Errorf("config should declare the rate zone first, got:\n%s", content)
+	}
+	location, err := RenderLocation(params, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, out := range []string{content, location} {
+		want := []string{
+			"deny 10.0.0.13;",
+			"allow 10.0.0.0/8;",
+			"deny all;",
+			`auth_basic "gc-admin";`,
+			"auth_basic_user_file /etc/gophercaptain/htpasswd/admin;",
+			"limit_req zone=gc-admin burst=20 nodelay;",
+			"proxy_pass",
+		}
+		last := -1
+		for _, w := range want {
+			i := strings.Index(out, w)
+			if i < last {
+				t.Errorf("%q missing or out of order in:\n%s", w, out)
+			}
+			last = i
+		}
+	}
+}
+
+func TestRenderMaintenance(t *testing.T) {
+	params := RouteParams{
+		Name:            "api",
+		Routes:          []Route{{Type: "subdomain", Value: "api.example.com"}, {Type: "path", Value: "example.com/api"}},
+		Port:            3000,
+		AuthFile:        "/etc/gophercaptain/htpasswd/api",
+		Maintenance:     true,
+		MaintenancePage: "/etc/gophercaptain/maintenance.html",
+		RetryAfter:      30,
+	}
+	content, err := RenderConfig(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	location, err := RenderLocation(params, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, out := range []string{content, location} {
+		for _, want := range []string{
+			"add_header Retry-After 30 always;",
+			"error_page 503 /gc-maintenance/api.html;",
+			"return 503;",
+			"location = /gc-maintenance/api.html {",
+			"internal;",
+			"alias /etc/gophercaptain/maintenance.html;",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("missing %q in:\n%s", want, out)
+			}
+		}
+		for _, unwanted := range []string{"proxy_pass", "auth_basic"} {
+			if strings.Contains(out, unwanted) {
+				t.Errorf("maintenance should not contain %q:\n%s", unwanted, out)
+			}
+		}
+	}
+
+	params.MaintenancePage = ""
+	content, err = RenderConfig(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(content, "error_page") || strings.Contains(content, "gc-maintenance") {
+		t.Errorf("without a page nginx's own 503 should be served:\n%s", content)
+	}
+	if !strings.Contains(content, "return 503;") {
+		t.Errorf("expected return 503:\n%s", content)
+	}
+}
+
+func TestRenderMaintenanceKeepsServerHeaders(t *testing.T) {
+	params := RouteParams{
+		Name:            "api",
+		Routes:          []Route{{Type: "subdomain", Value: "api.example.com"}, {Type: "path", Value: "example.com/api"}},
+		Port:            3000,
+		TLSCert:         "/etc/gophercaptain/certs/api.example.com/fullchain.pem",
+		TLSKey:          "/etc/gophercaptain/certs/api.example.com/privkey.pem",
+		Maintenance:     true,
+		MaintenancePage: "/etc/gophercaptain/maintenance.html",
+		RetryAfter:      30,
+	}
+	content, err := RenderConfig(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	location, err := RenderLocation(params, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Locations that add Retry-After repeat the server's headers, which
+	// nginx would otherwise drop from every maintenance response
+	_, proxy, _ := strings.Cut(content, "location / {\n        add_header Retry-After")
+	_, page, _ := strings.Cut(proxy, "location = /gc-maintenance/api.html {")
+	for _, block := range []string{proxy, page} {
+		for _, want := range []string{`add_header Strict-Transport-Security "max-age=31536000" always;`, "add_header X-GopherCaptain-Upstream $gophercaptain_upstream always;"} {
+			if !strings.Contains(block, want) {
+				t.Errorf("maintenance location should repeat %q, got:\n%s", want, content)
+			}
+		}
+	}
+
+	// Path routes are served over plain HTTP, so only the upstream header is repeated
+	if strings.Count(location, "add_header X-GopherCaptain-Upstream") != 2 {
+		t.Errorf("both maintenance locations should repeat the upstream header, got:\n%s", location)
+	}
+	if strings.Contains(location, "Strict-Transport-Security") {
+		t.Errorf("path route locations should not send HSTS, got:\n%s", location)
+	}
+}
+
+func TestValidateAccess(t *testing.T) {
+	tests := []struct {
+		allow, deny []string
+		rate        string
+		burst       int
+		ok          bool
+	}{
+		{nil, nil, "", 0, true},
+		{[]string{"10.0.0.0/8", "::1"}, []string{"192.168.0.1"}, "5r/m", 10, true},
+		{[]string{"10.0.0.0/33"}, nil, "", 0, false},
+		{nil, []string{"all"}, "", 0, false},
+		{nil, nil, "10/s", 0, false},
+		{nil, nil, "", 5, false},
+	}
+	for _, tt := range tests {
+		err := ValidateAccess(tt.allow, tt.deny, tt.rate, tt.burst)
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidateAccess(%q, %q, %q, %d) = %v, want ok=%v", tt.allow, tt.deny, tt.rate, tt.burst, err, tt.ok)
+		}
+	}
+}
+
+func TestWriteConfigOverride(t *testing.T) {
+	sitesDir := t.TempDir()
+	tmplDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmplDir, "api"), 0755)
+	override := filepath.Join(tmplDir, "api", templates.NginxConfig)
+	os.WriteFile(override, []byte(`server {
+    listen 8080;
+    server_name {{join .Domains " "}};
+{{template "proxy" .}}}
+`), 0644)
+
+	fake := runner.NewFakeRunner()
+	mgr := New(fake, sitesDir, t.TempDir(), t.TempDir()).WithTemplates(templates.New(tmplDir))
+	params := RouteParams{Name: "api", Routes: []Route{{Type: "subdomain", Value: "api.example.com"}}, Port: 3000}
+	if err := mgr.WriteConfig(context.Background(), params); err != nil {
+		t.Fatalf("WriteConfig: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(sitesDir, "gc-api.conf"))
+	content := string(data)
+	if templates.Source(content) != override || !strings.Contains(content, "listen 8080;") || !strings.Contains(content, "proxy_pass http://127.0.0.1:3000;") {
+		t.Errorf("config should come from the override, got:\n%s", content)
+	}
+
+	// Another service keeps the built-in template
+	params.Name = "web"
+	mgr.WriteConfig(context.Background(), params)
+	data, _ = os.ReadFile(filepath.Join(sitesDir, "gc-web.conf"))
+	if templates.Source(string(data)) != templates.Builtin {
+		t.Errorf("web should use the built-in template, got:\n%s", data)
+	}
+}
+
+func TestWriteConfigInvalidOverride(t *testing.T) {
+	sitesDir := t.TempDir()
+	tmplDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmplDir, templates.NginxLocation), []byte("location {{.Nope}} {}\n"), 0644)
+
+	fake := runner.NewFakeRunner()
+	mgr := New(fake, sitesDir, t.TempDir(), t.TempDir()).WithTemplates(templates.New(tmplDir))
+	err := mgr.WriteConfig(context.Background(), RouteParams{Name: "api", Routes: []Route{{Type: "path", Value: "example.com/api"}}, Port: 3000})
+	if err == nil || !strings.Contains(err.Error(), templates.NginxLocation) {
+		t.Errorf("expected an error naming the override, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(sitesDir, "gc-api.conf")); !os.IsNotExist(err) {
+		t.Error("nothing should be written when an override fails")
+	}
+	if fake.Called("nginx -t") {
+		t.Error("nginx should not be tested when an override fails")
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	sitesDir := t.TempDir()
+	enabledDir := t.TempDir()
+	mgr := New(runner.NewFakeRunner(), sitesDir, enabledDir, t.TempDir())
+
+	params := RouteParams{
+		Name:      "api",
+		Routes:    []Route{{Type: "subdomain", Value: "api.example.com"}},
+		Port:      3000,
+		AccessLog: "/var/log/nginx/gc-api.access.log",
+	}
+	if err := mgr.WriteConfig(context.Background(), params); err != nil {
+		t.Fatalf("WriteConfig: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(sitesDir, "gc-api.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "access_log /var/log/nginx/gc-api.access.log gophercaptain;") {
+		t.Errorf("config should log to the service's access log:\n%s", data)
+	}
+
+	format, err := os.ReadFile(filepath.Join(enabledDir, "00-gophercaptain-log-format.conf"))
+	if err != nil {
+		t.Fatalf("log format should be enabled: %v", err)
+	}
+	for _, want := range []string{"log_format gophercaptain escape=json", `"status":$status`, `"upstream_time":"$upstream_response_time"`} {
+		if !strings.Contains(string(format), want) {
+			t.Errorf("log format missing %q:\n%s", want, format)
+		}
+	}
+
+	// In maintenance the 503s are logged too
+	params.Maintenance = true
+	content, err := RenderConfig(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, "access_log /var/log/nginx/gc-api.access.log gophercaptain;") {
+		t.Errorf("maintenance config should keep the access log:\n%s", content)
+	}
+}
+
+func TestUpstreamHeader(t *testing.T) {
+	sitesDir := t.TempDir()
+	enabledDir := t.TempDir()
+	mgr := New(runner.NewFakeRunner(), sitesDir, enabledDir, t.TempDir())
+
+	for _, params := range []RouteParams{
+		{Name: "api", Routes: []Route{{Type: "subdomain", Value: "api.example.com"}}, Port: 3000},
+		{Name: "auth", Routes: []Route{{Type: "path", Value: "example.com/auth"}}, Port: 3001},
+	} {
+		if err := mgr.WriteConfig(context.Background(), params); err != nil {
+			t.Fatalf("WriteConfig: %v", err)
+		}
+	}
+	for _, file := range []string{"gc-api.conf", "gophercaptain-host-example.com.conf"} {
+		data, err := os.ReadFile(filepath.Join(sitesDir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "add_header X-GopherCaptain-Upstream $gophercaptain_upstream always;") {
+			t.Errorf("%s should send the upstream header:\n%s", file, data)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(enabledDir, "00-gophercaptain-upstream.conf"))
+	if err != nil {
+		t.Fatalf("upstream map should be enabled: %v", err)
+	}
+	if !strings.Contains(string(data), "127.0.0.1 $upstream_addr;") || !strings.Contains(string(data), `default   "";`) {
+		t.Errorf("upstream map should only answer local clients:\n%s", data)
+	}
+}
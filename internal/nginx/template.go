@@ -3,59 +3,497 @@ package nginx
 import (
 	"bytes"
 	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
 	"text/template"
+
+	"github.com/ecairns22/GopherCaptain/internal/templates"
 )
 
-const subdomainTemplate = `server {
+// upstreamTemplate load-balances over the instances of a scaled service.
+// It is rendered ahead of the route when there is more than one port, or
+// to hold idle keepalive connections.
+const upstreamTemplate = `{{define "upstream"}}{{if .Upstream}}upstream gc-{{.Name}} {
+{{- range .Servers}}
+    server 127.0.0.1:{{.}};
+{{- end}}
+{{- if .Keepalive}}
+    keepalive {{.Keepalive}};
+{{- end}}
+}
+
+{{end}}{{end}}`
+
+// limitTemplate declares the request rate zone of a rate-limited service.
+// Zones are http-level, so it is rendered into gc-<name>.conf for path
+// routes too.
+const limitTemplate = `{{define "limit"}}{{if .RateLimit}}limit_req_zone $binary_remote_addr zone=gc-{{.Name}}:10m rate={{.RateLimit}};
+
+{{end}}{{end}}`
+
+// proxyTemplate is the location block that forwards to the service,
+// followed in maintenance by the one serving the maintenance page.
+const proxyTemplate = `{{define "proxy"}}    location / {
+{{- range .Directives}}
+        {{.}}
+{{- end}}
+    }
+{{- with .MaintenanceURI}}
+
+    location = {{.}} {
+        internal;
+        alias {{$.MaintenancePage}};
+{{- range $.MaintenanceHeaders}}
+        {{.}}
+{{- end}}
+    }
+{{- end}}
+{{end}}`
+
+// subdomainTemplate serves the subdomain routes of a service as one server
+// block, on port 80 or with a certificate on 443 with port 80 redirecting
+// to it. ACME HTTP-01 challenges are answered on port 80 in both cases.
+// Every server block sends UpstreamHeader to local clients.
+// Without subdomain routes only the upstream, if any, is rendered; path
+// routes are served by their host's server block.
+const subdomainTemplate = `{{template "limit" .}}{{template "upstream" .}}{{with .Domains}}server {
     listen 80;
-    server_name {{.RouteValue}};
-
-    location / {
-        proxy_pass http://127.0.0.1:{{.Port}};
-        proxy_set_header Host $host;
-        proxy_set_header X-Real-IP $remote_addr;
-        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
-        proxy_set_header X-Forwarded-Proto $scheme;
+    server_name {{join . " "}};
+    add_header X-GopherCaptain-Upstream $gophercaptain_upstream always;
+{{- if $.ACMEWebroot}}
+
+    location /.well-known/acme-challenge/ {
+        root {{$.ACMEWebroot}};
+    }
+{{- end}}
+
+{{if $.TLS}}    location / {
+        return 301 https://$host$request_uri;
     }
 }
-`
 
-const pathTemplate = `location {{.RouteValue}} {
-    proxy_pass http://127.0.0.1:{{.Port}};
-    proxy_set_header Host $host;
-    proxy_set_header X-Real-IP $remote_addr;
-    proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
-    proxy_set_header X-Forwarded-Proto $scheme;
+server {
+    listen 443 ssl http2;
+    server_name {{join . " "}};
+
+    ssl_certificate     {{$.TLSCert}};
+    ssl_certificate_key {{$.TLSKey}};
+    add_header Strict-Transport-Security "max-age=31536000" always;
+    add_header X-GopherCaptain-Upstream $gophercaptain_upstream always;
+
+{{template "proxy" $}}}
+{{else}}{{template "proxy" $}}}
+{{end}}{{end}}`
+
+// locationTemplate holds a service's path routes on one host, written to a
+// snippet that the host's server block includes.
+const locationTemplate = `{{range $i, $path := .Paths}}{{if $i}}
+{{end}}location {{$path}} {
+{{- range $.Directives}}
+    {{.}}
+{{- end}}
+}
+{{end}}{{with .MaintenanceURI}}
+location = {{.}} {
+    internal;
+    alias {{$.MaintenancePage}};
+{{- range $.MaintenanceHeaders}}
+    {{.}}
+{{- end}}
+}
+{{end}}`
+
+// hostTemplate is the shared server block for all path routes on a host.
+const hostTemplate = `# Managed by GopherCaptain; regenerated when path routes on {{.Host}} change
+server {
+    listen 80;
+    server_name {{.Host}};
+    add_header X-GopherCaptain-Upstream $gophercaptain_upstream always;
+{{range .Includes}}
+    include {{.}};
+{{- end}}
 }
 `
 
-var parsedSubdomainTemplate = template.Must(template.New("subdomain").Parse(subdomainTemplate))
-var parsedPathTemplate = template.Must(template.New("path").Parse(pathTemplate))
+var funcs = template.FuncMap{"join": strings.Join}
+
+var parsedConfigTemplate = template.Must(template.Must(template.Must(template.Must(template.New("config").Funcs(funcs).Parse(limitTemplate)).Parse(upstreamTemplate)).Parse(proxyTemplate)).Parse(subdomainTemplate))
+var parsedLocationTemplate = template.Must(template.New("location").Parse(locationTemplate))
+var parsedHostTemplate = template.Must(template.New("host").Parse(hostTemplate))
 
-// RouteParams holds values for rendering nginx config.
+// Route is one route of a service.
+type Route struct {
+	Type  string // "subdomain" or "path"
+	Value string // domain, or host/path for path routes
+}
+
+// Host returns the domain the route is served on. Path routes without a
+// host, as recorded before hosts were required, return "".
+func (r Route) Host() string {
+	host, _ := SplitRoute(r.Value)
+	return host
+}
+
+// Path returns the location prefix of a path route.
+func (r Route) Path() string {
+	_, path := SplitRoute(r.Value)
+	return path
+}
+
+// RouteParams holds values for rendering a service's nginx config.
 type RouteParams struct {
-	Name       string
-	RouteType  string // "subdomain" or "path"
-	RouteValue string
-	Port       int
+	Name   string
+	Routes []Route
+	Port   int
+	Ports  []int // instance ports of a scaled service; overrides Port when set
+
+	TLSCert     string // certificate chain path; set to serve subdomain routes over HTTPS
+	TLSKey      string // private key path
+	ACMEWebroot string // directory served at /.well-known/acme-challenge/, empty = none
+
+	Proto       string // "http", "websocket" or "grpc"; empty = http
+	Keepalive   int    // idle upstream connections per worker, 0 = none
+	ReadTimeout string // proxy_read_timeout, e.g. "3600s"; empty = nginx default
+	MaxBodySize string // client_max_body_size, e.g. "50m"; empty = nginx default
+
+	Allow     []string // CIDRs allowed, empty = everyone not denied
+	Deny      []string // CIDRs refused, checked first
+	AuthFile  string   // htpasswd file for basic auth, empty = none
+	RateLimit string   // limit_req rate per client address, e.g. "10r/s"
+	RateBurst int      // requests queued above RateLimit before 503s
+
+	Maintenance     bool   // answer 503 instead of forwarding to the service
+	MaintenancePage string // HTML file served with the 503, empty = nginx's own
+	RetryAfter      int    // Retry-After seconds sent with the 503
+
+	AccessLog string // file the service's requests are logged to in LogFormat, empty = nginx's default log
+
+	// Metadata for override templates; the built-in ones do not use it
+	Version string
+	Repo    string
+	Env     map[string]string // the service's --env values and PORT
+}
+
+// LocationParams holds values for rendering the location snippet of a
+// service's path routes on one host.
+type LocationParams struct {
+	RouteParams
+	Host  string
+	Paths []string
+}
+
+// Domains returns the hosts of the subdomain routes, which share one
+// server block.
+func (p RouteParams) Domains() []string {
+	var domains []string
+	for _, r := range p.Routes {
+		if r.Type == "subdomain" {
+			domains = append(domains, r.Value)
+		}
+	}
+	return domains
+}
+
+// Hosts returns the hosts that path routes are served on, sorted.
+func (p RouteParams) Hosts() []string {
+	var hosts []string
+	for _, r := range p.Routes {
+		if r.Type == "path" && !slices.Contains(hosts, r.Host()) {
+			hosts = append(hosts, r.Host())
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Paths returns the location prefixes of the path routes on host.
+func (p RouteParams) Paths(host string) []string {
+	var paths []string
+	for _, r := range p.Routes {
+		if r.Type == "path" && r.Host() == host {
+			paths = append(paths, r.Path())
+		}
+	}
+	return paths
+}
+
+// TLS reports whether the route is served over HTTPS.
+func (p RouteParams) TLS() bool {
+	return p.TLSCert != ""
+}
+
+// Upstream reports whether the route needs an upstream block: to balance
+// over several instances, or to keep connections alive.
+func (p RouteParams) Upstream() bool {
+	return len(p.Ports) > 1 || p.Keepalive > 0
+}
+
+// Servers returns the ports listed in the upstream block.
+func (p RouteParams) Servers() []int {
+	if len(p.Ports) > 0 {
+		return p.Ports
+	}
+	return []int{p.Port}
+}
+
+// MaintenanceURI returns the internal location of the maintenance page, or
+// "" when none is served.
+func (p RouteParams) MaintenanceURI() string {
+	if !p.Maintenance || p.MaintenancePage == "" {
+		return ""
+	}
+	return "/gc-maintenance/" + p.Name + ".html"
+}
+
+// Headers that server blocks add to every response. nginx stops inheriting
+// them in a location that adds a header of its own, so maintenance
+// locations repeat them.
+const (
+	upstreamHeader = "add_header X-GopherCaptain-Upstream $gophercaptain_upstream always;"
+	hstsHeader     = `add_header Strict-Transport-Security "max-age=31536000" always;`
+)
+
+// Directives returns the lines of the location block that forwards to the
+// service, for its protocol. In maintenance the block answers 503 instead,
+// with the maintenance page if one is configured.
+func (p RouteParams) Directives() []string {
+	return p.directives(p.MaintenanceHeaders())
+}
+
+// MaintenanceHeaders returns the add_header lines of the maintenance
+// locations in the subdomain server block: Retry-After and the server's
+// own headers, including HSTS when it serves HTTPS.
+func (p RouteParams) MaintenanceHeaders() []string {
+	return p.maintenanceHeaders(p.TLS())
+}
+
+// Directives returns the lines of the locations of path routes, which are
+// served by the host's plain HTTP server block.
+func (p LocationParams) Directives() []string {
+	return p.directives(p.MaintenanceHeaders())
+}
+
+// MaintenanceHeaders returns the add_header lines of the maintenance
+// locations in the host's server block, which sends no HSTS.
+func (p LocationParams) MaintenanceHeaders() []string {
+	return p.maintenanceHeaders(false)
 }
 
-// RenderConfig renders the nginx config for the given route parameters.
+// maintenanceHeaders returns Retry-After followed by the headers of the
+// enclosing server block.
+func (p RouteParams) maintenanceHeaders(hsts bool) []string {
+	lines := []string{fmt.Sprintf("add_header Retry-After %d always;", p.RetryAfter)}
+	if hsts {
+		lines = append(lines, hstsHeader)
+	}
+	return append(lines, upstreamHeader)
+}
+
+// directives returns the location lines, with headers as the add_header
+// lines of a maintenance location.
+func (p RouteParams) directives(headers []string) []string {
+	var lines []string
+	if p.AccessLog != "" {
+		lines = append(lines, "access_log "+p.AccessLog+" "+LogFormat+";")
+	}
+	if p.Maintenance {
+		lines = append(lines, headers...)
+		if uri := p.MaintenanceURI(); uri != "" {
+			lines = append(lines, "error_page 503 "+uri+";")
+		}
+		return append(lines, "return 503;")
+	}
+	lines = append(lines, p.access()...)
+	if p.Proto == "grpc" {
+		lines = append(lines,
+			"grpc_pass grpc://"+p.Backend()+";",
+			"grpc_set_header X-Real-IP $remote_addr;",
+			"grpc_set_header X-Forwarded-For $proxy_add_x_forwarded_for;",
+		)
+		if p.ReadTimeout != "" {
+			lines = append(lines, "grpc_read_timeout "+p.ReadTimeout+";")
+		}
+	} else {
+		lines = append(lines, "proxy_pass http://"+p.Backend()+";")
+		// Upgrades and upstream keepalive both need HTTP/1.1 to the service
+		if p.Proto == "websocket" || p.Keepalive > 0 {
+			lines = append(lines, "proxy_http_version 1.1;")
+		}
+		lines = append(lines,
+			"proxy_set_header Host $host;",
+			"proxy_set_header X-Real-IP $remote_addr;",
+			"proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;",
+			"proxy_set_header X-Forwarded-Proto $scheme;",
+		)
+		if p.Proto == "websocket" {
+			lines = append(lines,
+				"proxy_set_header Upgrade $http_upgrade;",
+				`proxy_set_header Connection "upgrade";`,
+			)
+		} else if p.Keepalive > 0 {
+			lines = append(lines, `proxy_set_header Connection "";`)
+		}
+		if p.ReadTimeout != "" {
+			lines = append(lines, "proxy_read_timeout "+p.ReadTimeout+";")
+		}
+	}
+	if p.MaxBodySize != "" {
+		lines = append(lines, "client_max_body_size "+p.MaxBodySize+";")
+	}
+	return lines
+}
+
+// Backend returns the proxy_pass target: the upstream for a scaled
+// service, otherwise its single port.
+func (p RouteParams) Backend() string {
+	if p.Upstream() {
+		return "gc-" + p.Name
+	}
+	if len(p.Ports) == 1 {
+		return fmt.Sprintf("127.0.0.1:%d", p.Ports[0])
+	}
+	return fmt.Sprintf("127.0.0.1:%d", p.Port)
+}
+
+// access returns the access control lines that precede the proxy
+// directives: denied then allowed addresses, basic auth and the rate limit.
+func (p RouteParams) access() []string {
+	var lines []string
+	for _, cidr := range p.Deny {
+		lines = append(lines, "deny "+cidr+";")
+	}
+	for _, cidr := range p.Allow {
+		lines = append(lines, "allow "+cidr+";")
+	}
+	if len(p.Allow) > 0 {
+		lines = append(lines, "deny all;")
+	}
+	if p.AuthFile != "" {
+		lines = append(lines,
+			fmt.Sprintf("auth_basic %q;", "gc-"+p.Name),
+			"auth_basic_user_file "+p.AuthFile+";",
+		)
+	}
+	if p.RateLimit != "" {
+		line := "limit_req zone=gc-" + p.Name
+		if p.RateBurst > 0 {
+			line += fmt.Sprintf(" burst=%d nodelay", p.RateBurst)
+		}
+		lines = append(lines, line+";")
+	}
+	return lines
+}
+
+// RenderConfig renders gc-<name>.conf for the given route parameters: the
+// upstream of a scaled service and the server block of its subdomain routes.
 func RenderConfig(params RouteParams) (string, error) {
-	var buf bytes.Buffer
-	var tmpl *template.Template
+	return renderConfig(parsedConfigTemplate, templates.Builtin, params)
+}
 
-	switch params.RouteType {
-	case "subdomain":
-		tmpl = parsedSubdomainTemplate
-	case "path":
-		tmpl = parsedPathTemplate
-	default:
-		return "", fmt.Errorf("unknown route type %q; must be 'subdomain' or 'path'", params.RouteType)
+// renderConfig checks params and renders gc-<name>.conf with t.
+func renderConfig(t *template.Template, source string, params RouteParams) (string, error) {
+	for _, r := range params.Routes {
+		if r.Type != "subdomain" && r.Type != "path" {
+			return "", fmt.Errorf("unknown route type %q; must be 'subdomain' or 'path'", r.Type)
+		}
+	}
+	if params.TLS() && len(params.Domains()) == 0 {
+		return "", fmt.Errorf("TLS is only supported for subdomain routes")
+	}
+	if err := ValidateProxyOptions(params.Proto, params.Keepalive, params.ReadTimeout, params.MaxBodySize); err != nil {
+		return "", err
+	}
+	if err := ValidateAccess(params.Allow, params.Deny, params.RateLimit, params.RateBurst); err != nil {
+		return "", err
 	}
 
-	if err := tmpl.Execute(&buf, params); err != nil {
+	content, err := templates.Execute(t, source, params)
+	if err != nil {
 		return "", fmt.Errorf("rendering nginx config: %w", err)
 	}
+	return content, nil
+}
+
+// RenderLocation renders the location snippet for a service's path routes
+// on host.
+func RenderLocation(params RouteParams, host string) (string, error) {
+	return renderLocation(parsedLocationTemplate, templates.Builtin, params, host)
+}
+
+// renderLocation renders the location snippet on host with t.
+func renderLocation(t *template.Template, source string, params RouteParams, host string) (string, error) {
+	data := LocationParams{RouteParams: params, Host: host, Paths: params.Paths(host)}
+	if len(data.Paths) == 0 {
+		return "", fmt.Errorf("%s has no path routes on %s", params.Name, host)
+	}
+	content, err := templates.Execute(t, source, data)
+	if err != nil {
+		return "", fmt.Errorf("rendering nginx location: %w", err)
+	}
+	return content, nil
+}
+
+// RenderHost renders the server block for a host that includes the given
+// location snippets.
+func RenderHost(host string, includes []string) (string, error) {
+	var buf bytes.Buffer
+	data := struct {
+		Host     string
+		Includes []string
+	}{host, includes}
+	if err := parsedHostTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering nginx host %s: %w", host, err)
+	}
 	return buf.String(), nil
 }
+
+var (
+	validTimeout = regexp.MustCompile(`^[0-9]+(ms|s|m|h|d)?$`)
+	validSize    = regexp.MustCompile(`^[0-9]+[kKmMgG]?$`)
+	validRate    = regexp.MustCompile(`^[1-9][0-9]*r/[sm]$`)
+)
+
+// ValidateProxyOptions checks a route's protocol and tuning before they are
+// rendered into nginx directives.
+func ValidateProxyOptions(proto string, keepalive int, readTimeout, maxBodySize string) error {
+	switch proto {
+	case "", "http", "websocket", "grpc":
+	default:
+		return fmt.Errorf("unknown protocol %q; must be 'http', 'websocket' or 'grpc'", proto)
+	}
+	if keepalive < 0 {
+		return fmt.Errorf("keepalive must not be negative")
+	}
+	if readTimeout != "" && !validTimeout.MatchString(readTimeout) {
+		return fmt.Errorf("invalid read timeout %q; use an nginx time such as 60s or 1h", readTimeout)
+	}
+	if maxBodySize != "" && !validSize.MatchString(maxBodySize) {
+		return fmt.Errorf("invalid max body size %q; use an nginx size such as 10m or 0 for no limit", maxBodySize)
+	}
+	return nil
+}
+
+// ValidateAccess checks a route's address lists and rate limit. Addresses
+// are IPs or CIDRs; rates are nginx limit_req rates such as 10r/s or 30r/m.
+func ValidateAccess(allow, deny []string, rateLimit string, rateBurst int) error {
+	for _, cidr := range append(append([]string{}, allow...), deny...) {
+		if net.ParseIP(cidr) == nil {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return fmt.Errorf("invalid address %q; use an IP or CIDR such as 10.0.0.0/8", cidr)
+			}
+		}
+	}
+	if rateLimit != "" && !validRate.MatchString(rateLimit) {
+		return fmt.Errorf("invalid rate limit %q; use a rate such as 10r/s or 30r/m", rateLimit)
+	}
+	if rateBurst < 0 {
+		return fmt.Errorf("rate burst must not be negative")
+	}
+	if rateBurst > 0 && rateLimit == "" {
+		return fmt.Errorf("a rate burst needs a rate limit")
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ecairns22/GopherCaptain/internal/nginx"
+	"github.com/ecairns22/GopherCaptain/internal/state"
+)
+
+// htpasswdPath is the basic auth user file of a service's routes.
+func (o *Orchestrator) htpasswdPath(name string) string {
+	return filepath.Join(o.cfg.Nginx.HtpasswdDir, name)
+}
+
+// AddAuthUser adds a basic auth user to a service's routes, or changes the
+// password of an existing one. The first user turns basic auth on.
+func (o *Orchestrator) AddAuthUser(ctx context.Context, name, user, password string) error {
+	svc, err := o.routedService(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := nginx.ValidateAuthUser(user); err != nil {
+		return err
+	}
+	if password == "" {
+		return fmt.Errorf("password must not be empty")
+	}
+	hash, err := nginx.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := o.store.SetAuthUser(ctx, &state.AuthUser{Service: name, User: user, Hash: hash}); err != nil {
+		return err
+	}
+	return o.applyAuth(ctx, svc, "auth add "+user)
+}
+
+// RemoveAuthUser removes a basic auth user. Removing the last user turns
+// basic auth off.
+func (o *Orchestrator) RemoveAuthUser(ctx context.Context, name, user string) error {
+	svc, err := o.routedService(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := o.store.DeleteAuthUser(ctx, name, user); err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return fmt.Errorf("service %q has no auth user %q", name, user)
+		}
+		return err
+	}
+	return o.applyAuth(ctx, svc, "auth remove "+user)
+}
+
+// AuthUsers returns the names of a service's basic auth users.
+func (o *Orchestrator) AuthUsers(ctx context.Context, name string) ([]string, error) {
+	if _, err := o.store.GetService(ctx, name); err != nil {
+		return nil, fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", name)
+	}
+	users, err := o.store.AuthUsers(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(users))
+	for i, u := range users {
+		names[i] = u.User
+	}
+	return names, nil
+}
+
+// routedService loads a service that has at least one route.
+func (o *Orchestrator) routedService(ctx context.Context, name string) (*state.Service, error) {
+	svc, err := o.store.GetService(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", name)
+	}
+	if len(svc.Routes) == 0 {
+		return nil, fmt.Errorf("service %q has no routes", name)
+	}
+	return svc, nil
+}
+
+// applyAuth rewrites a service's htpasswd file from state, removing it
+// once no users are left, re-renders its nginx config and records the
+// change in history.
+func (o *Orchestrator) applyAuth(ctx context.Context, svc *state.Service, detail string) error {
+	users, err := o.store.AuthUsers(ctx, svc.Name)
+	if err != nil {
+		return err
+	}
+	path := o.htpasswdPath(svc.Name)
+	if len(users) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", path, err)
+		}
+	} else {
+		hashes := make(map[string]string, len(users))
+		for _, u := range users {
+			hashes[u.User] = u.Hash
+		}
+		if err := nginx.WriteHtpasswd(path, hashes); err != nil {
+			return err
+		}
+	}
+
+	if err := o.proxy.WriteConfig(ctx, o.routeParams(svc)); err != nil {
+		return err
+	}
+	o.store.AppendHistory(ctx, &state.HistoryEntry{
+		Service:   svc.Name,
+		Action:    "configure",
+		Version:   svc.Version,
+		Timestamp: time.Now(),
+		Detail:    map[string]string{"change": detail},
+	})
+	return nil
+}
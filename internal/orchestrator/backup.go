@@ -0,0 +1,400 @@
+package orchestrator
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ecairns22/GopherCaptain/internal/db"
+	"github.com/ecairns22/GopherCaptain/internal/state"
+	"github.com/ecairns22/GopherCaptain/internal/systemd"
+)
+
+// backupTimeLayout is the timestamp in backup file names. It sorts in
+// creation order, so the newest backup is the last name.
+const backupTimeLayout = "20060102-150405"
+
+// backupExt is the extension of compressed SQL dumps.
+const backupExt = ".sql.gz"
+
+// Backup is one compressed database dump of a service.
+type Backup struct {
+	Name    string // file name, as given to db restore
+	Path    string
+	Size    int64
+	Created time.Time
+}
+
+// BackupResult reports a new backup and the old ones pruned to make room.
+type BackupResult struct {
+	Backup *Backup
+	Pruned []*Backup
+}
+
+// RestoreResult reports the backup a database was restored from and the
+// backup taken of its contents just before.
+type RestoreResult struct {
+	Restored *Backup
+	Safety   *Backup
+}
+
+// backupDir returns the directory holding a service's backups.
+func (o *Orchestrator) backupDir(name string) string {
+	return filepath.Join(o.cfg.Backups.Dir, name)
+}
+
+// databaseService returns a service that has a database, and its provider.
+func (o *Orchestrator) databaseService(ctx context.Context, name string) (*state.Service, db.Provider, error) {
+	svc, err := o.store.GetService(ctx, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", name)
+	}
+	if svc.DBName == "" {
+		return nil, nil, fmt.Errorf("service %q has no database", name)
+	}
+	provider, err := o.db.Provider(svc.DBProvider)
+	if err != nil {
+		return nil, nil, err
+	}
+	return svc, provider, nil
+}
+
+// BackupDatabase dumps a service's database to a new compressed backup,
+// then prunes the oldest backups beyond [backups] keep.
+func (o *Orchestrator) BackupDatabase(ctx context.Context, name string) (*BackupResult, error) {
+	svc, provider, err := o.databaseService(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := o.dump(ctx, svc, provider)
+	if err != nil {
+		return nil, err
+	}
+	pruned, err := o.pruneBackups(name)
+	if err != nil {
+		return nil, err
+	}
+	return &BackupResult{Backup: backup, Pruned: pruned}, nil
+}
+
+// dump writes <name>-<timestamp>.sql.gz. The dump is streamed to a
+// .partial file that is only renamed once complete, so a failed or
+// interrupted backup is never listed or restored.
+func (o *Orchestrator) dump(ctx context.Context, svc *state.Service, provider db.Provider) (*Backup, error) {
+	dir := o.backupDir(svc.Name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("creating backup directory: %w", err)
+	}
+
+	created := time.Now()
+	file := svc.Name + "-" + created.Format(backupTimeLayout) + backupExt
+	path := filepath.Join(dir, file)
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("backup %s already exists; try again in a second", file)
+	}
+
+	partial := path + ".partial"
+	f, err := os.OpenFile(partial, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("creating backup file: %w", err)
+	}
+	zw := gzip.NewWriter(f)
+	err = provider.Dump(ctx, svc.Name, zw)
+	if closeErr := zw.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("compressing backup: %w", closeErr)
+	}
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("writing backup file: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(partial)
+		return nil, err
+	}
+	if err := os.Rename(partial, path); err != nil {
+		os.Remove(partial)
+		return nil, fmt.Errorf("saving backup: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading backup: %w", err)
+	}
+	return &Backup{Name: file, Path: path, Size: info.Size(), Created: created}, nil
+}
+
+// Backups lists a service's backups, newest first.
+func (o *Orchestrator) Backups(ctx context.Context, name string) ([]*Backup, error) {
+	if _, _, err := o.databaseService(ctx, name); err != nil {
+		return nil, err
+	}
+	return o.listBackups(name)
+}
+
+// listBackups reads the backup directory of a service, newest first. A
+// missing directory means no backups yet.
+func (o *Orchestrator) listBackups(name string) ([]*Backup, error) {
+	dir := o.backupDir(name)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading backups: %w", err)
+	}
+
+	var backups []*Backup
+	for _, e := range entries {
+		stamp, ok := backupStamp(name, e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		created, err := time.ParseInLocation(backupTimeLayout, stamp, time.Local)
+		if err != nil {
+			created = info.ModTime()
+		}
+		backups = append(backups, &Backup{
+			Name:    e.Name(),
+			Path:    filepath.Join(dir, e.Name()),
+			Size:    info.Size(),
+			Created: created,
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+	return backups, nil
+}
+
+// backupStamp returns the timestamp part of a backup file name of the
+// service, and whether the name is one.
+func backupStamp(name, file string) (string, bool) {
+	prefix := name + "-"
+	if !strings.HasPrefix(file, prefix) || !strings.HasSuffix(file, backupExt) {
+		return "", false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(file, prefix), backupExt)
+	// A service called "api" must not claim the backups of "api-v2"
+	if len(stamp) != len(backupTimeLayout) {
+		return "", false
+	}
+	return stamp, true
+}
+
+// pruneBackups deletes all but the newest [backups] keep backups.
+func (o *Orchestrator) pruneBackups(name string) ([]*Backup, error) {
+	backups, err := o.listBackups(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) <= o.cfg.Backups.Keep {
+		return nil, nil
+	}
+	pruned := backups[o.cfg.Backups.Keep:]
+	for _, b := range pruned {
+		if err := os.Remove(b.Path); err != nil {
+			return nil, fmt.Errorf("pruning backup %s: %w", b.Name, err)
+		}
+	}
+	return pruned, nil
+}
+
+// RestoreDatabase replaces a service's database with a backup, given by
+// file name or as "latest". The service is stopped while the restore runs,
+// with its routes serving the maintenance page, and the current contents
+// are backed up first so a bad restore can itself be undone. The safety
+// backup is not counted against [backups] keep until the next backup.
+func (o *Orchestrator) RestoreDatabase(ctx context.Context, name, backup string, progress func(string)) (*RestoreResult, error) {
+	report := func(msg string) {
+		if progress != nil {
+			progress(msg)
+		}
+	}
+	svc, provider, err := o.databaseService(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	restored, err := o.findBackup(name, backup)
+	if err != nil {
+		return nil, err
+	}
+
+	report("Backing up current database...")
+	safety, err := o.dump(ctx, svc, provider)
+	if err != nil {
+		return nil, fmt.Errorf("backing up before restore: %w", err)
+	}
+	report("Saved " + safety.Name)
+
+	endMaintenance := o.beginMaintenance(ctx, svc, progress)
+	defer endMaintenance()
+
+	report(fmt.Sprintf("Stopping gc-%s...", name))
+	if err := o.stopService(ctx, svc); err != nil {
+		o.activate(ctx, svc)
+		return nil, err
+	}
+
+	report("Restoring " + restored.Name + "...")
+	restoreErr := o.restoreFile(ctx, svc, provider, restored.Path)
+
+	// The service comes back either way. A failed PostgreSQL restore runs
+	// in one transaction and leaves the old data; a failed MariaDB restore
+	// can leave the database partly restored, which the safety backup undoes
+	report(fmt.Sprintf("Starting gc-%s...", name))
+	if err := o.activate(ctx, svc); err != nil {
+		if restoreErr != nil {
+			return nil, restoreErr
+		}
+		return nil, fmt.Errorf("starting service after restore: %w", err)
+	}
+	if restoreErr != nil {
+		return nil, fmt.Errorf("%w; run 'gophercaptain db restore %s %s' to return to the data from before the restore", restoreErr, name, safety.Name)
+	}
+
+	o.store.AppendHistory(ctx, &state.HistoryEntry{
+		Service:   name,
+		Action:    "restore",
+		Version:   svc.Version,
+		Timestamp: time.Now(),
+		Detail:    map[string]string{"backup": restored.Name, "safety_backup": safety.Name},
+	})
+
+	return &RestoreResult{Restored: restored, Safety: safety}, nil
+}
+
+// findBackup resolves a backup argument to one of the service's backups.
+// Only file names are accepted, so a restore cannot read an arbitrary path.
+func (o *Orchestrator) findBackup(name, backup string) (*Backup, error) {
+	backups, err := o.listBackups(name)
+	if err != nil {
+		return nil, err
+	}
+	if backup == "latest" {
+		if len(backups) == 0 {
+			return nil, fmt.Errorf("service %q has no backups; run 'gophercaptain db backup %s' first", name, name)
+		}
+		return backups[0], nil
+	}
+	for _, b := range backups {
+		if b.Name == backup {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("backup %q not found; run 'gophercaptain db backups %s' to see backups", backup, name)
+}
+
+// restoreFile feeds a compressed dump to the provider.
+func (o *Orchestrator) restoreFile(ctx context.Context, svc *state.Service, provider db.Provider, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening backup: %w", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("reading backup %s: %w", filepath.Base(path), err)
+	}
+	defer zr.Close()
+	return provider.Restore(ctx, svc.Name, zr)
+}
+
+// stopService stops everything that could run a service against its
+// database: each instance of a scaled service, and the timer or socket
+// that would start it again. activate undoes it.
+func (o *Orchestrator) stopService(ctx context.Context, svc *state.Service) error {
+	switch {
+	case len(svc.Instances) > 0:
+		for _, port := range svc.Instances {
+			if err := o.systemd.StopInstance(ctx, svc.Name, port); err != nil {
+				return err
+			}
+		}
+		return nil
+	case svc.Schedule != "":
+		if err := o.systemd.StopTimer(ctx, svc.Name); err != nil {
+			return err
+		}
+	case svc.SocketActivated:
+		if err := o.systemd.StopSocket(ctx, svc.Name); err != nil {
+			return err
+		}
+	}
+	return o.systemd.Stop(ctx, svc.Name)
+}
+
+// ScheduleBackups installs a timer that backs up a service's database on
+// an OnCalendar= schedule, pruning as db backup does. An empty schedule
+// removes the timer.
+func (o *Orchestrator) ScheduleBackups(ctx context.Context, name, schedule string) error {
+	svc, _, err := o.databaseService(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	change := "backups unscheduled"
+	if schedule == "" {
+		if svc.BackupSchedule == "" {
+			return nil
+		}
+		if err := o.systemd.DisableBackupTimer(ctx, name); err != nil {
+			return err
+		}
+	} else {
+		if err := o.systemd.ValidateSchedule(ctx, schedule); err != nil {
+			return err
+		}
+		binary, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("locating gophercaptain binary: %w", err)
+		}
+		err = o.systemd.EnableBackupTimer(ctx, systemd.BackupParams{
+			Binary:     binary,
+			Name:       name,
+			Schedule:   schedule,
+			DBProvider: svc.DBProvider,
+		})
+		if err != nil {
+			return err
+		}
+		change = "backups " + schedule
+	}
+
+	svc.BackupSchedule = schedule
+	svc.UpdatedAt = time.Now()
+	if err := o.store.UpdateService(ctx, svc); err != nil {
+		return err
+	}
+	o.store.AppendHistory(ctx, &state.HistoryEntry{
+		Service:   name,
+		Action:    "configure",
+		Version:   svc.Version,
+		Timestamp: svc.UpdatedAt,
+		Detail:    map[string]string{"change": change},
+	})
+	return nil
+}
+
+// checkDataDirs refuses service names whose own directories hold the
+// tool's data: remove always deletes the config directory, and
+// --purge-data the state, cache and logs directories, as does a failed
+// deploy.
+func (o *Orchestrator) checkDataDirs(name string) error {
+	serviceDirs := append([]string{filepath.Join(configBase, name)}, systemd.DataDirs(name)...)
+	toolDirs := []string{o.cfg.Backups.Dir, o.cfg.Nginx.ACMEWebroot, o.cfg.Nginx.CertDir, o.cfg.Nginx.HtpasswdDir, o.cfg.Templates.Dir}
+	for _, serviceDir := range serviceDirs {
+		for _, dir := range toolDirs {
+			rel, err := filepath.Rel(serviceDir, dir)
+			if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("service name %q is reserved: %s holds %s; use --name to choose a different service name", name, serviceDir, dir)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package orchestrator
+
+import (
+	"context"
+	"time"
+
+	"github.com/ecairns22/GopherCaptain/internal/state"
+)
+
+// SetMaintenance switches a service's routes to the maintenance page, or
+// back to the service. The switch is kept in state, so it survives
+// re-renders of the config until it is turned off again.
+func (o *Orchestrator) SetMaintenance(ctx context.Context, name string, on bool) error {
+	svc, err := o.routedService(ctx, name)
+	if err != nil {
+		return err
+	}
+	svc.Maintenance = on
+	if err := o.proxy.WriteConfig(ctx, o.routeParams(svc)); err != nil {
+		return err
+	}
+	if err := o.store.UpdateService(ctx, svc); err != nil {
+		return err
+	}
+	change := "maintenance off"
+	if on {
+		change = "maintenance on"
+	}
+	o.store.AppendHistory(ctx, &state.HistoryEntry{
+		Service:   svc.Name,
+		Action:    "configure",
+		Version:   svc.Version,
+		Timestamp: time.Now(),
+		Detail:    map[string]string{"change": change},
+	})
+	return nil
+}
+
+// beginMaintenance serves the maintenance page on a service's routes while
+// it is stopped for an upgrade or restore, so clients get a 503 with
+// Retry-After rather than a 502. It returns the function that restores the
+// routes, which is safe to call more than once. Scheduled jobs, services
+// without routes, and those already in maintenance are left alone; the page
+// does not use the upstream, so scaled services are covered too. Failures
+// are reported but do not stop the caller.
+func (o *Orchestrator) beginMaintenance(ctx context.Context, svc *state.Service, progress func(string)) func() {
+	report := func(msg string) {
+		if progress != nil {
+			progress(msg)
+		}
+	}
+	if len(svc.Routes) == 0 || svc.Schedule != "" || svc.Maintenance {
+		return func() {}
+	}
+
+	maint := *svc
+	maint.Maintenance = true
+	if err := o.proxy.WriteConfig(ctx, o.routeParams(&maint)); err != nil {
+		report("maintenance page not served: " + err.Error())
+		return func() {}
+	}
+	report("Serving maintenance page")
+
+	done := false
+	return func() {
+		if done {
+			return
+		}
+		done = true
+		if err := o.proxy.WriteConfig(ctx, o.routeParams(svc)); err != nil {
+			report("restoring routes after maintenance: " + err.Error())
+			return
+		}
+		report("Routes restored")
+	}
+}
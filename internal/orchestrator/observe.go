@@ -0,0 +1,104 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ecairns22/GopherCaptain/internal/state"
+	"github.com/ecairns22/GopherCaptain/internal/systemd"
+)
+
+// observePoll is how often unit state is sampled during the observation window.
+const observePoll = 2 * time.Second
+
+// crashLoop describes a unit that restarted too often or failed while being
+// observed after an upgrade.
+type crashLoop struct {
+	Reason  string
+	Journal string // tail of the failing unit's journal
+}
+
+// observedUnit is one unit watched for restarts, with its restart count when
+// observation began.
+type observedUnit struct {
+	port     int // instance port, 0 for gc-<name>.service
+	baseline int
+}
+
+// observe watches a freshly upgraded service for window and reports a crash
+// loop if any of its units fails or restarts more than maxRestarts times.
+// Scheduled jobs are not observed; they only run when their timer fires.
+// Cancelling ctx ends the window early without a verdict.
+func (o *Orchestrator) observe(ctx context.Context, svc *state.Service, window time.Duration, maxRestarts int) *crashLoop {
+	if window <= 0 || svc.Schedule != "" {
+		return nil
+	}
+
+	var units []observedUnit
+	if len(svc.Instances) > 0 {
+		for _, port := range svc.Instances {
+			units = append(units, observedUnit{port: port})
+		}
+	} else {
+		units = []observedUnit{{}}
+	}
+	for i := range units {
+		if status, err := o.unitStatus(ctx, svc.Name, units[i].port); err == nil {
+			units[i].baseline = status.NRestarts
+		}
+	}
+
+	deadline := time.Now().Add(window)
+	ticker := time.NewTicker(observePoll)
+	defer ticker.Stop()
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		for _, u := range units {
+			status, err := o.unitStatus(ctx, svc.Name, u.port)
+			if err != nil {
+				continue
+			}
+			restarts := status.NRestarts - u.baseline
+			var reason string
+			switch {
+			case status.ActiveState == "failed":
+				reason = fmt.Sprintf("%s failed (%s) after %d restart(s)", unitLabel(svc.Name, u.port), status.Result, restarts)
+			case restarts > maxRestarts:
+				reason = fmt.Sprintf("%s restarted %d times within %s", unitLabel(svc.Name, u.port), restarts, window)
+			default:
+				continue
+			}
+			return &crashLoop{Reason: reason, Journal: o.unitJournal(ctx, svc.Name, u.port)}
+		}
+	}
+	return nil
+}
+
+func (o *Orchestrator) unitStatus(ctx context.Context, name string, port int) (*systemd.UnitStatus, error) {
+	if port != 0 {
+		return o.systemd.InstanceStatus(ctx, name, port)
+	}
+	return o.systemd.Status(ctx, name)
+}
+
+func (o *Orchestrator) unitJournal(ctx context.Context, name string, port int) string {
+	var journal string
+	if port != 0 {
+		journal, _ = o.systemd.InstanceJournalTail(ctx, name, port, 30)
+	} else {
+		journal, _ = o.systemd.JournalTail(ctx, name, 30)
+	}
+	return journal
+}
+
+func unitLabel(name string, port int) string {
+	if port != 0 {
+		return fmt.Sprintf("instance on port %d", port)
+	}
+	return "service"
+}
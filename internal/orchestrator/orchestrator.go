@@ -6,15 +6,16 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ecairns22/GopherCaptain/internal/certs"
 	"github.com/ecairns22/GopherCaptain/internal/config"
 	"github.com/ecairns22/GopherCaptain/internal/creds"
 	"github.com/ecairns22/GopherCaptain/internal/db"
 	ghclient "github.com/ecairns22/GopherCaptain/internal/github"
 	"github.com/ecairns22/GopherCaptain/internal/health"
-	"github.com/ecairns22/GopherCaptain/internal/nginx"
 	"github.com/ecairns22/GopherCaptain/internal/ports"
 	"github.com/ecairns22/GopherCaptain/internal/state"
 	"github.com/ecairns22/GopherCaptain/internal/systemd"
@@ -32,20 +33,20 @@ type Orchestrator struct {
 	store   *state.Store
 	gh      *ghclient.Client
 	systemd *systemd.Manager
-	nginx   *nginx.Manager
+	proxy   Proxy
 	db      *db.Manager
 	ports   *ports.Allocator
 }
 
 // New creates an Orchestrator from the loaded config and initialized managers.
-func New(cfg *config.Config, store *state.Store, gh *ghclient.Client, sys *systemd.Manager, ngx *nginx.Manager, dbMgr *db.Manager) *Orchestrator {
+func New(cfg *config.Config, store *state.Store, gh *ghclient.Client, sys *systemd.Manager, proxy Proxy, dbMgr *db.Manager) *Orchestrator {
 	alloc := ports.New(cfg.Ports.RangeStart, cfg.Ports.RangeEnd, store)
 	return &Orchestrator{
 		cfg:     cfg,
 		store:   store,
 		gh:      gh,
 		systemd: sys,
-		nginx:   ngx,
+		proxy:   proxy,
 		db:      dbMgr,
 		ports:   alloc,
 	}
@@ -53,16 +54,35 @@ func New(cfg *config.Config, store *state.Store, gh *ghclient.Client, sys *syste
 
 // DeployRequest holds all parameters for a deploy.
 type DeployRequest struct {
-	Repo       string
-	Name       string // derived from repo if empty
-	Version    string // "latest" or explicit tag
-	Port       int    // 0 = auto-assign
-	Route      string // e.g. "api.example.com" or "/api", empty = no routing
-	RouteType  string // "subdomain" or "path", inferred if empty
-	ExtraEnv   map[string]string
-	NoDB       bool
-	ConfigFile bool // write TOML instead of env
-	Owner      string
+	Repo            string
+	Name            string   // derived from repo if empty
+	Version         string   // "latest" or explicit tag
+	Port            int      // 0 = auto-assign
+	Routes          []string // e.g. "api.example.com" or "example.com/api", none = no routing
+	RouteType       string   // "subdomain" or "path" for all routes, inferred per route if empty
+	ExtraEnv        map[string]string
+	Args            []string // extra ExecStart arguments
+	WorkDir         string   // empty = state directory
+	SocketActivated bool     // start on first connection via gc-<name>.socket
+	Hardening       string   // systemd hardening profile, empty = baseline
+	Schedule        string   // OnCalendar= expression; deploys a timer-driven job
+	PreStart        []string // arguments to the new binary run before each upgrade
+	TLS             bool     // HTTPS via ACME for a subdomain route
+	NoTLS           bool     // plain HTTP even when [nginx] tls = "acme"
+	TLSCert         string   // supplied certificate chain, instead of ACME
+	TLSKey          string   // supplied private key
+	Proto           string   // "http", "websocket" or "grpc"; empty = http
+	Keepalive       int      // idle upstream connections kept open, 0 = none
+	ReadTimeout     string   // nginx proxy_read_timeout, e.g. "3600s"
+	MaxBodySize     string   // nginx client_max_body_size, e.g. "50m"
+	Allow           []string // CIDRs allowed to reach the routes, empty = everyone
+	Deny            []string // CIDRs refused
+	RateLimit       string   // per-client request rate, e.g. "10r/s"
+	RateBurst       int      // requests allowed above the rate
+	NoDB            bool
+	DB              string // database provider, "mariadb" (default) or "postgres"
+	ConfigFile      bool   // write TOML instead of env
+	Owner           string
 }
 
 // DeployResult holds the output of a successful deploy.
@@ -70,11 +90,13 @@ type DeployResult struct {
 	Name       string
 	Version    string
 	Port       int
-	Route      string
-	RouteType  string
+	Routes     []state.Route
 	DBName     string
+	DBProvider string
 	NginxSkip  bool   // true if nginx was skipped or failed (non-fatal)
 	NginxWarn  string // warning message if nginx failed
+	TLS        bool   // route is served over HTTPS
+	TLSWarn    string // warning message if the certificate could not be obtained
 }
 
 // Deploy executes the full deploy flow with rollback on failure.
@@ -89,12 +111,44 @@ func (o *Orchestrator) Deploy(ctx context.Context, req DeployRequest) (*DeployRe
 	if err := db.ValidateServiceName(name); err != nil {
 		return nil, err
 	}
+	if err := o.checkDataDirs(name); err != nil {
+		return nil, err
+	}
+
+	if req.WorkDir != "" && !filepath.IsAbs(req.WorkDir) {
+		return nil, fmt.Errorf("working directory %q must be an absolute path", req.WorkDir)
+	}
+	if err := systemd.ValidateHardening(req.Hardening); err != nil {
+		return nil, err
+	}
+	if req.Schedule != "" {
+		// Jobs listen on nothing, so there is nothing to route or activate
+		if req.Port != 0 || len(req.Routes) > 0 || req.SocketActivated {
+			return nil, fmt.Errorf("scheduled jobs cannot use --port, --route or --socket-activated")
+		}
+		if err := o.systemd.ValidateSchedule(ctx, req.Schedule); err != nil {
+			return nil, err
+		}
+	}
 
 	// Check name not already taken
 	if _, err := o.store.GetService(ctx, name); err == nil {
 		return nil, fmt.Errorf("service %q already exists; use a different --name or remove it first", name)
 	}
 
+	dbKind := req.DB
+	if dbKind == "" {
+		dbKind = db.MariaDBProvider
+	}
+	var dbProvider db.Provider
+	if !req.NoDB {
+		p, err := o.db.Provider(dbKind)
+		if err != nil {
+			return nil, err
+		}
+		dbProvider = p
+	}
+
 	// Resolve owner
 	owner := req.Owner
 	repo := req.Repo
@@ -110,9 +164,11 @@ func (o *Orchestrator) Deploy(ctx context.Context, req DeployRequest) (*DeployRe
 		return nil, fmt.Errorf("resolving version: %w", err)
 	}
 
-	// Allocate port
+	// Allocate port (scheduled jobs get none)
 	port := req.Port
-	if port == 0 {
+	if req.Schedule != "" {
+		port = 0
+	} else if port == 0 {
 		p, err := o.ports.Next(ctx)
 		if err != nil {
 			return nil, err
@@ -124,10 +180,42 @@ func (o *Orchestrator) Deploy(ctx context.Context, req DeployRequest) (*DeployRe
 		}
 	}
 
-	// Infer route type
-	routeType := req.RouteType
-	if routeType == "" && req.Route != "" {
-		routeType = nginx.InferRouteType(req.Route)
+	routes, err := o.qualifyRoutes(req.RouteType, req.Routes)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.checkConflicts(ctx, name, routes); err != nil {
+		return nil, err
+	}
+
+	svc := &state.Service{
+		Name:            name,
+		Repo:            req.Repo,
+		Version:         version,
+		Port:            port,
+		Routes:          routes,
+		ExtraEnv:        req.ExtraEnv,
+		ConfigFile:      req.ConfigFile,
+		Args:            req.Args,
+		WorkDir:         req.WorkDir,
+		SocketActivated: req.SocketActivated,
+		Hardening:       req.Hardening,
+		Schedule:        req.Schedule,
+		PreStart:        req.PreStart,
+		Proto:           req.Proto,
+		Keepalive:       req.Keepalive,
+		ReadTimeout:     req.ReadTimeout,
+		MaxBodySize:     req.MaxBodySize,
+		Allow:           req.Allow,
+		Deny:            req.Deny,
+		RateLimit:       req.RateLimit,
+		RateBurst:       req.RateBurst,
+	}
+	if err := o.resolveTLS(req, svc); err != nil {
+		return nil, err
+	}
+	if err := checkProxyOptions(svc); err != nil {
+		return nil, err
 	}
 
 	// Track completed steps for rollback
@@ -142,17 +230,14 @@ func (o *Orchestrator) Deploy(ctx context.Context, req DeployRequest) (*DeployRe
 			case "binary":
 				rbErr = removeBinary(name, version)
 			case "database":
-				rbErr = o.db.DropDatabase(ctx, name)
+				rbErr = dbProvider.DropDatabase(ctx, name)
 			case "envfile":
 				rbErr = removeEnvDir(name)
 			case "systemd":
-				o.systemd.Stop(ctx, name)
-				o.systemd.Disable(ctx, name)
-				o.systemd.RemoveUnit(name)
-				o.systemd.DaemonReload(ctx)
+				o.removeUnits(ctx, svc)
 				rbErr = o.systemd.RemoveUser(ctx, name)
 			case "nginx":
-				rbErr = o.nginx.RemoveConfig(ctx, name)
+				rbErr = o.proxy.RemoveConfig(ctx, name)
 			}
 			if rbErr != nil {
 				rollbackErrs = append(rollbackErrs, fmt.Sprintf("rollback %s: %v", step, rbErr))
@@ -170,11 +255,14 @@ func (o *Orchestrator) Deploy(ctx context.Context, req DeployRequest) (*DeployRe
 		return nil, fmt.Errorf("fetching binary: %w", err)
 	}
 	completed = append(completed, "binary")
+	if err := updateSymlink(name, version); err != nil {
+		return nil, rollback(fmt.Errorf("updating symlink: %w", err))
+	}
 
 	// Step 2: Create database (unless --no-db)
 	var dbResult *db.CreateResult
 	if !req.NoDB {
-		dbResult, err = o.db.CreateDatabase(ctx, name)
+		dbResult, err = dbProvider.CreateDatabase(ctx, name)
 		if err != nil {
 			return nil, rollback(fmt.Errorf("creating database: %w", err))
 		}
@@ -183,14 +271,22 @@ func (o *Orchestrator) Deploy(ctx context.Context, req DeployRequest) (*DeployRe
 
 	// Step 3: Write env/config file
 	envEntries := map[string]string{
-		"PORT": fmt.Sprintf("%d", port),
+		"STATE_DIR": systemd.StateDir(name),
+		"CACHE_DIR": systemd.CacheDir(name),
+		"LOGS_DIR":  systemd.LogsDir(name),
+	}
+	if port != 0 {
+		envEntries["PORT"] = fmt.Sprintf("%d", port)
 	}
 	if dbResult != nil {
-		envEntries["DB_HOST"] = o.cfg.MariaDB.Host
-		envEntries["DB_PORT"] = fmt.Sprintf("%d", o.cfg.MariaDB.Port)
+		envEntries["DB_HOST"] = dbResult.Host
+		envEntries["DB_PORT"] = fmt.Sprintf("%d", dbResult.Port)
 		envEntries["DB_NAME"] = dbResult.DBName
 		envEntries["DB_USER"] = dbResult.DBUser
 		envEntries["DB_PASSWORD"] = dbResult.Password
+		if dbResult.URL != "" {
+			envEntries["DATABASE_URL"] = dbResult.URL
+		}
 	}
 	for k, v := range req.ExtraEnv {
 		envEntries[k] = v
@@ -216,19 +312,25 @@ func (o *Orchestrator) Deploy(ctx context.Context, req DeployRequest) (*DeployRe
 	if err := o.systemd.CreateUser(ctx, name); err != nil {
 		return nil, rollback(fmt.Errorf("creating system user: %w", err))
 	}
-	if err := o.systemd.WriteUnit(ctx, name); err != nil {
-		return nil, rollback(fmt.Errorf("writing systemd unit: %w", err))
-	}
-	if err := o.systemd.DaemonReload(ctx); err != nil {
-		return nil, rollback(fmt.Errorf("daemon-reload: %w", err))
+	// Record the systemd step before writing anything so a partial set of
+	// unit files is cleaned up too
+	completed = append(completed, "systemd")
+	if err := o.writeUnits(ctx, svc); err != nil {
+		return nil, rollback(err)
 	}
-	if err := o.systemd.Enable(ctx, name); err != nil {
-		return nil, rollback(fmt.Errorf("enabling service: %w", err))
+	if err := o.enableUnits(ctx, svc); err != nil {
+		return nil, rollback(err)
 	}
-	if err := o.systemd.Start(ctx, name); err != nil {
+	if err := o.activate(ctx, svc); err != nil {
 		return nil, rollback(fmt.Errorf("starting service: %w", err))
 	}
-	completed = append(completed, "systemd")
+	// A listening socket proves nothing about the binary, so the first
+	// connection is made now rather than by the first client
+	if svc.SocketActivated {
+		if err := o.checkHealth(ctx, svc); err != nil {
+			return nil, rollback(fmt.Errorf("health check: %w", err))
+		}
+	}
 
 	// Step 5: Write nginx config (non-fatal per design)
 	result := &DeployResult{
@@ -238,23 +340,34 @@ func (o *Orchestrator) Deploy(ctx context.Context, req DeployRequest) (*DeployRe
 	}
 	if dbResult != nil {
 		result.DBName = dbResult.DBName
+		result.DBProvider = dbKind
+		svc.DBName = dbResult.DBName
+		svc.DBUser = dbResult.DBUser
+		svc.DBProvider = dbKind
 	}
 
-	if req.Route != "" {
-		params := nginx.RouteParams{
-			Name:       name,
-			RouteType:  routeType,
-			RouteValue: req.Route,
-			Port:       port,
-		}
-		if err := o.nginx.WriteConfig(ctx, params); err != nil {
+	if len(routes) > 0 {
+		if err := o.proxy.WriteConfig(ctx, o.routeParams(svc)); err != nil {
 			// Nginx failure is non-fatal — warn but continue
 			result.NginxSkip = true
 			result.NginxWarn = err.Error()
 		} else {
-			result.Route = req.Route
-			result.RouteType = routeType
+			result.Routes = routes
 			completed = append(completed, "nginx")
+
+			// Certificate failures are non-fatal too: the route stays on
+			// HTTP and the renewal timer retries
+			if svc.TLS == "acme" && !o.proxyIssuesCertificates() {
+				if err := o.enableRenewal(ctx); err != nil {
+					result.TLSWarn = err.Error()
+				}
+				if certs.NeedsRenewal(svc.TLSCert, time.Now()) {
+					if err := o.issueCertificate(ctx, svc); err != nil {
+						result.TLSWarn = err.Error()
+					}
+				}
+			}
+			result.TLS = o.servesTLS(svc)
 		}
 	} else {
 		result.NginxSkip = true
@@ -262,32 +375,22 @@ func (o *Orchestrator) Deploy(ctx context.Context, req DeployRequest) (*DeployRe
 
 	// Step 6: Record state
 	now := time.Now()
-	svc := &state.Service{
-		Name:       name,
-		Repo:       req.Repo,
-		Version:    version,
-		Port:       port,
-		RouteType:  routeType,
-		RouteValue: req.Route,
-		DBName:     result.DBName,
-		DBUser:     result.DBName, // gc_<name> for both
-		ExtraEnv:   req.ExtraEnv,
-		DeployedAt: now,
-		UpdatedAt:  now,
-	}
-	if dbResult != nil {
-		svc.DBUser = dbResult.DBUser
-	}
+	svc.DeployedAt = now
+	svc.UpdatedAt = now
 	if err := o.store.InsertService(ctx, svc); err != nil {
 		return nil, rollback(fmt.Errorf("recording state: %w", err))
 	}
 
+	detail := map[string]string{"port": fmt.Sprintf("%d", port)}
+	if req.Schedule != "" {
+		detail = map[string]string{"schedule": req.Schedule}
+	}
 	o.store.AppendHistory(ctx, &state.HistoryEntry{
 		Service:   name,
 		Action:    "deploy",
 		Version:   version,
 		Timestamp: now,
-		Detail:    map[string]string{"port": fmt.Sprintf("%d", port)},
+		Detail:    detail,
 	})
 
 	return result, nil
@@ -295,9 +398,14 @@ func (o *Orchestrator) Deploy(ctx context.Context, req DeployRequest) (*DeployRe
 
 // UpgradeRequest holds parameters for an upgrade.
 type UpgradeRequest struct {
-	Name    string
-	Version string // "latest" or explicit tag
-	Owner   string
+	Name        string
+	Version     string // "latest" or explicit tag
+	Owner       string
+	Observe     time.Duration // crash-loop watch after the health check, 0 = [upgrade] observe
+	MaxRestarts int           // restarts tolerated while observing, negative = [upgrade] max_restarts
+	NoObserve   bool          // skip the crash-loop watch
+	CheckRoutes bool          // roll back unless every route reaches the new version; also [upgrade] check_routes
+	Progress    func(msg string)
 }
 
 // UpgradeResult holds the output of a successful upgrade.
@@ -307,9 +415,11 @@ type UpgradeResult struct {
 	NewVersion  string
 	RolledBack  bool
 	RollbackMsg string
+	Journal     string // journal excerpt when a crash loop caused the rollback
 }
 
-// Upgrade executes the upgrade flow: fetch, stop, swap symlink, start, health check.
+// Upgrade executes the upgrade flow: fetch, pre-start, stop, swap symlink,
+// start, health check, then watch for a crash loop before committing.
 func (o *Orchestrator) Upgrade(ctx context.Context, req UpgradeRequest) (*UpgradeResult, error) {
 	svc, err := o.store.GetService(ctx, req.Name)
 	if err != nil {
@@ -340,29 +450,81 @@ func (o *Orchestrator) Upgrade(ctx context.Context, req UpgradeRequest) (*Upgrad
 	oldVersion := svc.Version
 
 	// Step 1: Fetch new binary
-	_, err = o.gh.DownloadAsset(ctx, owner, repo, version, req.Name)
+	binPath, err := o.gh.DownloadAsset(ctx, owner, repo, version, req.Name)
 	if err != nil {
 		return nil, fmt.Errorf("fetching binary: %w", err)
 	}
 
-	// Step 2: Stop service
-	if err := o.systemd.Stop(ctx, req.Name); err != nil {
-		return nil, fmt.Errorf("stopping service: %w", err)
+	// Run the pre-start command (e.g. migrations) with the new binary while
+	// the old version keeps serving; a failure leaves it untouched
+	if len(svc.PreStart) > 0 {
+		if err := o.systemd.RunOnce(ctx, unitParams(svc), binPath, svc.PreStart); err != nil {
+			os.Remove(binPath)
+			return nil, fmt.Errorf("pre-start command failed, %s still running %s: %w", req.Name, oldVersion, err)
+		}
+	}
+
+	// Step 2: Stop service. A scheduled job that is mid-run is left to
+	// finish on the old binary; the next run picks up the new one. Scaled
+	// services keep running and are restarted one instance at a time.
+	// While the service is down its routes serve the maintenance page.
+	endMaintenance := func() {}
+	if len(svc.Instances) == 0 {
+		endMaintenance = o.beginMaintenance(ctx, svc, req.Progress)
+	}
+	defer endMaintenance()
+	if svc.Schedule == "" && len(svc.Instances) == 0 {
+		if err := o.systemd.Stop(ctx, req.Name); err != nil {
+			return nil, fmt.Errorf("stopping service: %w", err)
+		}
+	}
+
+	// Re-render the unit from state so arguments and working directory
+	// recorded at deploy time always apply to the new version, which
+	// templates see as .Version
+	if err := o.writeUnits(ctx, atVersion(svc, version)); err != nil {
+		o.activate(ctx, svc)
+		return nil, err
+	}
+
+	// revert points the service back at the old binary and its units
+	revert := func() {
+		updateSymlink(req.Name, oldVersion)
+		o.writeUnits(ctx, svc)
 	}
 
 	// Step 3: Update symlink
 	if err := updateSymlink(req.Name, version); err != nil {
 		// Try to restart with old version
-		updateSymlink(req.Name, oldVersion)
-		o.systemd.Start(ctx, req.Name)
+		revert()
+		o.activate(ctx, svc)
 		return nil, fmt.Errorf("updating symlink: %w", err)
 	}
 
-	// Step 4: Start service
-	if err := o.systemd.Start(ctx, req.Name); err != nil {
+	// Step 4 (scaled): roll the instances over one by one, each passing its
+	// health check before the next is restarted
+	if len(svc.Instances) > 0 {
+		if port, err := o.rollInstances(ctx, svc); err != nil {
+			revert()
+			if failed, err := o.rollInstances(ctx, svc); err != nil {
+				return nil, fmt.Errorf("instance on port %d failed with %s; rolling back to %s failed on port %d: %w", port, version, oldVersion, failed, err)
+			}
+			return &UpgradeResult{
+				Name:        req.Name,
+				OldVersion:  oldVersion,
+				NewVersion:  version,
+				RolledBack:  true,
+				RollbackMsg: fmt.Sprintf("instance on port %d failed with %s, rolled back to %s", port, version, oldVersion),
+			}, nil
+		}
+	}
+
+	// Step 4: Start service (socket-activated services only need their
+	// socket listening and jobs their timer; the new binary runs next time)
+	if err := o.activate(ctx, svc); err != nil {
 		// Rollback: swap symlink back and restart
-		updateSymlink(req.Name, oldVersion)
-		o.systemd.Start(ctx, req.Name)
+		revert()
+		o.activate(ctx, svc)
 		return &UpgradeResult{
 			Name:        req.Name,
 			OldVersion:  oldVersion,
@@ -373,11 +535,11 @@ func (o *Orchestrator) Upgrade(ctx context.Context, req UpgradeRequest) (*Upgrad
 	}
 
 	// Step 5: Health check
-	if err := health.WaitForPort(svc.Port, 10*time.Second); err != nil {
+	if err := o.checkHealth(ctx, svc); err != nil {
 		// Rollback: swap symlink back, restart
 		o.systemd.Stop(ctx, req.Name)
-		updateSymlink(req.Name, oldVersion)
-		o.systemd.Start(ctx, req.Name)
+		revert()
+		o.activate(ctx, svc)
 		return &UpgradeResult{
 			Name:        req.Name,
 			OldVersion:  oldVersion,
@@ -386,11 +548,72 @@ func (o *Orchestrator) Upgrade(ctx context.Context, req UpgradeRequest) (*Upgrad
 			RollbackMsg: fmt.Sprintf("health check failed for %s, rolled back to %s", version, oldVersion),
 		}, nil
 	}
+	endMaintenance()
+
+	// Step 5b: Make sure the proxy reaches the new version on every route.
+	// A service in manual maintenance answers 503 by design, so is skipped.
+	if (req.CheckRoutes || o.cfg.Upgrade.CheckRoutes) && len(svc.Routes) > 0 && !svc.Maintenance {
+		if reason := unreachable(o.checkRoutes(ctx, svc)); reason != "" {
+			revert()
+			if err := o.restart(ctx, svc); err != nil {
+				return nil, fmt.Errorf("%s with %s; rolling back to %s failed: %w", reason, version, oldVersion, err)
+			}
+			return &UpgradeResult{
+				Name:        req.Name,
+				OldVersion:  oldVersion,
+				NewVersion:  version,
+				RolledBack:  true,
+				RollbackMsg: fmt.Sprintf("%s with %s, rolled back to %s", reason, version, oldVersion),
+			}, nil
+		}
+	}
 
-	// Step 6: Prune old versions (keep current + previous)
+	// Step 6: Watch for a crash loop. A service can pass its health check
+	// and still die a minute later; roll back if it keeps restarting.
+	window, maxRestarts := o.cfg.Upgrade.ObserveWindow, o.cfg.Upgrade.MaxRestarts
+	if req.Observe > 0 {
+		window = req.Observe
+	}
+	if req.MaxRestarts >= 0 {
+		maxRestarts = req.MaxRestarts
+	}
+	if req.NoObserve {
+		window = 0
+	}
+	if window > 0 && svc.Schedule == "" && req.Progress != nil {
+		req.Progress(fmt.Sprintf("watching %s for crash loops for %s", req.Name, window))
+	}
+	if loop := o.observe(ctx, svc, window, maxRestarts); loop != nil {
+		revert()
+		restartErr := o.restart(ctx, svc)
+		detail := map[string]string{"from": version, "reason": loop.Reason, "journal": loop.Journal}
+		if restartErr != nil {
+			detail["error"] = restartErr.Error()
+		}
+		o.store.AppendHistory(ctx, &state.HistoryEntry{
+			Service:   req.Name,
+			Action:    "rollback",
+			Version:   oldVersion,
+			Timestamp: time.Now(),
+			Detail:    detail,
+		})
+		if restartErr != nil {
+			return nil, fmt.Errorf("%s with %s; rolling back to %s failed: %w", loop.Reason, version, oldVersion, restartErr)
+		}
+		return &UpgradeResult{
+			Name:        req.Name,
+			OldVersion:  oldVersion,
+			NewVersion:  version,
+			RolledBack:  true,
+			RollbackMsg: fmt.Sprintf("%s with %s, rolled back to %s", loop.Reason, version, oldVersion),
+			Journal:     loop.Journal,
+		}, nil
+	}
+
+	// Step 7: Prune old versions (keep current + previous)
 	pruneVersions(req.Name, version, oldVersion)
 
-	// Step 7: Update state
+	// Step 8: Update state
 	now := time.Now()
 	svc.PrevVersion = oldVersion
 	svc.Version = version
@@ -427,19 +650,31 @@ func (o *Orchestrator) Rollback(ctx context.Context, name string) (string, error
 
 	prevVersion := svc.PrevVersion
 
-	// Stop service
-	if err := o.systemd.Stop(ctx, name); err != nil {
-		return "", fmt.Errorf("stopping service: %w", err)
+	// Stop service (scaled services are restarted instance by instance)
+	if svc.Schedule == "" && len(svc.Instances) == 0 {
+		if err := o.systemd.Stop(ctx, name); err != nil {
+			return "", fmt.Errorf("stopping service: %w", err)
+		}
+	}
+
+	if err := o.writeUnits(ctx, atVersion(svc, prevVersion)); err != nil {
+		o.activate(ctx, svc)
+		return "", err
 	}
 
 	// Swap symlink
 	if err := updateSymlink(name, prevVersion); err != nil {
-		o.systemd.Start(ctx, name)
+		o.writeUnits(ctx, svc)
+		o.activate(ctx, svc)
 		return "", fmt.Errorf("updating symlink: %w", err)
 	}
 
 	// Start service
-	if err := o.systemd.Start(ctx, name); err != nil {
+	if len(svc.Instances) > 0 {
+		if port, err := o.rollInstances(ctx, svc); err != nil {
+			return "", fmt.Errorf("restarting instance on port %d after rollback: %w", port, err)
+		}
+	} else if err := o.activate(ctx, svc); err != nil {
 		return "", fmt.Errorf("starting service after rollback: %w", err)
 	}
 
@@ -462,9 +697,10 @@ func (o *Orchestrator) Rollback(ctx context.Context, name string) (string, error
 
 // RemoveRequest holds parameters for a remove.
 type RemoveRequest struct {
-	Name   string
-	DropDB bool
-	Yes    bool // skip confirmation
+	Name      string
+	DropDB    bool
+	PurgeData bool // also delete the state, cache and logs directories
+	Yes       bool // skip confirmation
 }
 
 // RemoveStep is a callback for reporting progress.
@@ -477,21 +713,22 @@ func (o *Orchestrator) Remove(ctx context.Context, req RemoveRequest, step Remov
 		return fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", req.Name)
 	}
 
-	// Stop systemd service
+	// Stop, disable and remove units
 	step(fmt.Sprintf("Stopping gc-%s...", req.Name))
-	o.systemd.Stop(ctx, req.Name)
-
-	// Disable and remove unit
 	step("Removing systemd unit...")
-	o.systemd.Disable(ctx, req.Name)
-	o.systemd.RemoveUnit(req.Name)
-	o.systemd.DaemonReload(ctx)
+	o.removeUnits(ctx, svc)
 	o.systemd.RemoveUser(ctx, req.Name)
+	if svc.BackupSchedule != "" {
+		step("Removing backup timer...")
+		o.systemd.DisableBackupTimer(ctx, req.Name)
+	}
 
 	// Remove nginx config
-	if svc.RouteValue != "" {
+	if len(svc.Routes) > 0 {
 		step("Removing nginx config...")
-		o.nginx.RemoveConfig(ctx, req.Name)
+		o.proxy.RemoveConfig(ctx, req.Name)
+		o.removeCertificate(svc)
+		os.Remove(o.htpasswdPath(svc.Name))
 	}
 
 	// Remove env file and config directory
@@ -502,10 +739,26 @@ func (o *Orchestrator) Remove(ctx context.Context, req RemoveRequest, step Remov
 	binDir := filepath.Join(binBase, req.Name)
 	os.RemoveAll(binDir)
 
+	// Persistent data survives unless explicitly purged
+	if req.PurgeData {
+		step("Purging data directories...")
+		for _, dir := range append(systemd.DataDirs(req.Name), o.backupDir(req.Name)) {
+			if err := os.RemoveAll(dir); err != nil {
+				return fmt.Errorf("purging %s: %w", dir, err)
+			}
+		}
+	} else {
+		step(fmt.Sprintf("Keeping data in %s (use --purge-data to delete)", systemd.StateDir(req.Name)))
+	}
+
 	// Drop database if requested
 	if req.DropDB && svc.DBName != "" {
-		step(fmt.Sprintf("Dropping database %s...", svc.DBName))
-		if err := o.db.DropDatabase(ctx, req.Name); err != nil {
+		step(fmt.Sprintf("Dropping %s database %s...", svc.DBProvider, svc.DBName))
+		provider, err := o.db.Provider(svc.DBProvider)
+		if err != nil {
+			return fmt.Errorf("dropping database: %w", err)
+		}
+		if err := provider.DropDatabase(ctx, req.Name); err != nil {
 			return fmt.Errorf("dropping database: %w", err)
 		}
 	}
@@ -519,6 +772,9 @@ func (o *Orchestrator) Remove(ctx context.Context, req RemoveRequest, step Remov
 		Timestamp: now,
 	})
 	o.store.DeleteService(ctx, req.Name)
+	if svc.TLS == "acme" {
+		o.disableUnusedRenewal(ctx)
+	}
 
 	return nil
 }
@@ -538,8 +794,225 @@ func (o *Orchestrator) IsActive(ctx context.Context, name string) (bool, error)
 	return o.systemd.IsActive(ctx, name)
 }
 
+// enableUnits enables whichever unit starts the service at boot: its
+// timer for scheduled jobs, its socket when socket-activated, or itself.
+func (o *Orchestrator) enableUnits(ctx context.Context, svc *state.Service) error {
+	var err error
+	switch {
+	case len(svc.Instances) > 0:
+		for _, port := range svc.Instances {
+			if err = o.systemd.EnableInstance(ctx, svc.Name, port); err != nil {
+				break
+			}
+		}
+	case svc.Schedule != "":
+		err = o.systemd.EnableTimer(ctx, svc.Name)
+	case svc.SocketActivated:
+		err = o.systemd.EnableSocket(ctx, svc.Name)
+	default:
+		err = o.systemd.Enable(ctx, svc.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("enabling service: %w", err)
+	}
+	return nil
+}
+
+// activate starts a service the way it runs in production: through its
+// timer or socket when it has one, as its instances when scaled, directly
+// otherwise.
+func (o *Orchestrator) activate(ctx context.Context, svc *state.Service) error {
+	switch {
+	case len(svc.Instances) > 0:
+		for _, port := range svc.Instances {
+			if err := o.systemd.StartInstance(ctx, svc.Name, port); err != nil {
+				return err
+			}
+		}
+		return nil
+	case svc.Schedule != "":
+		return o.systemd.StartTimer(ctx, svc.Name)
+	case svc.SocketActivated:
+		return o.systemd.StartSocket(ctx, svc.Name)
+	default:
+		return o.systemd.Start(ctx, svc.Name)
+	}
+}
+
+// checkHealth confirms a freshly started service is accepting connections.
+// A socket-activated service's socket accepts connections whether or not
+// the binary works, so the connection is only the trigger: the check then
+// waits for systemd to report gc-<name>.service active. Scheduled jobs
+// have no port to check.
+func (o *Orchestrator) checkHealth(ctx context.Context, svc *state.Service) error {
+	if svc.Schedule != "" {
+		return nil
+	}
+	if svc.SocketActivated {
+		if err := health.WaitForPort(svc.Port, 10*time.Second); err != nil {
+			return err
+		}
+		return o.waitForActive(ctx, svc.Name, 10*time.Second)
+	}
+	if len(svc.Instances) > 0 {
+		for _, port := range svc.Instances {
+			if err := health.WaitForPort(port, 10*time.Second); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return health.WaitForPort(svc.Port, 10*time.Second)
+}
+
+// waitForActive polls until the service unit is active, as it should be
+// once a connection to its socket has started it.
+func (o *Orchestrator) waitForActive(ctx context.Context, name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if active, _ := o.systemd.IsActive(ctx, name); active {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("gc-%s.service not active %s after a connection to its socket; journal:\n%s", name, timeout, o.unitJournal(ctx, name, 0))
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// rollInstances restarts the instances of a scaled service one at a time,
+// waiting for each to pass its health check before moving on so the rest
+// keep serving. It returns the port of the instance that failed.
+func (o *Orchestrator) rollInstances(ctx context.Context, svc *state.Service) (int, error) {
+	for _, port := range svc.Instances {
+		o.systemd.StopInstance(ctx, svc.Name, port)
+		if err := o.systemd.StartInstance(ctx, svc.Name, port); err != nil {
+			return port, err
+		}
+		if err := health.WaitForPort(port, 10*time.Second); err != nil {
+			return port, err
+		}
+	}
+	return 0, nil
+}
+
+// removeUnits stops, disables and deletes every unit file of a service.
+// Timers and sockets go first so they cannot start the service again.
+// Errors are ignored: this runs during cleanup, when units may be missing.
+func (o *Orchestrator) removeUnits(ctx context.Context, svc *state.Service) {
+	if svc.Schedule != "" {
+		o.systemd.StopTimer(ctx, svc.Name)
+		o.systemd.DisableTimer(ctx, svc.Name)
+		o.systemd.RemoveTimer(svc.Name)
+	}
+	if svc.SocketActivated {
+		o.systemd.StopSocket(ctx, svc.Name)
+		o.systemd.DisableSocket(ctx, svc.Name)
+		o.systemd.RemoveSocket(svc.Name)
+	}
+	for _, port := range svc.Instances {
+		o.systemd.StopInstance(ctx, svc.Name, port)
+		o.systemd.DisableInstance(ctx, svc.Name, port)
+	}
+	if len(svc.Instances) > 0 {
+		o.systemd.RemoveTemplate(svc.Name)
+	}
+	o.systemd.Stop(ctx, svc.Name)
+	o.systemd.Disable(ctx, svc.Name)
+	o.systemd.RemoveUnit(svc.Name)
+	o.systemd.RemoveDropIns(svc.Name)
+	o.systemd.DaemonReload(ctx)
+}
+
+// restart applies changed unit configuration the way the service runs:
+// scaled instances one at a time, socket-activated services on their next
+// connection and scheduled jobs on their next run.
+func (o *Orchestrator) restart(ctx context.Context, svc *state.Service) error {
+	switch {
+	case svc.Schedule != "":
+		return nil
+	case len(svc.Instances) > 0:
+		_, err := o.rollInstances(ctx, svc)
+		return err
+	case svc.SocketActivated:
+		return o.systemd.Stop(ctx, svc.Name)
+	}
+	o.systemd.Stop(ctx, svc.Name)
+	if err := o.systemd.Start(ctx, svc.Name); err != nil {
+		return err
+	}
+	return o.checkHealth(ctx, svc)
+}
+
 // --- helpers ---
 
+// unitParams builds the systemd unit parameters recorded for a service.
+func unitParams(svc *state.Service) systemd.ServiceParams {
+	return systemd.ServiceParams{
+		Name:            svc.Name,
+		Args:            svc.Args,
+		WorkingDir:      svc.WorkDir,
+		Hardening:       svc.Hardening,
+		SocketActivated: svc.SocketActivated,
+		Scheduled:       svc.Schedule != "",
+		DBProvider:      svc.DBProvider,
+		Version:         svc.Version,
+		Repo:            svc.Repo,
+		Port:            svc.Port,
+		Env:             templateEnv(svc),
+	}
+}
+
+// atVersion returns a copy of svc at the given version, so its units can be
+// rendered for a version before state records it.
+func atVersion(svc *state.Service, version string) *state.Service {
+	target := *svc
+	target.Version = version
+	return &target
+}
+
+// templateEnv is the env metadata exposed to override templates: the
+// service's --env values and its port, but no database credentials.
+func templateEnv(svc *state.Service) map[string]string {
+	env := make(map[string]string, len(svc.ExtraEnv)+1)
+	for k, v := range svc.ExtraEnv {
+		env[k] = v
+	}
+	if svc.Port != 0 {
+		env["PORT"] = strconv.Itoa(svc.Port)
+	}
+	return env
+}
+
+// writeUnits writes a service's unit files from state and reloads systemd.
+func (o *Orchestrator) writeUnits(ctx context.Context, svc *state.Service) error {
+	if err := o.systemd.WriteUnit(ctx, unitParams(svc)); err != nil {
+		return fmt.Errorf("writing systemd unit: %w", err)
+	}
+	if svc.SocketActivated {
+		if err := o.systemd.WriteSocket(ctx, systemd.SocketParams{Name: svc.Name, Port: svc.Port}); err != nil {
+			return fmt.Errorf("writing systemd socket: %w", err)
+		}
+	}
+	if svc.Schedule != "" {
+		if err := o.systemd.WriteTimer(ctx, systemd.TimerParams{Name: svc.Name, Schedule: svc.Schedule}); err != nil {
+			return fmt.Errorf("writing systemd timer: %w", err)
+		}
+	}
+	if len(svc.Instances) > 0 {
+		if err := o.systemd.WriteTemplate(ctx, unitParams(svc)); err != nil {
+			return fmt.Errorf("writing systemd template unit: %w", err)
+		}
+	}
+	if err := o.writeOverrides(ctx, svc); err != nil {
+		return fmt.Errorf("writing systemd overrides: %w", err)
+	}
+	if err := o.systemd.DaemonReload(ctx); err != nil {
+		return fmt.Errorf("daemon-reload: %w", err)
+	}
+	return nil
+}
+
 func updateSymlink(name, version string) error {
 	dir := filepath.Join(binBase, name)
 	symlinkPath := filepath.Join(dir, name)
@@ -558,6 +1031,9 @@ func removeEnvDir(name string) error {
 	return os.RemoveAll(filepath.Join(configBase, name))
 }
 
+// pruneVersions removes versioned binaries other than current and previous.
+// Only <name>-<version> entries are considered, so anything else left in the
+// bin directory is never deleted.
 func pruneVersions(name, current, previous string) {
 	dir := filepath.Join(binBase, name)
 	entries, err := os.ReadDir(dir)
@@ -566,9 +1042,8 @@ func pruneVersions(name, current, previous string) {
 	}
 
 	keep := map[string]bool{
-		name:                                    true, // symlink
-		fmt.Sprintf("%s-%s", name, current):     true,
-		fmt.Sprintf("%s-%s", name, previous):    true,
+		fmt.Sprintf("%s-%s", name, current):  true,
+		fmt.Sprintf("%s-%s", name, previous): true,
 	}
 
 	// Sort to get deterministic pruning order
@@ -577,6 +1052,9 @@ func pruneVersions(name, current, previous string) {
 	})
 
 	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), name+"-") {
+			continue
+		}
 		if !keep[e.Name()] {
 			os.Remove(filepath.Join(dir, e.Name()))
 		}
@@ -0,0 +1,86 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ecairns22/GopherCaptain/internal/config"
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+	"github.com/ecairns22/GopherCaptain/internal/state"
+	"github.com/ecairns22/GopherCaptain/internal/systemd"
+	"github.com/ecairns22/GopherCaptain/internal/templates"
+)
+
+func testStore(t *testing.T) *state.Store {
+	t.Helper()
+	store, err := state.Open(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("opening state: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestUnitsRenderTargetVersion(t *testing.T) {
+	ctx := context.Background()
+	unitDir, tmplDir := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(tmplDir, templates.Unit), []byte("[Service]\nExecStart=/usr/local/bin/wrap {{.Name}} {{.Version}}\n"), 0644)
+
+	sys := systemd.NewWithController(runner.NewFakeRunner(), systemd.NewFakeController(), unitDir).WithTemplates(templates.New(tmplDir))
+	o := New(&config.Config{}, testStore(t), nil, sys, nil, nil)
+	svc := &state.Service{Name: "api", Version: "v1.0.0", Port: 3000}
+
+	unit := func() string {
+		data, err := os.ReadFile(filepath.Join(unitDir, "gc-api.service"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// Upgrading renders the units for the new version before state has it
+	if err := o.writeUnits(ctx, atVersion(svc, "v2.0.0")); err != nil {
+		t.Fatalf("writeUnits: %v", err)
+	}
+	if got := unit(); !strings.Contains(got, "wrap api v2.0.0") {
+		t.Errorf("upgraded unit should render the new version, got:\n%s", got)
+	}
+	if svc.Version != "v1.0.0" {
+		t.Errorf("atVersion changed the service to %s", svc.Version)
+	}
+
+	// Reverting renders the old version again
+	if err := o.writeUnits(ctx, svc); err != nil {
+		t.Fatalf("writeUnits: %v", err)
+	}
+	if got := unit(); !strings.Contains(got, "wrap api v1.0.0") {
+		t.Errorf("reverted unit should render the old version, got:\n%s", got)
+	}
+}
+
+func TestScaleRefusesConfigFile(t *testing.T) {
+	ctx := context.Background()
+	store := testStore(t)
+	ctl := systemd.NewFakeController()
+	sys := systemd.NewWithController(runner.NewFakeRunner(), ctl, t.TempDir())
+	o := New(&config.Config{}, store, nil, sys, nil, nil)
+
+	svc := &state.Service{Name: "api", Repo: "acme/api", Version: "v1.0.0", Port: 3000, ConfigFile: true}
+	if err := store.InsertService(ctx, svc); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := o.Scale(ctx, ScaleRequest{Name: "api", Instances: 2})
+	if err == nil || !strings.Contains(err.Error(), "--config-file") {
+		t.Fatalf("expected scaling a --config-file service to be refused, got %v", err)
+	}
+	if len(ctl.Calls) > 0 {
+		t.Errorf("refused scale should not touch units, calls: %v", ctl.Calls)
+	}
+	if got, _ := store.GetService(ctx, "api"); len(got.Instances) > 0 {
+		t.Errorf("refused scale recorded instances %v", got.Instances)
+	}
+}
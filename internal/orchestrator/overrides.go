@@ -0,0 +1,93 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ecairns22/GopherCaptain/internal/state"
+	"github.com/ecairns22/GopherCaptain/internal/systemd"
+)
+
+// SetOverride records a systemd drop-in for a service, writes it and
+// restarts the service so it takes effect. Drop-ins live beside the
+// generated unit and are rewritten from state with it, so they survive
+// upgrades and rollbacks.
+func (o *Orchestrator) SetOverride(ctx context.Context, name, dropIn, content string) error {
+	svc, err := o.store.GetService(ctx, name)
+	if err != nil {
+		return fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", name)
+	}
+	if err := systemd.ValidateDropInName(dropIn); err != nil {
+		return err
+	}
+
+	if err := o.store.SetOverride(ctx, &state.Override{Service: name, Name: dropIn, Content: content}); err != nil {
+		return err
+	}
+	if err := o.systemd.WriteDropIn(ctx, name, dropIn, content); err != nil {
+		return err
+	}
+	return o.applyOverrides(ctx, svc, "override "+dropIn)
+}
+
+// RemoveOverride deletes a service's drop-in and restarts the service.
+func (o *Orchestrator) RemoveOverride(ctx context.Context, name, dropIn string) error {
+	svc, err := o.store.GetService(ctx, name)
+	if err != nil {
+		return fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", name)
+	}
+
+	if err := o.store.DeleteOverride(ctx, name, dropIn); err != nil {
+		if errors.Is(err, state.ErrNotFound) {
+			return fmt.Errorf("service %q has no override %q", name, dropIn)
+		}
+		return err
+	}
+	if err := o.systemd.RemoveDropIn(name, dropIn); err != nil {
+		return err
+	}
+	return o.applyOverrides(ctx, svc, "remove override "+dropIn)
+}
+
+// Overrides returns a service's drop-in overrides.
+func (o *Orchestrator) Overrides(ctx context.Context, name string) ([]*state.Override, error) {
+	if _, err := o.store.GetService(ctx, name); err != nil {
+		return nil, fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", name)
+	}
+	return o.store.Overrides(ctx, name)
+}
+
+// applyOverrides reloads systemd and restarts the service after its
+// drop-ins changed, and records the change in history.
+func (o *Orchestrator) applyOverrides(ctx context.Context, svc *state.Service, detail string) error {
+	if err := o.systemd.DaemonReload(ctx); err != nil {
+		return fmt.Errorf("daemon-reload: %w", err)
+	}
+	if err := o.restart(ctx, svc); err != nil {
+		return fmt.Errorf("restarting %s: %w", svc.Name, err)
+	}
+	o.store.AppendHistory(ctx, &state.HistoryEntry{
+		Service:   svc.Name,
+		Action:    "configure",
+		Version:   svc.Version,
+		Timestamp: time.Now(),
+		Detail:    map[string]string{"change": detail},
+	})
+	return nil
+}
+
+// writeOverrides rewrites every drop-in recorded for a service.
+func (o *Orchestrator) writeOverrides(ctx context.Context, svc *state.Service) error {
+	overrides, err := o.store.Overrides(ctx, svc.Name)
+	if err != nil {
+		return err
+	}
+	for _, ov := range overrides {
+		if err := o.systemd.WriteDropIn(ctx, svc.Name, ov.Name, ov.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
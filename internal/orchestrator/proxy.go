@@ -0,0 +1,43 @@
+package orchestrator
+
+import (
+	"context"
+
+	"github.com/ecairns22/GopherCaptain/internal/nginx"
+	"github.com/ecairns22/GopherCaptain/internal/state"
+)
+
+// Proxy is the reverse proxy that serves the routes of services, selected
+// by [proxy] kind. nginx.Manager is the default; caddy.Manager and
+// haproxy.Manager render the same route parameters for their proxy.
+type Proxy interface {
+	// WriteConfig renders a service's routes, tests the whole config and
+	// reloads the proxy. If the test fails the service's previous config
+	// is restored.
+	WriteConfig(ctx context.Context, params nginx.RouteParams) error
+	// RemoveConfig removes a service's routes and reloads the proxy.
+	RemoveConfig(ctx context.Context, name string) error
+	Test(ctx context.Context) error
+	Reload(ctx context.Context) error
+	// ForeignServerNames returns the hosts of sites the proxy serves that
+	// GopherCaptain did not write, for conflict checks.
+	ForeignServerNames() []nginx.ServerName
+}
+
+var _ Proxy = (*nginx.Manager)(nil)
+
+// proxyIssuesCertificates reports whether the proxy obtains ACME
+// certificates itself. Caddy does, so routes there skip GopherCaptain's
+// issuer and renewal timer.
+func (o *Orchestrator) proxyIssuesCertificates() bool {
+	return o.cfg.Proxy.Kind == "caddy"
+}
+
+// servesTLS reports whether a service's subdomain routes are served over
+// HTTPS: with a certificate in place, or by a proxy issuing its own.
+func (o *Orchestrator) servesTLS(svc *state.Service) bool {
+	if svc.TLS == "acme" && o.proxyIssuesCertificates() {
+		return true
+	}
+	return o.routeParams(svc).TLS()
+}
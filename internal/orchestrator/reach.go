@@ -0,0 +1,150 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ecairns22/GopherCaptain/internal/health"
+	"github.com/ecairns22/GopherCaptain/internal/nginx"
+	"github.com/ecairns22/GopherCaptain/internal/state"
+)
+
+// routeCheckTimeout bounds each request of a route check.
+const routeCheckTimeout = 5 * time.Second
+
+// RouteCheck is the outcome of a request to one of a service's routes
+// through the local proxy.
+type RouteCheck struct {
+	Route    string
+	URL      string
+	Status   int
+	Latency  time.Duration
+	Upstream string // address nginx forwarded to; empty if it answered itself
+	Reached  bool   // the response came from this service
+	// Protected is set when the proxy refused the request under the
+	// service's basic auth or allow/deny rules, which this host's probe is
+	// subject to like any client. Whether the route reaches the service is
+	// then unknown, so the route does not count as unreachable.
+	Protected bool
+	Err       error
+}
+
+// CheckRoutes requests each of a service's routes through the proxy on
+// this host.
+func (o *Orchestrator) CheckRoutes(ctx context.Context, name string) ([]RouteCheck, error) {
+	svc, err := o.store.GetService(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", name)
+	}
+	return o.checkRoutes(ctx, svc), nil
+}
+
+// checkRoutes sends a GET for each route with its Host header, over https
+// when the route is served with TLS. nginx names the upstream it used in
+// nginx.UpstreamHeader, so a request answered by another service, or by
+// nginx itself, does not count as reached. Caddy and HAProxy do not say,
+// so there any response but a 502, 503 or 504 does.
+func (o *Orchestrator) checkRoutes(ctx context.Context, svc *state.Service) []RouteCheck {
+	scheme := "http"
+	if o.servesTLS(svc) {
+		scheme = "https"
+	}
+	params := o.routeParams(svc)
+	protected := params.AuthFile != "" || len(params.Allow) > 0 || len(params.Deny) > 0
+	var checks []RouteCheck
+	for _, r := range params.Routes {
+		check := RouteCheck{Route: r.Value}
+		if r.Type == "subdomain" {
+			check.URL = scheme + "://" + r.Value + "/"
+		} else {
+			check.URL = "http://" + r.Value
+		}
+		probe, err := health.ProbeRoute(ctx, check.URL, routeCheckTimeout)
+		if err != nil {
+			check.Err = err
+			checks = append(checks, check)
+			continue
+		}
+		check.Status, check.Latency = probe.Status, probe.Latency
+		if o.cfg.Proxy.Kind == "nginx" {
+			check.Upstream = probe.Header.Get(nginx.UpstreamHeader)
+		}
+		check.Reached, check.Protected = judgeProbe(o.cfg.Proxy.Kind, check.Status, check.Upstream, servicePorts(svc), protected)
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// judgeProbe decides from a route probe's status and upstream whether it
+// reached a service listening on ports, or was refused by the access
+// control of a protected service before it could.
+func judgeProbe(proxyKind string, status int, upstream string, ports []int, protected bool) (reached, refused bool) {
+	if proxyKind == "nginx" {
+		if slices.Contains(ports, upstreamPort(upstream)) {
+			return true, false
+		}
+		// Refusals come from nginx itself, so without an upstream
+		if upstream != "" {
+			return false, false
+		}
+	}
+	if protected && (status == http.StatusUnauthorized || status == http.StatusForbidden) {
+		return false, true
+	}
+	if proxyKind == "nginx" {
+		return false, false
+	}
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return false, false
+	}
+	return true, false
+}
+
+// unreachable returns a description of the first route check that failed,
+// or "" when every route reached the service or was refused by its access
+// control.
+func unreachable(checks []RouteCheck) string {
+	for _, c := range checks {
+		switch {
+		case c.Protected:
+		case c.Err != nil:
+			return fmt.Sprintf("route %s unreachable: %v", c.Route, c.Err)
+		case !c.Reached && c.Upstream != "":
+			return fmt.Sprintf("route %s reached %s instead of the service (status %d)", c.Route, c.Upstream, c.Status)
+		case !c.Reached:
+			return fmt.Sprintf("route %s did not reach the service (status %d)", c.Route, c.Status)
+		}
+	}
+	return ""
+}
+
+// servicePorts returns the ports a service listens on.
+func servicePorts(svc *state.Service) []int {
+	if len(svc.Instances) > 0 {
+		return svc.Instances
+	}
+	return []int{svc.Port}
+}
+
+// upstreamPort returns the port of the upstream that finally answered, the
+// last of those nginx lists after retries ("127.0.0.1:3000, 127.0.0.1:3004"),
+// or 0 if there is none.
+func upstreamPort(upstream string) int {
+	if upstream == "" {
+		return 0
+	}
+	fields := strings.FieldsFunc(upstream, func(r rune) bool { return r == ',' || r == ' ' })
+	_, port, err := net.SplitHostPort(fields[len(fields)-1])
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(port)
+	return n
+}
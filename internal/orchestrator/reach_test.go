@@ -0,0 +1,52 @@
+package orchestrator
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestJudgeProbe(t *testing.T) {
+	ports := []int{3000}
+	tests := []struct {
+		name             string
+		kind             string
+		status           int
+		upstream         string
+		protected        bool
+		reached, refused bool
+	}{
+		{"forwarded", "nginx", http.StatusOK, "127.0.0.1:3000", false, true, false},
+		{"retried onto the service", "nginx", http.StatusOK, "127.0.0.1:3004, 127.0.0.1:3000", false, true, false},
+		{"service answers 401 itself", "nginx", http.StatusUnauthorized, "127.0.0.1:3000", true, true, false},
+		{"other service", "nginx", http.StatusOK, "127.0.0.1:3001", false, false, false},
+		{"other service refuses", "nginx", http.StatusForbidden, "127.0.0.1:3001", true, false, false},
+		{"basic auth", "nginx", http.StatusUnauthorized, "", true, false, true},
+		{"allow list", "nginx", http.StatusForbidden, "", true, false, true},
+		{"403 without access control", "nginx", http.StatusForbidden, "", false, false, false},
+		{"nginx 502", "nginx", http.StatusBadGateway, "", true, false, false},
+		{"caddy ok", "caddy", http.StatusOK, "", false, true, false},
+		{"caddy basic auth", "caddy", http.StatusUnauthorized, "", true, false, true},
+		{"haproxy 503", "haproxy", http.StatusServiceUnavailable, "", false, false, false},
+	}
+	for _, tt := range tests {
+		reached, refused := judgeProbe(tt.kind, tt.status, tt.upstream, ports, tt.protected)
+		if reached != tt.reached || refused != tt.refused {
+			t.Errorf("%s: judgeProbe = reached %v, refused %v; want %v, %v", tt.name, reached, refused, tt.reached, tt.refused)
+		}
+	}
+}
+
+func TestUnreachableSkipsProtectedRoutes(t *testing.T) {
+	checks := []RouteCheck{
+		{Route: "admin.example.com", Status: http.StatusUnauthorized, Protected: true},
+		{Route: "api.example.com", Status: http.StatusOK, Reached: true},
+	}
+	if got := unreachable(checks); got != "" {
+		t.Errorf("unreachable = %q, want none for a route refused by basic auth", got)
+	}
+
+	checks = append(checks, RouteCheck{Route: "example.com/api", Status: http.StatusNotFound})
+	if got := unreachable(checks); got == "" {
+		t.Error("a route nginx answered itself should be unreachable")
+	}
+}
@@ -0,0 +1,193 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ecairns22/GopherCaptain/internal/nginx"
+	"github.com/ecairns22/GopherCaptain/internal/state"
+)
+
+// qualifyRoute checks a route and gives a path route its host: path routes
+// are served by a shared server block per host, so "/api" becomes
+// "<default_host>/api". The host is recorded so a later change of
+// default_host does not move existing routes.
+func (o *Orchestrator) qualifyRoute(routeType, route string) (string, error) {
+	if route == "" || routeType != "path" {
+		return route, nil
+	}
+	host, path := nginx.SplitRoute(route)
+	if path == "" {
+		return "", fmt.Errorf("path route %q must contain a path, e.g. example.com/api", route)
+	}
+	if host != "" {
+		return route, nil
+	}
+	if o.cfg.Nginx.DefaultHost == "" {
+		return "", fmt.Errorf("path route %q needs a host: use --route example.com%s or set [nginx] default_host", route, route)
+	}
+	return o.cfg.Nginx.DefaultHost + path, nil
+}
+
+// qualifyRoutes checks the routes given at deploy and qualifies each one.
+// The route type applies to all of them; if empty it is inferred per route.
+func (o *Orchestrator) qualifyRoutes(routeType string, values []string) ([]state.Route, error) {
+	var routes []state.Route
+	seen := make(map[string]bool)
+	for _, value := range values {
+		typ := routeType
+		if typ == "" {
+			typ = nginx.InferRouteType(value)
+		}
+		route, err := o.qualifyRoute(typ, value)
+		if err != nil {
+			return nil, err
+		}
+		if seen[route] {
+			return nil, fmt.Errorf("route %q given more than once", route)
+		}
+		seen[route] = true
+		routes = append(routes, state.Route{Type: typ, Value: route})
+	}
+	return routes, nil
+}
+
+// checkConflicts fails if any of a service's routes would be served
+// ambiguously alongside another of its routes, a route of another service
+// or a server block in sites-enabled that GopherCaptain does not manage.
+// A service's own subdomain and path routes on one host would still give
+// nginx two server blocks with the same server_name.
+func (o *Orchestrator) checkConflicts(ctx context.Context, name string, routes []state.Route) error {
+	if len(routes) == 0 {
+		return nil
+	}
+	for i, r := range routes {
+		for _, other := range routes[i+1:] {
+			if nginx.Conflict(nginx.Route{Type: r.Type, Value: r.Value}, nginx.Route{Type: other.Type, Value: other.Value}) {
+				return fmt.Errorf("route %q conflicts with route %q of the same service", r.Value, other.Value)
+			}
+		}
+	}
+	services, err := o.store.ListServices(ctx)
+	if err != nil {
+		return err
+	}
+	foreign := o.proxy.ForeignServerNames()
+	for _, r := range routes {
+		route := nginx.Route{Type: r.Type, Value: r.Value}
+		for _, other := range services {
+			if other.Name == name {
+				continue
+			}
+			for _, theirs := range o.routeParams(other).Routes {
+				if nginx.Conflict(route, theirs) {
+					return fmt.Errorf("route %q conflicts with route %q of service %q", r.Value, theirs.Value, other.Name)
+				}
+			}
+		}
+		for _, s := range foreign {
+			if nginx.MatchServerName(s.Name, route.Host()) {
+				return fmt.Errorf("route %q conflicts with server_name %s in %s, which gophercaptain does not manage", r.Value, s.Name, s.File)
+			}
+		}
+	}
+	return nil
+}
+
+// checkProxyOptions validates a service's proxy protocol and tuning. gRPC
+// needs HTTP/2 from the client, which nginx only offers on the HTTPS server
+// of subdomain routes; path routes share a plain HTTP host server.
+func checkProxyOptions(svc *state.Service) error {
+	if err := nginx.ValidateProxyOptions(svc.Proto, svc.Keepalive, svc.ReadTimeout, svc.MaxBodySize); err != nil {
+		return err
+	}
+	if err := nginx.ValidateAccess(svc.Allow, svc.Deny, svc.RateLimit, svc.RateBurst); err != nil {
+		return err
+	}
+	custom := (svc.Proto != "" && svc.Proto != "http") || svc.Keepalive > 0 || svc.ReadTimeout != "" || svc.MaxBodySize != "" ||
+		len(svc.Allow) > 0 || len(svc.Deny) > 0 || svc.RateLimit != ""
+	if custom && len(svc.Routes) == 0 {
+		return fmt.Errorf("proxy and access options such as --proto, --allow or --rate-limit require --route")
+	}
+	if svc.Proto != "grpc" {
+		return nil
+	}
+	for _, r := range svc.Routes {
+		if r.Type != "subdomain" {
+			return fmt.Errorf("gRPC route %q must be a subdomain route", r.Value)
+		}
+	}
+	if svc.TLS == "" {
+		return fmt.Errorf("gRPC routes need TLS: add --tls or --tls-cert/--tls-key")
+	}
+	return nil
+}
+
+// domains returns the hosts of a service's subdomain routes, which one
+// certificate covers.
+func domains(svc *state.Service) []string {
+	var names []string
+	for _, r := range svc.Routes {
+		if r.Type == "subdomain" {
+			names = append(names, r.Value)
+		}
+	}
+	return names
+}
+
+// routeParams builds the nginx parameters for a routed service. An ACME
+// route answers challenges on port 80, and is only served over HTTPS once
+// its certificate has been issued.
+func (o *Orchestrator) routeParams(svc *state.Service) nginx.RouteParams {
+	params := nginx.RouteParams{
+		Name:        svc.Name,
+		Port:        svc.Port,
+		Ports:       svc.Instances,
+		Proto:       svc.Proto,
+		Keepalive:   svc.Keepalive,
+		ReadTimeout: svc.ReadTimeout,
+		MaxBodySize: svc.MaxBodySize,
+		Allow:       svc.Allow,
+		Deny:        svc.Deny,
+		RateLimit:   svc.RateLimit,
+		RateBurst:   svc.RateBurst,
+		Maintenance: svc.Maintenance,
+		RetryAfter:  o.cfg.Nginx.RetryAfter,
+		AccessLog:   nginx.AccessLogPath(o.cfg.Nginx.LogDir, svc.Name),
+		Version:     svc.Version,
+		Repo:        svc.Repo,
+		Env:         templateEnv(svc),
+	}
+	// Basic auth is on while the service has users, i.e. an htpasswd file
+	if path := o.htpasswdPath(svc.Name); pathExists(path) {
+		params.AuthFile = path
+	}
+	// A missing page falls back to nginx's own 503 rather than a 404
+	if page := o.cfg.Nginx.MaintenancePage; page != "" && pathExists(page) {
+		params.MaintenancePage = page
+	}
+	for _, r := range svc.Routes {
+		// Path routes recorded before hosts were required
+		if route, err := o.qualifyRoute(r.Type, r.Value); err == nil {
+			r.Value = route
+		}
+		params.Routes = append(params.Routes, nginx.Route{Type: r.Type, Value: r.Value})
+	}
+	switch svc.TLS {
+	case "acme":
+		params.ACMEWebroot = o.cfg.Nginx.ACMEWebroot
+		if !pathExists(svc.TLSCert) {
+			break
+		}
+		fallthrough
+	case "manual":
+		params.TLSCert, params.TLSKey = svc.TLSCert, svc.TLSKey
+	}
+	return params
+}
+
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
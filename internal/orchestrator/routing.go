@@ -0,0 +1,211 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ecairns22/GopherCaptain/internal/certs"
+	"github.com/ecairns22/GopherCaptain/internal/nginx"
+	"github.com/ecairns22/GopherCaptain/internal/state"
+)
+
+// RouteRequest holds the parameters for changing a service's routes.
+type RouteRequest struct {
+	Name      string
+	Routes    []string // replace all routes with these
+	RouteType string   // "subdomain" or "path" for every route, empty = inferred per route
+	TLS       bool
+	NoTLS     bool
+	TLSCert   string
+	TLSKey    string
+}
+
+// RouteResult reports a service's routes after a change.
+type RouteResult struct {
+	Name     string
+	Port     int
+	Routes   []state.Route
+	TLS      bool      // subdomain routes are served over HTTPS
+	TLSMode  string    // "acme", "manual" or empty
+	NotAfter time.Time // certificate expiry, zero if none is served
+	TLSWarn  string    // set if the certificate could not be issued (non-fatal)
+}
+
+// SetRoutes replaces the routes of a service, e.g. to route one deployed
+// without routes or whose nginx step failed. The new config is tested
+// before state changes; if nginx rejects it the old config keeps serving.
+// Without TLS flags, subdomain routes keep the service's current TLS mode.
+func (o *Orchestrator) SetRoutes(ctx context.Context, req RouteRequest) (*RouteResult, error) {
+	svc, err := o.store.GetService(ctx, req.Name)
+	if err != nil {
+		return nil, fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", req.Name)
+	}
+	if svc.Schedule != "" {
+		return nil, fmt.Errorf("service %q is a scheduled job and cannot be routed", req.Name)
+	}
+	if len(req.Routes) == 0 {
+		return nil, fmt.Errorf("no routes given; use 'gophercaptain route remove %s' to remove routing", req.Name)
+	}
+
+	routes, err := o.qualifyRoutes(req.RouteType, req.Routes)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.checkConflicts(ctx, svc.Name, routes); err != nil {
+		return nil, err
+	}
+	next := *svc
+	next.Routes = routes
+	next.TLS, next.TLSCert, next.TLSKey = "", "", ""
+	if err := o.resolveTLS(routeTLS(req, svc, &next), &next); err != nil {
+		return nil, err
+	}
+	if err := checkProxyOptions(&next); err != nil {
+		return nil, err
+	}
+	return o.applyRoutes(ctx, svc, &next)
+}
+
+// RemoveRoutes removes the given routes of a service, or all of them when
+// none are given, in which case its nginx config is removed. Proxy and
+// access options stay recorded for a later route set.
+func (o *Orchestrator) RemoveRoutes(ctx context.Context, name string, values []string) (*RouteResult, error) {
+	svc, err := o.routedService(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	next := *svc
+	next.Routes = nil
+	if len(values) > 0 {
+		remove := make(map[string]bool)
+		for _, value := range values {
+			route, err := o.qualifyRoute(nginx.InferRouteType(value), value)
+			if err != nil {
+				return nil, err
+			}
+			if !slices.ContainsFunc(svc.Routes, func(r state.Route) bool { return r.Value == route }) {
+				return nil, fmt.Errorf("service %q has no route %q; run 'gophercaptain route show %s' to see its routes", name, route, name)
+			}
+			remove[route] = true
+		}
+		for _, r := range svc.Routes {
+			if !remove[r.Value] {
+				next.Routes = append(next.Routes, r)
+			}
+		}
+	}
+	if len(domains(&next)) == 0 {
+		next.TLS, next.TLSCert, next.TLSKey = "", "", ""
+	}
+	if len(next.Routes) > 0 {
+		if err := checkProxyOptions(&next); err != nil {
+			return nil, err
+		}
+	}
+	return o.applyRoutes(ctx, svc, &next)
+}
+
+// Routes returns a service's routes and how they are served.
+func (o *Orchestrator) Routes(ctx context.Context, name string) (*RouteResult, error) {
+	svc, err := o.store.GetService(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", name)
+	}
+	return o.routeResult(svc), nil
+}
+
+// routeTLS returns the TLS request for a route change. Flags win; without
+// them subdomain routes keep the mode the service's subdomain routes had,
+// and a service gaining its first ones gets the [nginx] tls default.
+func routeTLS(req RouteRequest, svc, next *state.Service) DeployRequest {
+	tls := DeployRequest{TLS: req.TLS, NoTLS: req.NoTLS, TLSCert: req.TLSCert, TLSKey: req.TLSKey}
+	if req.TLS || req.NoTLS || req.TLSCert != "" || req.TLSKey != "" || len(domains(svc)) == 0 || len(domains(next)) == 0 {
+		return tls
+	}
+	switch svc.TLS {
+	case "acme":
+		tls.TLS = true
+	case "manual":
+		tls.TLSCert, tls.TLSKey = svc.TLSCert, svc.TLSKey
+	default:
+		tls.NoTLS = true
+	}
+	return tls
+}
+
+// applyRoutes writes the nginx config for a service's new routes, or
+// removes it when none are left, then records them. A certificate is
+// issued if the subdomain routes need one they are not covered by.
+func (o *Orchestrator) applyRoutes(ctx context.Context, svc, next *state.Service) (*RouteResult, error) {
+	if len(next.Routes) > 0 {
+		if err := o.proxy.WriteConfig(ctx, o.routeParams(next)); err != nil {
+			return nil, fmt.Errorf("routes of %s unchanged: %w", svc.Name, err)
+		}
+	} else if err := o.proxy.RemoveConfig(ctx, svc.Name); err != nil {
+		return nil, err
+	}
+
+	next.UpdatedAt = time.Now()
+	if err := o.store.UpdateService(ctx, next); err != nil {
+		return nil, fmt.Errorf("updating state: %w", err)
+	}
+	if svc.TLSCert != next.TLSCert {
+		o.removeCertificate(svc)
+	}
+	if svc.TLS == "acme" && next.TLS != "acme" {
+		o.disableUnusedRenewal(ctx)
+	}
+
+	values := make([]string, len(next.Routes))
+	for i, r := range next.Routes {
+		values[i] = r.Value
+	}
+	change := "routes removed"
+	if len(values) > 0 {
+		change = "routes " + strings.Join(values, ", ")
+	}
+	o.store.AppendHistory(ctx, &state.HistoryEntry{
+		Service:   svc.Name,
+		Action:    "configure",
+		Version:   svc.Version,
+		Timestamp: next.UpdatedAt,
+		Detail:    map[string]string{"change": change},
+	})
+
+	// As at deploy, certificate failures leave the routes on HTTP for the
+	// renewal timer to retry
+	var tlsWarn string
+	if next.TLS == "acme" && !o.proxyIssuesCertificates() {
+		if err := o.enableRenewal(ctx); err != nil {
+			tlsWarn = err.Error()
+		}
+		if certs.NeedsRenewal(next.TLSCert, time.Now()) || !slices.Equal(domains(svc), domains(next)) {
+			if err := o.issueCertificate(ctx, next); err != nil {
+				tlsWarn = err.Error()
+			}
+		}
+	}
+
+	result := o.routeResult(next)
+	result.TLSWarn = tlsWarn
+	return result, nil
+}
+
+// routeResult describes how a service's routes are served.
+func (o *Orchestrator) routeResult(svc *state.Service) *RouteResult {
+	result := &RouteResult{
+		Name:    svc.Name,
+		Port:    svc.Port,
+		Routes:  svc.Routes,
+		TLS:     len(svc.Routes) > 0 && o.servesTLS(svc),
+		TLSMode: svc.TLS,
+	}
+	if result.TLS && !o.proxyIssuesCertificates() {
+		result.NotAfter, _ = certs.NotAfter(svc.TLSCert)
+	}
+	return result
+}
@@ -0,0 +1,214 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ecairns22/GopherCaptain/internal/config"
+	"github.com/ecairns22/GopherCaptain/internal/nginx"
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+	"github.com/ecairns22/GopherCaptain/internal/state"
+	"github.com/ecairns22/GopherCaptain/internal/systemd"
+)
+
+// routingTest is an orchestrator routing through nginx into temp dirs.
+type routingTest struct {
+	o           *Orchestrator
+	store       *state.Store
+	runner      *runner.FakeRunner
+	sitesDir    string
+	snippetsDir string
+}
+
+func newRoutingTest(t *testing.T) *routingTest {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Nginx.DefaultHost = "example.com"
+	cfg.Nginx.HtpasswdDir = t.TempDir()
+	cfg.Nginx.CertDir = t.TempDir()
+
+	r := runner.NewFakeRunner()
+	sitesDir, snippetsDir := t.TempDir(), t.TempDir()
+	proxy := nginx.New(r, sitesDir, t.TempDir(), snippetsDir)
+	sys := systemd.NewWithController(r, systemd.NewFakeController(), t.TempDir())
+	store := testStore(t)
+	return &routingTest{
+		o:           New(cfg, store, nil, sys, proxy, nil),
+		store:       store,
+		runner:      r,
+		sitesDir:    sitesDir,
+		snippetsDir: snippetsDir,
+	}
+}
+
+// insert records a deployed service with the given routes.
+func (rt *routingTest) insert(t *testing.T, name string, port int, routes ...state.Route) {
+	t.Helper()
+	svc := &state.Service{Name: name, Repo: "acme/" + name, Version: "v1.0.0", Port: port, Routes: routes}
+	if err := rt.store.InsertService(context.Background(), svc); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestSetRoutesRejectsConflict(t *testing.T) {
+	ctx := context.Background()
+	rt := newRoutingTest(t)
+	rt.insert(t, "web", 3000, state.Route{Type: "subdomain", Value: "app.example.com"}, state.Route{Type: "path", Value: "example.com/docs"})
+	rt.insert(t, "api", 3001)
+
+	for _, route := range []string{"app.example.com", "/docs"} {
+		_, err := rt.o.SetRoutes(ctx, RouteRequest{Name: "api", Routes: []string{route}})
+		if err == nil || !strings.Contains(err.Error(), `service "web"`) {
+			t.Errorf("route %s: expected a conflict with web, got %v", route, err)
+		}
+	}
+	if exists(filepath.Join(rt.sitesDir, "gc-api.conf")) {
+		t.Error("a rejected route should not write config")
+	}
+	if got, _ := rt.store.GetService(ctx, "api"); len(got.Routes) > 0 {
+		t.Errorf("a rejected route should not be recorded, got %v", got.Routes)
+	}
+
+	// A service's own routes may not collide either: a subdomain route and
+	// a path route on one host would be two server blocks for it
+	_, err := rt.o.SetRoutes(ctx, RouteRequest{Name: "api", Routes: []string{"example.com", "/v1"}})
+	if err == nil || !strings.Contains(err.Error(), "same service") {
+		t.Errorf("expected a conflict within the service, got %v", err)
+	}
+}
+
+func TestRoutesRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	rt := newRoutingTest(t)
+	rt.insert(t, "api", 3000)
+
+	config := filepath.Join(rt.sitesDir, "gc-api.conf")
+	snippet := filepath.Join(rt.snippetsDir, "example.com", "gc-api.conf")
+	host := filepath.Join(rt.sitesDir, "gophercaptain-host-example.com.conf")
+
+	result, err := rt.o.SetRoutes(ctx, RouteRequest{Name: "api", Routes: []string{"api.example.com", "/docs"}})
+	if err != nil {
+		t.Fatalf("SetRoutes: %v", err)
+	}
+	want := []state.Route{{Type: "subdomain", Value: "api.example.com"}, {Type: "path", Value: "example.com/docs"}}
+	if len(result.Routes) != 2 || result.Routes[0] != want[0] || result.Routes[1] != want[1] || result.TLS {
+		t.Errorf("result = %+v, want routes %v over HTTP", result, want)
+	}
+	data, _ := os.ReadFile(config)
+	if !strings.Contains(string(data), "server_name api.example.com;") || !strings.Contains(string(data), "proxy_pass http://127.0.0.1:3000;") {
+		t.Errorf("config should serve api.example.com, got:\n%s", data)
+	}
+	data, _ = os.ReadFile(snippet)
+	if !strings.Contains(string(data), "location /docs {") {
+		t.Errorf("snippet should hold the /docs location, got:\n%s", data)
+	}
+	if data, _ := os.ReadFile(host); !strings.Contains(string(data), "include "+snippet+";") {
+		t.Errorf("host server should include the snippet, got:\n%s", data)
+	}
+	if got, _ := rt.store.GetService(ctx, "api"); len(got.Routes) != 2 {
+		t.Errorf("state routes = %v, want %v", got.Routes, want)
+	}
+
+	// Removing the path route leaves the subdomain served
+	if _, err := rt.o.RemoveRoutes(ctx, "api", []string{"/docs"}); err != nil {
+		t.Fatalf("RemoveRoutes: %v", err)
+	}
+	if exists(snippet) || exists(host) {
+		t.Error("the snippet and its host server should go with the last path route")
+	}
+	if data, _ := os.ReadFile(config); !strings.Contains(string(data), "server_name api.example.com;") {
+		t.Errorf("subdomain route should still be served, got:\n%s", data)
+	}
+
+	// Removing the last route removes the config
+	if _, err := rt.o.RemoveRoutes(ctx, "api", nil); err != nil {
+		t.Fatalf("RemoveRoutes: %v", err)
+	}
+	if exists(config) {
+		t.Error("config should be removed with the last route")
+	}
+	got, _ := rt.store.GetService(ctx, "api")
+	if len(got.Routes) > 0 {
+		t.Errorf("state routes = %v, want none", got.Routes)
+	}
+	if _, err := rt.o.RemoveRoutes(ctx, "api", nil); err == nil {
+		t.Error("removing routes of an unrouted service should fail")
+	}
+}
+
+func TestSetRoutesKeepsOldConfigOnTestFailure(t *testing.T) {
+	ctx := context.Background()
+	rt := newRoutingTest(t)
+	rt.insert(t, "api", 3000)
+	if _, err := rt.o.SetRoutes(ctx, RouteRequest{Name: "api", Routes: []string{"api.example.com"}}); err != nil {
+		t.Fatalf("SetRoutes: %v", err)
+	}
+
+	rt.runner.SetResponse("nginx -t", runner.Response{Stderr: "emerg", Err: errors.New("exit status 1")})
+	_, err := rt.o.SetRoutes(ctx, RouteRequest{Name: "api", Routes: []string{"www.example.com"}})
+	if err == nil || !strings.Contains(err.Error(), "routes of api unchanged") {
+		t.Fatalf("expected the change to be refused, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(rt.sitesDir, "gc-api.conf")); !strings.Contains(string(data), "server_name api.example.com;") {
+		t.Errorf("old config should be restored, got:\n%s", data)
+	}
+	if got, _ := rt.store.GetService(ctx, "api"); len(got.Routes) != 1 || got.Routes[0].Value != "api.example.com" {
+		t.Errorf("state routes = %v, want the old route", got.Routes)
+	}
+}
+
+func TestRemoveRoutesDropsTLSWithSubdomains(t *testing.T) {
+	ctx := context.Background()
+	rt := newRoutingTest(t)
+	svc := &state.Service{
+		Name: "api", Repo: "acme/api", Version: "v1.0.0", Port: 3000,
+		Routes: []state.Route{{Type: "subdomain", Value: "api.example.com"}, {Type: "path", Value: "example.com/api"}},
+		TLS:    "manual", TLSCert: "/etc/ssl/api.pem", TLSKey: "/etc/ssl/api.key",
+	}
+	if err := rt.store.InsertService(ctx, svc); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := rt.o.RemoveRoutes(ctx, "api", []string{"api.example.com"})
+	if err != nil {
+		t.Fatalf("RemoveRoutes: %v", err)
+	}
+	got, _ := rt.store.GetService(ctx, "api")
+	if got.TLS != "" || got.TLSCert != "" || got.TLSKey != "" || result.TLS {
+		t.Errorf("TLS should be dropped with the last subdomain route, got %q %q %q", got.TLS, got.TLSCert, got.TLSKey)
+	}
+}
+
+func TestRouteTLS(t *testing.T) {
+	sub := []state.Route{{Type: "subdomain", Value: "api.example.com"}}
+	path := []state.Route{{Type: "path", Value: "example.com/api"}}
+	tests := []struct {
+		name       string
+		req        RouteRequest
+		svc, next  state.Service
+		tls, noTLS bool
+		cert, key  string
+	}{
+		{"acme kept", RouteRequest{}, state.Service{Routes: sub, TLS: "acme"}, state.Service{Routes: sub}, true, false, "", ""},
+		{"manual kept", RouteRequest{}, state.Service{Routes: sub, TLS: "manual", TLSCert: "c.pem", TLSKey: "k.pem"}, state.Service{Routes: sub}, false, false, "c.pem", "k.pem"},
+		{"plain HTTP kept", RouteRequest{}, state.Service{Routes: sub}, state.Service{Routes: sub}, false, true, "", ""},
+		{"first subdomain gets the default", RouteRequest{}, state.Service{Routes: path}, state.Service{Routes: sub}, false, false, "", ""},
+		{"no subdomains left", RouteRequest{}, state.Service{Routes: sub, TLS: "acme"}, state.Service{Routes: path}, false, false, "", ""},
+		{"flag wins", RouteRequest{NoTLS: true}, state.Service{Routes: sub, TLS: "acme"}, state.Service{Routes: sub}, false, true, "", ""},
+	}
+	for _, tt := range tests {
+		got := routeTLS(tt.req, &tt.svc, &tt.next)
+		if got.TLS != tt.tls || got.NoTLS != tt.noTLS || got.TLSCert != tt.cert || got.TLSKey != tt.key {
+			t.Errorf("%s: routeTLS = %+v", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/ecairns22/GopherCaptain/internal/creds"
+	"github.com/ecairns22/GopherCaptain/internal/health"
+	"github.com/ecairns22/GopherCaptain/internal/state"
+)
+
+// ScaleRequest holds parameters for a scale.
+type ScaleRequest struct {
+	Name      string
+	Instances int
+}
+
+// ScaleResult holds the output of a successful scale.
+type ScaleResult struct {
+	Name      string
+	Ports     []int  // ports of the running instances
+	NginxWarn string // warning message if nginx failed
+}
+
+// Scale runs the given number of instances of a service. More than one
+// instance moves the service onto the gc-<name>@.service template unit, one
+// instance per port, with its original port kept as the first instance;
+// scaling back to one returns it to gc-<name>.service.
+func (o *Orchestrator) Scale(ctx context.Context, req ScaleRequest) (*ScaleResult, error) {
+	svc, err := o.store.GetService(ctx, req.Name)
+	if err != nil {
+		return nil, fmt.Errorf("service %q not found; run 'gophercaptain list' to see deployed services", req.Name)
+	}
+	if req.Instances < 1 {
+		return nil, fmt.Errorf("instance count must be at least 1, got %d", req.Instances)
+	}
+	if svc.Schedule != "" || svc.SocketActivated {
+		return nil, fmt.Errorf("service %q is a scheduled job or socket-activated and cannot be scaled", req.Name)
+	}
+	// Instances get their own port from instance-<port>.env, which a TOML
+	// config file cannot pick up, so every instance would share one port
+	if svc.ConfigFile && req.Instances > 1 {
+		return nil, fmt.Errorf("service %q was deployed with --config-file and cannot be scaled: its instances would share port %d", req.Name, svc.Port)
+	}
+
+	current := len(svc.Instances)
+	if current == 0 {
+		current = 1
+	}
+
+	result := &ScaleResult{Name: req.Name}
+	switch {
+	case req.Instances > current:
+		if err := o.scaleUp(ctx, svc, req.Instances); err != nil {
+			return nil, err
+		}
+		// Route to the new instances only once they are healthy
+		result.NginxWarn = o.updateRoute(ctx, svc)
+	case req.Instances < current:
+		remove := surplusInstances(svc, req.Instances)
+
+		// Stop routing to instances before they are stopped
+		var keep []int
+		for _, port := range svc.Instances {
+			if !slices.Contains(remove, port) && req.Instances > 1 {
+				keep = append(keep, port)
+			}
+		}
+		routed := *svc
+		routed.Instances = keep
+		result.NginxWarn = o.updateRoute(ctx, &routed)
+
+		if err := o.scaleDown(ctx, svc, remove, req.Instances); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Ports = svc.Instances
+	if len(result.Ports) == 0 {
+		result.Ports = []int{svc.Port}
+	}
+
+	if req.Instances != current {
+		o.store.AppendHistory(ctx, &state.HistoryEntry{
+			Service:   req.Name,
+			Action:    "scale",
+			Version:   svc.Version,
+			Timestamp: time.Now(),
+			Detail:    map[string]string{"from": fmt.Sprintf("%d", current), "to": fmt.Sprintf("%d", req.Instances)},
+		})
+	}
+	return result, nil
+}
+
+// scaleUp starts instances on newly allocated ports until n are running.
+// On the first scale-out the service's own port is handed over from
+// gc-<name>.service to an instance last, after the new instances are up,
+// so something is serving throughout. Instances added by a failed scale-up
+// are removed again.
+func (o *Orchestrator) scaleUp(ctx context.Context, svc *state.Service, n int) error {
+	converting := len(svc.Instances) == 0
+	if converting {
+		if err := o.systemd.WriteTemplate(ctx, unitParams(svc)); err != nil {
+			return err
+		}
+		if err := o.systemd.DaemonReload(ctx); err != nil {
+			return fmt.Errorf("daemon-reload: %w", err)
+		}
+	}
+
+	var added []int
+	undo := func(err error) error {
+		for _, port := range added {
+			o.removeInstance(ctx, svc, port)
+		}
+		if converting {
+			o.systemd.RemoveTemplate(svc.Name)
+			o.systemd.DaemonReload(ctx)
+		}
+		return err
+	}
+
+	extra := n - len(svc.Instances)
+	if converting {
+		extra = n - 1
+	}
+	for i := 0; i < extra; i++ {
+		port, err := o.ports.Next(ctx)
+		if err != nil {
+			return undo(err)
+		}
+		if err := o.addInstance(ctx, svc, port); err != nil {
+			return undo(err)
+		}
+		added = append(added, port)
+	}
+
+	if converting {
+		o.systemd.Stop(ctx, svc.Name)
+		o.systemd.Disable(ctx, svc.Name)
+		if err := o.addInstance(ctx, svc, svc.Port); err != nil {
+			o.systemd.Enable(ctx, svc.Name)
+			o.systemd.Start(ctx, svc.Name)
+			return undo(err)
+		}
+	}
+
+	sort.Ints(svc.Instances)
+	return nil
+}
+
+// surplusInstances picks the highest-port instances to stop so that n
+// remain. The service's own port is never picked.
+func surplusInstances(svc *state.Service, n int) []int {
+	var remove []int
+	for i := len(svc.Instances) - 1; i >= 0 && len(svc.Instances)-len(remove) > n; i-- {
+		if port := svc.Instances[i]; port != svc.Port {
+			remove = append(remove, port)
+		}
+	}
+	return remove
+}
+
+// scaleDown stops the given instances. At n == 1 the service's own port
+// goes back from its instance to gc-<name>.service.
+func (o *Orchestrator) scaleDown(ctx context.Context, svc *state.Service, remove []int, n int) error {
+	for _, port := range remove {
+		o.removeInstance(ctx, svc, port)
+	}
+	if n > 1 {
+		return nil
+	}
+
+	o.removeInstance(ctx, svc, svc.Port)
+	if err := o.systemd.Enable(ctx, svc.Name); err != nil {
+		return fmt.Errorf("enabling service: %w", err)
+	}
+	if err := o.systemd.Start(ctx, svc.Name); err != nil {
+		return fmt.Errorf("starting service: %w", err)
+	}
+	if err := health.WaitForPort(svc.Port, 10*time.Second); err != nil {
+		return err
+	}
+	o.systemd.RemoveTemplate(svc.Name)
+	o.systemd.DaemonReload(ctx)
+	return nil
+}
+
+// addInstance records, enables and starts an instance, and waits for it to
+// accept connections. A failed instance is cleaned up before returning.
+func (o *Orchestrator) addInstance(ctx context.Context, svc *state.Service, port int) error {
+	env := &creds.EnvFileContent{Entries: map[string]string{"PORT": fmt.Sprintf("%d", port)}}
+	if err := creds.WriteEnvFile(instanceEnvPath(svc.Name, port), env); err != nil {
+		return err
+	}
+	if err := o.store.AddInstance(ctx, svc.Name, port); err != nil {
+		os.Remove(instanceEnvPath(svc.Name, port))
+		return err
+	}
+	svc.Instances = append(svc.Instances, port)
+
+	err := o.systemd.EnableInstance(ctx, svc.Name, port)
+	if err == nil {
+		err = o.systemd.StartInstance(ctx, svc.Name, port)
+	}
+	if err == nil {
+		err = health.WaitForPort(port, 10*time.Second)
+	}
+	if err != nil {
+		o.removeInstance(ctx, svc, port)
+		return fmt.Errorf("starting instance on port %d: %w", port, err)
+	}
+	return nil
+}
+
+// removeInstance stops and forgets an instance. Errors are ignored so a
+// partially started instance can always be cleaned up.
+func (o *Orchestrator) removeInstance(ctx context.Context, svc *state.Service, port int) {
+	o.systemd.StopInstance(ctx, svc.Name, port)
+	o.systemd.DisableInstance(ctx, svc.Name, port)
+	o.store.RemoveInstance(ctx, svc.Name, port)
+	os.Remove(instanceEnvPath(svc.Name, port))
+
+	for i, p := range svc.Instances {
+		if p == port {
+			svc.Instances = append(svc.Instances[:i], svc.Instances[i+1:]...)
+			break
+		}
+	}
+}
+
+// updateRoute re-renders a routed service's nginx config over its current
+// instances. Failures are non-fatal, as in Deploy, and returned as a warning.
+func (o *Orchestrator) updateRoute(ctx context.Context, svc *state.Service) string {
+	if len(svc.Routes) == 0 {
+		return ""
+	}
+	if err := o.proxy.WriteConfig(ctx, o.routeParams(svc)); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// instanceEnvPath is the per-instance env file that sets PORT, read by the
+// template unit after the shared env file.
+func instanceEnvPath(name string, port int) string {
+	return filepath.Join(configBase, name, fmt.Sprintf("instance-%d.env", port))
+}
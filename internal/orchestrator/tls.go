@@ -0,0 +1,158 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ecairns22/GopherCaptain/internal/certs"
+	"github.com/ecairns22/GopherCaptain/internal/state"
+)
+
+// RenewResult reports what renewal did for one ACME route.
+type RenewResult struct {
+	Name     string
+	Domains  []string
+	Renewed  bool
+	NotAfter time.Time // expiry of the certificate now served, zero if none
+	Err      error
+}
+
+// resolveTLS works out how a new service's subdomain routes are served:
+// with a supplied certificate, via ACME (requested, or the [nginx] tls
+// default), or over plain HTTP. One certificate covers all of them, stored
+// under the first domain. It sets the TLS fields of svc.
+func (o *Orchestrator) resolveTLS(req DeployRequest, svc *state.Service) error {
+	names := domains(svc)
+	manual := req.TLSCert != "" || req.TLSKey != ""
+	acme := req.TLS || (o.cfg.Nginx.TLS == "acme" && !req.NoTLS && len(names) > 0)
+	if !manual && !acme {
+		return nil
+	}
+	if req.NoTLS && (manual || req.TLS) {
+		return fmt.Errorf("--no-tls cannot be combined with --tls, --tls-cert or --tls-key")
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("TLS requires a subdomain route")
+	}
+	// The first domain names the certificate directory
+	for _, name := range names {
+		if strings.ContainsAny(name, "/\\ ") || strings.HasPrefix(name, ".") {
+			return fmt.Errorf("invalid domain %q for TLS", name)
+		}
+	}
+
+	if manual {
+		if req.TLSCert == "" || req.TLSKey == "" {
+			return fmt.Errorf("--tls-cert and --tls-key must be given together")
+		}
+		if err := certs.Validate(req.TLSCert, req.TLSKey); err != nil {
+			return err
+		}
+		svc.TLS, svc.TLSCert, svc.TLSKey = "manual", req.TLSCert, req.TLSKey
+		return nil
+	}
+	svc.TLS = "acme"
+	svc.TLSCert, svc.TLSKey = certs.Paths(o.cfg.Nginx.CertDir, names[0])
+	return nil
+}
+
+// issueCertificate obtains a certificate covering all of a service's
+// subdomain routes and switches its nginx config to HTTPS. The routes'
+// port 80 config must already be live so the CA can fetch the challenges.
+func (o *Orchestrator) issueCertificate(ctx context.Context, svc *state.Service) error {
+	if err := os.MkdirAll(o.cfg.Nginx.ACMEWebroot, 0755); err != nil {
+		return fmt.Errorf("creating ACME webroot: %w", err)
+	}
+	issuer := certs.NewIssuer(o.cfg.Nginx.ACMEDirectory, o.cfg.Nginx.ACMEEmail,
+		filepath.Join(o.cfg.Nginx.CertDir, "acme-account.key"), o.cfg.Nginx.ACMEWebroot)
+	names := domains(svc)
+	if len(names) == 0 {
+		return fmt.Errorf("%s has no subdomain routes to certify", svc.Name)
+	}
+	certPEM, keyPEM, err := issuer.Obtain(ctx, names...)
+	if err != nil {
+		return err
+	}
+	if err := certs.Write(o.cfg.Nginx.CertDir, filepath.Base(filepath.Dir(svc.TLSCert)), certPEM, keyPEM); err != nil {
+		return err
+	}
+	return o.proxy.WriteConfig(ctx, o.routeParams(svc))
+}
+
+// enableRenewal installs the daily renewal timer the first time an ACME
+// route is deployed.
+func (o *Orchestrator) enableRenewal(ctx context.Context) error {
+	binary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating gophercaptain binary: %w", err)
+	}
+	return o.systemd.EnableRenewTimer(ctx, binary)
+}
+
+// disableUnusedRenewal removes the renewal timer once the last ACME route
+// is gone. Failures are ignored: a leftover timer only finds nothing to do.
+func (o *Orchestrator) disableUnusedRenewal(ctx context.Context) {
+	services, err := o.store.ListServices(ctx)
+	if err != nil {
+		return
+	}
+	for _, svc := range services {
+		if svc.TLS == "acme" {
+			return
+		}
+	}
+	o.systemd.DisableRenewTimer(ctx)
+}
+
+// RenewCertificates renews the certificates of ACME routes that are missing
+// or expire within 30 days, or of all of them with force. Nginx is reloaded
+// for each renewed route. One route failing does not stop the others.
+// Nothing is renewed when the proxy issues its own certificates.
+func (o *Orchestrator) RenewCertificates(ctx context.Context, force bool) ([]RenewResult, error) {
+	if o.proxyIssuesCertificates() {
+		return nil, nil
+	}
+	services, err := o.store.ListServices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []RenewResult
+	now := time.Now()
+	for _, svc := range services {
+		if svc.TLS != "acme" {
+			continue
+		}
+		result := RenewResult{Name: svc.Name, Domains: domains(svc)}
+		if force || certs.NeedsRenewal(svc.TLSCert, now) {
+			result.Err = o.issueCertificate(ctx, svc)
+			result.Renewed = result.Err == nil
+		}
+		result.NotAfter, _ = certs.NotAfter(svc.TLSCert)
+		results = append(results, result)
+
+		if result.Renewed {
+			o.store.AppendHistory(ctx, &state.HistoryEntry{
+				Service:   svc.Name,
+				Action:    "renew",
+				Version:   svc.Version,
+				Timestamp: now,
+				Detail:    map[string]string{"domains": strings.Join(result.Domains, ","), "not_after": result.NotAfter.Format(time.RFC3339)},
+			})
+		}
+	}
+	return results, nil
+}
+
+// removeCertificate deletes a service's issued ACME certificate. Supplied
+// certificates belong to the operator and are left alone.
+func (o *Orchestrator) removeCertificate(svc *state.Service) {
+	dir := filepath.Dir(svc.TLSCert)
+	if svc.TLS == "acme" && filepath.Dir(dir) == filepath.Clean(o.cfg.Nginx.CertDir) {
+		os.RemoveAll(dir)
+	}
+}
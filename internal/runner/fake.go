@@ -3,6 +3,7 @@ package runner
 import (
 	"context"
 	"fmt"
+	"io"
 	"strings"
 	"sync"
 )
@@ -11,6 +12,8 @@ import (
 type Call struct {
 	Name string
 	Args []string
+	// Stdin holds everything read from the reader passed to Pipe.
+	Stdin string
 }
 
 func (c Call) String() string {
@@ -56,6 +59,36 @@ func (f *FakeRunner) SetFallback(resp Response) {
 
 // Run records the call and returns the matching response.
 func (f *FakeRunner) Run(_ context.Context, name string, args ...string) (string, string, error) {
+	resp := f.record(name, args)
+	return resp.Stdout, resp.Stderr, resp.Err
+}
+
+// Stream records the call and writes the matching response to the writers.
+func (f *FakeRunner) Stream(_ context.Context, stdout, stderr io.Writer, name string, args ...string) error {
+	resp := f.record(name, args)
+	io.WriteString(stdout, resp.Stdout)
+	io.WriteString(stderr, resp.Stderr)
+	return resp.Err
+}
+
+// Pipe records the call along with its stdin and writes the matching
+// response to the writers.
+func (f *FakeRunner) Pipe(_ context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+	var in strings.Builder
+	if stdin != nil {
+		io.Copy(&in, stdin)
+	}
+	resp := f.record(name, args)
+	f.mu.Lock()
+	f.Calls[len(f.Calls)-1].Stdin = in.String()
+	f.mu.Unlock()
+	io.WriteString(stdout, resp.Stdout)
+	io.WriteString(stderr, resp.Stderr)
+	return resp.Err
+}
+
+// record appends the call and finds its response.
+func (f *FakeRunner) record(name string, args []string) Response {
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
@@ -64,23 +97,23 @@ func (f *FakeRunner) Run(_ context.Context, name string, args ...string) (string
 
 	key := call.String()
 	if resp, ok := f.responses[key]; ok {
-		return resp.Stdout, resp.Stderr, resp.Err
+		return resp
 	}
 
 	// Try matching just the command name with first arg for broader matches
 	if len(args) > 0 {
 		partial := name + " " + args[0]
 		if resp, ok := f.responses[partial]; ok {
-			return resp.Stdout, resp.Stderr, resp.Err
+			return resp
 		}
 	}
 
 	// Try matching just the command name
 	if resp, ok := f.responses[name]; ok {
-		return resp.Stdout, resp.Stderr, resp.Err
+		return resp
 	}
 
-	return f.fallback.Stdout, f.fallback.Stderr, f.fallback.Err
+	return f.fallback
 }
 
 // Called returns true if a command matching the prefix was recorded.
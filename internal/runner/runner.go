@@ -3,12 +3,20 @@ package runner
 import (
 	"bytes"
 	"context"
+	"io"
 	"os/exec"
 )
 
 // CommandRunner abstracts command execution for testability.
 type CommandRunner interface {
 	Run(ctx context.Context, name string, args ...string) (stdout, stderr string, err error)
+	// Stream runs a command with its output connected to the given writers
+	// as it is produced, for long-running commands such as journalctl -f.
+	Stream(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) error
+	// Pipe runs a command with stdin read from the given reader and its
+	// output connected to the given writers, for commands such as mysql
+	// that consume or produce more data than should be held in memory.
+	Pipe(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error
 }
 
 // OSRunner executes commands via os/exec.
@@ -22,3 +30,18 @@ func (r *OSRunner) Run(ctx context.Context, name string, args ...string) (string
 	err := cmd.Run()
 	return outBuf.String(), errBuf.String(), err
 }
+
+func (r *OSRunner) Stream(ctx context.Context, stdout, stderr io.Writer, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+func (r *OSRunner) Pipe(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
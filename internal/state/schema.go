@@ -25,3 +25,111 @@ CREATE TABLE IF NOT EXISTS history (
     detail      TEXT
 );
 `
+
+// migrations are applied in order on top of schema. The database's
+// PRAGMA user_version records how many have already run, so entries must
+// only ever be appended.
+var migrations = []string{
+	// 1: custom ExecStart arguments and working directory
+	`ALTER TABLE services ADD COLUMN exec_args TEXT;
+	 ALTER TABLE services ADD COLUMN work_dir TEXT;`,
+
+	// 2: systemd socket activation
+	`ALTER TABLE services ADD COLUMN socket_activated INTEGER NOT NULL DEFAULT 0;`,
+
+	// 3: per-service hardening profile
+	`ALTER TABLE services ADD COLUMN hardening TEXT NOT NULL DEFAULT 'baseline';`,
+
+	// 4: scheduled jobs have no port, so port becomes nullable (SQLite
+	// cannot drop NOT NULL in place, hence the rebuild)
+	`CREATE TABLE services_new (
+	    name             TEXT PRIMARY KEY,
+	    repo             TEXT NOT NULL,
+	    version          TEXT NOT NULL,
+	    prev_version     TEXT,
+	    port             INTEGER UNIQUE,
+	    route_type       TEXT NOT NULL,
+	    route_value      TEXT NOT NULL,
+	    db_name          TEXT NOT NULL,
+	    db_user          TEXT NOT NULL,
+	    extra_env        TEXT,
+	    exec_args        TEXT,
+	    work_dir         TEXT,
+	    socket_activated INTEGER NOT NULL DEFAULT 0,
+	    hardening        TEXT NOT NULL DEFAULT 'baseline',
+	    schedule         TEXT,
+	    deployed_at      INTEGER NOT NULL,
+	    updated_at       INTEGER NOT NULL
+	 );
+	 INSERT INTO services_new (name, repo, version, prev_version, port, route_type, route_value, db_name, db_user, extra_env, exec_args, work_dir, socket_activated, hardening, deployed_at, updated_at)
+	 SELECT name, repo, version, prev_version, port, route_type, route_value, db_name, db_user, extra_env, exec_args, work_dir, socket_activated, hardening, deployed_at, updated_at
+	 FROM services;
+	 DROP TABLE services;
+	 ALTER TABLE services_new RENAME TO services;`,
+
+	// 5: command run with the new binary before an upgrade activates it
+	`ALTER TABLE services ADD COLUMN pre_start TEXT;`,
+
+	// 6: ports of the gc-<name>@<port>.service instances of scaled services
+	`CREATE TABLE instances (
+	    port    INTEGER PRIMARY KEY,
+	    service TEXT NOT NULL
+	 );
+	 CREATE INDEX instances_service ON instances (service);`,
+
+	// 7: systemd drop-in overrides, keyed by service and drop-in name
+	`CREATE TABLE overrides (
+	    service TEXT NOT NULL,
+	    name    TEXT NOT NULL,
+	    content TEXT NOT NULL,
+	    PRIMARY KEY (service, name)
+	 );`,
+
+	// 8: HTTPS for subdomain routes, from ACME or a supplied certificate
+	`ALTER TABLE services ADD COLUMN tls TEXT;
+	 ALTER TABLE services ADD COLUMN tls_cert TEXT;
+	 ALTER TABLE services ADD COLUMN tls_key TEXT;`,
+
+	// 9: several routes per service; the single route moves into routes
+	`CREATE TABLE routes (
+	    service TEXT NOT NULL,
+	    type    TEXT NOT NULL,
+	    value   TEXT NOT NULL,
+	    PRIMARY KEY (service, value)
+	 );
+	 INSERT INTO routes (service, type, value)
+	 SELECT name, route_type, route_value FROM services WHERE route_value != '';
+	 ALTER TABLE services DROP COLUMN route_type;
+	 ALTER TABLE services DROP COLUMN route_value;`,
+
+	// 10: proxy protocol and tuning of a service's routes
+	`ALTER TABLE services ADD COLUMN proto TEXT NOT NULL DEFAULT 'http';
+	 ALTER TABLE services ADD COLUMN keepalive INTEGER NOT NULL DEFAULT 0;
+	 ALTER TABLE services ADD COLUMN read_timeout TEXT;
+	 ALTER TABLE services ADD COLUMN max_body_size TEXT;`,
+
+	// 11: access control on a service's routes
+	`ALTER TABLE services ADD COLUMN allow_cidrs TEXT;
+	 ALTER TABLE services ADD COLUMN deny_cidrs TEXT;
+	 ALTER TABLE services ADD COLUMN rate_limit TEXT;
+	 ALTER TABLE services ADD COLUMN rate_burst INTEGER NOT NULL DEFAULT 0;
+	 CREATE TABLE auth_users (
+	    service TEXT NOT NULL,
+	    user    TEXT NOT NULL,
+	    hash    TEXT NOT NULL,
+	    PRIMARY KEY (service, user)
+	 );`,
+
+	// 12: maintenance mode switched on by hand
+	`ALTER TABLE services ADD COLUMN maintenance INTEGER NOT NULL DEFAULT 0;`,
+
+	// 13: database provider, MariaDB until PostgreSQL was added
+	`ALTER TABLE services ADD COLUMN db_provider TEXT NOT NULL DEFAULT '';
+	 UPDATE services SET db_provider = 'mariadb' WHERE db_name != '';`,
+
+	// 14: scheduled database backups
+	`ALTER TABLE services ADD COLUMN backup_schedule TEXT;`,
+
+	// 15: services whose env file is written as TOML (deploy --config-file)
+	`ALTER TABLE services ADD COLUMN config_file INTEGER NOT NULL DEFAULT 0;`,
+}
@@ -16,18 +16,45 @@ var ErrNotFound = errors.New("service not found")
 
 // Service represents a deployed service in the state store.
 type Service struct {
-	Name        string
-	Repo        string
-	Version     string
-	PrevVersion string
-	Port        int
-	RouteType   string
-	RouteValue  string
-	DBName      string
-	DBUser      string
-	ExtraEnv    map[string]string
-	DeployedAt  time.Time
-	UpdatedAt   time.Time
+	Name            string
+	Repo            string
+	Version         string
+	PrevVersion     string
+	Port            int     // 0 for scheduled jobs, which listen on nothing
+	Routes          []Route // in the order given at deploy; empty = not routed
+	DBName          string
+	DBUser          string
+	DBProvider      string // "mariadb" or "postgres" when the service has a database
+	BackupSchedule  string // OnCalendar= expression of scheduled database backups, empty = none
+	ExtraEnv        map[string]string
+	ConfigFile      bool     // env file written as TOML, for deploy --config-file
+	Args            []string // extra ExecStart arguments
+	WorkDir         string   // empty = default working directory
+	SocketActivated bool     // started on demand by gc-<name>.socket
+	Hardening       string   // systemd hardening profile
+	Schedule        string   // OnCalendar= expression; set for scheduled jobs
+	PreStart        []string // arguments to the new binary run before upgrades, e.g. migrate up
+	Instances       []int    // instance ports of a scaled service, empty otherwise; see AddInstance
+	TLS             string   // "acme" or "manual"; empty = HTTP only
+	TLSCert         string   // certificate chain path when TLS is set
+	TLSKey          string   // private key path when TLS is set
+	Proto           string   // "http", "websocket" or "grpc"; empty = http
+	Keepalive       int      // idle upstream connections kept open, 0 = none
+	ReadTimeout     string   // nginx proxy_read_timeout, empty = nginx default
+	MaxBodySize     string   // nginx client_max_body_size, empty = nginx default
+	Allow           []string // CIDRs allowed to reach the routes, empty = everyone
+	Deny            []string // CIDRs refused, checked before Allow
+	RateLimit       string   // nginx limit_req rate per client, e.g. "10r/s"; empty = unlimited
+	RateBurst       int      // requests allowed above RateLimit before refusing
+	Maintenance     bool     // routes serve the maintenance page until switched off
+	DeployedAt      time.Time
+	UpdatedAt       time.Time
+}
+
+// Route is one nginx route of a service.
+type Route struct {
+	Type  string // "subdomain" or "path"
+	Value string // domain, or host/path for path routes
 }
 
 // HistoryEntry represents an action recorded in the history table.
@@ -40,6 +67,20 @@ type HistoryEntry struct {
 	Detail    map[string]string
 }
 
+// AuthUser is a basic auth user of a service's routes.
+type AuthUser struct {
+	Service string
+	User    string
+	Hash    string // htpasswd password hash
+}
+
+// Override is a systemd drop-in file managed for a service.
+type Override struct {
+	Service string
+	Name    string // drop-in name, written as <name>.conf
+	Content string
+}
+
 // Store wraps a SQLite database for state management.
 type Store struct {
 	db *sql.DB
@@ -68,9 +109,42 @@ func Open(dbPath string) (*Store, error) {
 		return nil, fmt.Errorf("creating schema: %w", err)
 	}
 
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return &Store{db: db}, nil
 }
 
+// migrate applies any migrations newer than the database's user_version.
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("starting migration %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("applying migration %d: %w", i+1, err)
+		}
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("recording migration %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("committing migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
 // Close closes the underlying database.
 func (s *Store) Close() error {
 	return s.db.Close()
@@ -82,25 +156,45 @@ func (s *Store) InsertService(ctx context.Context, svc *Service) error {
 	if err != nil {
 		return err
 	}
+	args, err := marshalJSON(svc.Args)
+	if err != nil {
+		return err
+	}
+	preStart, err := marshalJSON(svc.PreStart)
+	if err != nil {
+		return err
+	}
+	allow, err := marshalJSON(svc.Allow)
+	if err != nil {
+		return err
+	}
+	deny, err := marshalJSON(svc.Deny)
+	if err != nil {
+		return err
+	}
 	_, err = s.db.ExecContext(ctx,
-		`INSERT INTO services (name, repo, version, prev_version, port, route_type, route_value, db_name, db_user, extra_env, deployed_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO services (`+serviceColumns+`)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		svc.Name, svc.Repo, svc.Version, nullString(svc.PrevVersion),
-		svc.Port, svc.RouteType, svc.RouteValue,
-		svc.DBName, svc.DBUser, extraEnv,
+		nullInt(svc.Port),
+		svc.DBName, svc.DBUser, svc.DBProvider, nullString(svc.BackupSchedule), extraEnv, svc.ConfigFile,
+		args, nullString(svc.WorkDir), svc.SocketActivated, hardening(svc.Hardening),
+		nullString(svc.Schedule), preStart,
+		nullString(svc.TLS), nullString(svc.TLSCert), nullString(svc.TLSKey),
+		proto(svc.Proto), svc.Keepalive, nullString(svc.ReadTimeout), nullString(svc.MaxBodySize),
+		allow, deny, nullString(svc.RateLimit), svc.RateBurst, svc.Maintenance,
 		svc.DeployedAt.Unix(), svc.UpdatedAt.Unix(),
 	)
 	if err != nil {
 		return fmt.Errorf("inserting service %s: %w", svc.Name, err)
 	}
-	return nil
+	return s.setRoutes(ctx, svc.Name, svc.Routes)
 }
 
 // GetService retrieves a service by name.
 func (s *Store) GetService(ctx context.Context, name string) (*Service, error) {
 	row := s.db.QueryRowContext(ctx,
-		`SELECT name, repo, version, prev_version, port, route_type, route_value, db_name, db_user, extra_env, deployed_at, updated_at
-		 FROM services WHERE name = ?`, name)
+		`SELECT `+serviceColumns+` FROM services WHERE name = ?`, name)
 
 	svc, err := scanService(row)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -109,14 +203,19 @@ func (s *Store) GetService(ctx context.Context, name string) (*Service, error) {
 	if err != nil {
 		return nil, fmt.Errorf("getting service %s: %w", name, err)
 	}
+	if svc.Instances, err = s.Instances(ctx, name); err != nil {
+		return nil, err
+	}
+	if svc.Routes, err = s.routes(ctx, name); err != nil {
+		return nil, err
+	}
 	return svc, nil
 }
 
 // ListServices returns all services.
 func (s *Store) ListServices(ctx context.Context) ([]*Service, error) {
 	rows, err := s.db.QueryContext(ctx,
-		`SELECT name, repo, version, prev_version, port, route_type, route_value, db_name, db_user, extra_env, deployed_at, updated_at
-		 FROM services ORDER BY name`)
+		`SELECT `+serviceColumns+` FROM services ORDER BY name`)
 	if err != nil {
 		return nil, fmt.Errorf("listing services: %w", err)
 	}
@@ -124,13 +223,25 @@ func (s *Store) ListServices(ctx context.Context) ([]*Service, error) {
 
 	var services []*Service
 	for rows.Next() {
-		svc, err := scanServiceFromRows(rows)
+		svc, err := scanService(rows)
 		if err != nil {
 			return nil, err
 		}
 		services = append(services, svc)
 	}
-	return services, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, svc := range services {
+		if svc.Instances, err = s.Instances(ctx, svc.Name); err != nil {
+			return nil, err
+		}
+		if svc.Routes, err = s.routes(ctx, svc.Name); err != nil {
+			return nil, err
+		}
+	}
+	return services, nil
 }
 
 // UpdateService updates a service record.
@@ -139,12 +250,33 @@ func (s *Store) UpdateService(ctx context.Context, svc *Service) error {
 	if err != nil {
 		return err
 	}
+	args, err := marshalJSON(svc.Args)
+	if err != nil {
+		return err
+	}
+	preStart, err := marshalJSON(svc.PreStart)
+	if err != nil {
+		return err
+	}
+	allow, err := marshalJSON(svc.Allow)
+	if err != nil {
+		return err
+	}
+	deny, err := marshalJSON(svc.Deny)
+	if err != nil {
+		return err
+	}
 	result, err := s.db.ExecContext(ctx,
-		`UPDATE services SET repo=?, version=?, prev_version=?, port=?, route_type=?, route_value=?, db_name=?, db_user=?, extra_env=?, updated_at=?
+		`UPDATE services SET repo=?, version=?, prev_version=?, port=?, db_name=?, db_user=?, db_provider=?, backup_schedule=?, extra_env=?, config_file=?, exec_args=?, work_dir=?, socket_activated=?, hardening=?, schedule=?, pre_start=?, tls=?, tls_cert=?, tls_key=?, proto=?, keepalive=?, read_timeout=?, max_body_size=?, allow_cidrs=?, deny_cidrs=?, rate_limit=?, rate_burst=?, maintenance=?, updated_at=?
 		 WHERE name=?`,
 		svc.Repo, svc.Version, nullString(svc.PrevVersion),
-		svc.Port, svc.RouteType, svc.RouteValue,
-		svc.DBName, svc.DBUser, extraEnv,
+		nullInt(svc.Port),
+		svc.DBName, svc.DBUser, svc.DBProvider, nullString(svc.BackupSchedule), extraEnv, svc.ConfigFile,
+		args, nullString(svc.WorkDir), svc.SocketActivated, hardening(svc.Hardening),
+		nullString(svc.Schedule), preStart,
+		nullString(svc.TLS), nullString(svc.TLSCert), nullString(svc.TLSKey),
+		proto(svc.Proto), svc.Keepalive, nullString(svc.ReadTimeout), nullString(svc.MaxBodySize),
+		allow, deny, nullString(svc.RateLimit), svc.RateBurst, svc.Maintenance,
 		svc.UpdatedAt.Unix(), svc.Name,
 	)
 	if err != nil {
@@ -154,11 +286,55 @@ func (s *Store) UpdateService(ctx context.Context, svc *Service) error {
 	if n == 0 {
 		return ErrNotFound
 	}
+	return s.setRoutes(ctx, svc.Name, svc.Routes)
+}
+
+// setRoutes replaces a service's routes, keeping their order.
+func (s *Store) setRoutes(ctx context.Context, service string, routes []Route) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM routes WHERE service=?`, service); err != nil {
+		return fmt.Errorf("clearing routes of %s: %w", service, err)
+	}
+	for _, r := range routes {
+		if _, err := s.db.ExecContext(ctx, `INSERT INTO routes (service, type, value) VALUES (?, ?, ?)`, service, r.Type, r.Value); err != nil {
+			return fmt.Errorf("adding route %s of %s: %w", r.Value, service, err)
+		}
+	}
 	return nil
 }
 
+// routes returns a service's routes in the order they were recorded.
+func (s *Store) routes(ctx context.Context, service string) ([]Route, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT type, value FROM routes WHERE service=? ORDER BY rowid`, service)
+	if err != nil {
+		return nil, fmt.Errorf("querying routes of %s: %w", service, err)
+	}
+	defer rows.Close()
+
+	var routes []Route
+	for rows.Next() {
+		var r Route
+		if err := rows.Scan(&r.Type, &r.Value); err != nil {
+			return nil, err
+		}
+		routes = append(routes, r)
+	}
+	return routes, rows.Err()
+}
+
 // DeleteService removes a service by name.
 func (s *Store) DeleteService(ctx context.Context, name string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM instances WHERE service=?`, name); err != nil {
+		return fmt.Errorf("deleting instances of %s: %w", name, err)
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM overrides WHERE service=?`, name); err != nil {
+		return fmt.Errorf("deleting overrides of %s: %w", name, err)
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM routes WHERE service=?`, name); err != nil {
+		return fmt.Errorf("deleting routes of %s: %w", name, err)
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM auth_users WHERE service=?`, name); err != nil {
+		return fmt.Errorf("deleting auth users of %s: %w", name, err)
+	}
 	result, err := s.db.ExecContext(ctx, `DELETE FROM services WHERE name=?`, name)
 	if err != nil {
 		return fmt.Errorf("deleting service %s: %w", name, err)
@@ -170,9 +346,11 @@ func (s *Store) DeleteService(ctx context.Context, name string) error {
 	return nil
 }
 
-// UsedPorts returns all ports currently assigned to services.
+// UsedPorts returns all ports currently assigned to services and their instances.
 func (s *Store) UsedPorts(ctx context.Context) ([]int, error) {
-	rows, err := s.db.QueryContext(ctx, `SELECT port FROM services ORDER BY port`)
+	rows, err := s.db.QueryContext(ctx, `SELECT port FROM services WHERE port IS NOT NULL
+		 UNION SELECT port FROM instances
+		 ORDER BY port`)
 	if err != nil {
 		return nil, fmt.Errorf("querying used ports: %w", err)
 	}
@@ -192,7 +370,9 @@ func (s *Store) UsedPorts(ctx context.Context) ([]int, error) {
 // PortOwner returns the name of the service using the given port, or empty string if free.
 func (s *Store) PortOwner(ctx context.Context, port int) (string, error) {
 	var name string
-	err := s.db.QueryRowContext(ctx, `SELECT name FROM services WHERE port=?`, port).Scan(&name)
+	err := s.db.QueryRowContext(ctx,
+		`SELECT name FROM services WHERE port=?
+		 UNION SELECT service FROM instances WHERE port=?`, port, port).Scan(&name)
 	if errors.Is(err, sql.ErrNoRows) {
 		return "", nil
 	}
@@ -202,6 +382,133 @@ func (s *Store) PortOwner(ctx context.Context, port int) (string, error) {
 	return name, nil
 }
 
+// Instances returns the instance ports of a scaled service, lowest first.
+func (s *Store) Instances(ctx context.Context, service string) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT port FROM instances WHERE service=? ORDER BY port`, service)
+	if err != nil {
+		return nil, fmt.Errorf("querying instances of %s: %w", service, err)
+	}
+	defer rows.Close()
+
+	var ports []int
+	for rows.Next() {
+		var p int
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		ports = append(ports, p)
+	}
+	return ports, rows.Err()
+}
+
+// AddInstance records an instance of a scaled service on port.
+func (s *Store) AddInstance(ctx context.Context, service string, port int) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO instances (port, service) VALUES (?, ?)`, port, service)
+	if err != nil {
+		return fmt.Errorf("adding instance %d of %s: %w", port, service, err)
+	}
+	return nil
+}
+
+// RemoveInstance forgets the instance of a scaled service on port.
+func (s *Store) RemoveInstance(ctx context.Context, service string, port int) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM instances WHERE service=? AND port=?`, service, port)
+	if err != nil {
+		return fmt.Errorf("removing instance %d of %s: %w", port, service, err)
+	}
+	return nil
+}
+
+// SetOverride creates or replaces a drop-in override.
+func (s *Store) SetOverride(ctx context.Context, o *Override) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO overrides (service, name, content) VALUES (?, ?, ?)
+		 ON CONFLICT (service, name) DO UPDATE SET content=excluded.content`,
+		o.Service, o.Name, o.Content)
+	if err != nil {
+		return fmt.Errorf("setting override %s of %s: %w", o.Name, o.Service, err)
+	}
+	return nil
+}
+
+// Overrides returns a service's drop-in overrides ordered by name.
+func (s *Store) Overrides(ctx context.Context, service string) ([]*Override, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT service, name, content FROM overrides WHERE service=? ORDER BY name`, service)
+	if err != nil {
+		return nil, fmt.Errorf("querying overrides of %s: %w", service, err)
+	}
+	defer rows.Close()
+
+	var overrides []*Override
+	for rows.Next() {
+		var o Override
+		if err := rows.Scan(&o.Service, &o.Name, &o.Content); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, &o)
+	}
+	return overrides, rows.Err()
+}
+
+// DeleteOverride removes a drop-in override.
+func (s *Store) DeleteOverride(ctx context.Context, service, name string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM overrides WHERE service=? AND name=?`, service, name)
+	if err != nil {
+		return fmt.Errorf("deleting override %s of %s: %w", name, service, err)
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetAuthUser creates a basic auth user or replaces their password hash.
+func (s *Store) SetAuthUser(ctx context.Context, u *AuthUser) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO auth_users (service, user, hash) VALUES (?, ?, ?)
+		 ON CONFLICT (service, user) DO UPDATE SET hash=excluded.hash`,
+		u.Service, u.User, u.Hash)
+	if err != nil {
+		return fmt.Errorf("setting auth user %s of %s: %w", u.User, u.Service, err)
+	}
+	return nil
+}
+
+// AuthUsers returns a service's basic auth users ordered by name.
+func (s *Store) AuthUsers(ctx context.Context, service string) ([]*AuthUser, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT service, user, hash FROM auth_users WHERE service=? ORDER BY user`, service)
+	if err != nil {
+		return nil, fmt.Errorf("querying auth users of %s: %w", service, err)
+	}
+	defer rows.Close()
+
+	var users []*AuthUser
+	for rows.Next() {
+		var u AuthUser
+		if err := rows.Scan(&u.Service, &u.User, &u.Hash); err != nil {
+			return nil, err
+		}
+		users = append(users, &u)
+	}
+	return users, rows.Err()
+}
+
+// DeleteAuthUser removes a basic auth user.
+func (s *Store) DeleteAuthUser(ctx context.Context, service, user string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM auth_users WHERE service=? AND user=?`, service, user)
+	if err != nil {
+		return fmt.Errorf("deleting auth user %s of %s: %w", user, service, err)
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // AppendHistory records an action in the history table.
 func (s *Store) AppendHistory(ctx context.Context, entry *HistoryEntry) error {
 	detail, err := marshalJSON(entry.Detail)
@@ -249,17 +556,35 @@ func (s *Store) ListHistory(ctx context.Context, service string) ([]*HistoryEntr
 	return entries, rows.Err()
 }
 
-// scanService scans a single row into a Service.
-func scanService(row *sql.Row) (*Service, error) {
+// serviceColumns lists the services columns in the order scanService expects.
+const serviceColumns = `name, repo, version, prev_version, port, db_name, db_user, db_provider, backup_schedule, extra_env, config_file, exec_args, work_dir, socket_activated, hardening, schedule, pre_start, tls, tls_cert, tls_key, proto, keepalive, read_timeout, max_body_size, allow_cidrs, deny_cidrs, rate_limit, rate_burst, maintenance, deployed_at, updated_at`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanService scans a row selected with serviceColumns into a Service.
+func scanService(row rowScanner) (*Service, error) {
 	var svc Service
 	var prevVersion sql.NullString
-	var extraEnv sql.NullString
+	var extraEnv, args, workDir, schedule, preStart sql.NullString
+	var tls, tlsCert, tlsKey sql.NullString
+	var readTimeout, maxBodySize sql.NullString
+	var allow, deny, rateLimit sql.NullString
+	var backupSchedule sql.NullString
+	var port sql.NullInt64
 	var deployedAt, updatedAt int64
 
 	err := row.Scan(
 		&svc.Name, &svc.Repo, &svc.Version, &prevVersion,
-		&svc.Port, &svc.RouteType, &svc.RouteValue,
-		&svc.DBName, &svc.DBUser, &extraEnv,
+		&port,
+		&svc.DBName, &svc.DBUser, &svc.DBProvider, &backupSchedule, &extraEnv, &svc.ConfigFile,
+		&args, &workDir, &svc.SocketActivated, &svc.Hardening,
+		&schedule, &preStart,
+		&tls, &tlsCert, &tlsKey,
+		&svc.Proto, &svc.Keepalive, &readTimeout, &maxBodySize,
+		&allow, &deny, &rateLimit, &svc.RateBurst, &svc.Maintenance,
 		&deployedAt, &updatedAt,
 	)
 	if err != nil {
@@ -267,6 +592,16 @@ func scanService(row *sql.Row) (*Service, error) {
 	}
 
 	svc.PrevVersion = prevVersion.String
+	svc.Port = int(port.Int64)
+	svc.WorkDir = workDir.String
+	svc.Schedule = schedule.String
+	svc.BackupSchedule = backupSchedule.String
+	svc.TLS = tls.String
+	svc.TLSCert = tlsCert.String
+	svc.TLSKey = tlsKey.String
+	svc.ReadTimeout = readTimeout.String
+	svc.MaxBodySize = maxBodySize.String
+	svc.RateLimit = rateLimit.String
 	svc.DeployedAt = time.Unix(deployedAt, 0)
 	svc.UpdatedAt = time.Unix(updatedAt, 0)
 
@@ -275,34 +610,24 @@ func scanService(row *sql.Row) (*Service, error) {
 			return nil, fmt.Errorf("unmarshaling extra_env: %w", err)
 		}
 	}
-
-	return &svc, nil
-}
-
-// scanServiceFromRows scans a row from *sql.Rows into a Service.
-func scanServiceFromRows(rows *sql.Rows) (*Service, error) {
-	var svc Service
-	var prevVersion sql.NullString
-	var extraEnv sql.NullString
-	var deployedAt, updatedAt int64
-
-	err := rows.Scan(
-		&svc.Name, &svc.Repo, &svc.Version, &prevVersion,
-		&svc.Port, &svc.RouteType, &svc.RouteValue,
-		&svc.DBName, &svc.DBUser, &extraEnv,
-		&deployedAt, &updatedAt,
-	)
-	if err != nil {
-		return nil, err
+	if args.Valid && args.String != "" {
+		if err := json.Unmarshal([]byte(args.String), &svc.Args); err != nil {
+			return nil, fmt.Errorf("unmarshaling exec_args: %w", err)
+		}
 	}
-
-	svc.PrevVersion = prevVersion.String
-	svc.DeployedAt = time.Unix(deployedAt, 0)
-	svc.UpdatedAt = time.Unix(updatedAt, 0)
-
-	if extraEnv.Valid && extraEnv.String != "" {
-		if err := json.Unmarshal([]byte(extraEnv.String), &svc.ExtraEnv); err != nil {
-			return nil, fmt.Errorf("unmarshaling extra_env: %w", err)
+	if preStart.Valid && preStart.String != "" {
+		if err := json.Unmarshal([]byte(preStart.String), &svc.PreStart); err != nil {
+			return nil, fmt.Errorf("unmarshaling pre_start: %w", err)
+		}
+	}
+	if allow.Valid && allow.String != "" {
+		if err := json.Unmarshal([]byte(allow.String), &svc.Allow); err != nil {
+			return nil, fmt.Errorf("unmarshaling allow_cidrs: %w", err)
+		}
+	}
+	if deny.Valid && deny.String != "" {
+		if err := json.Unmarshal([]byte(deny.String), &svc.Deny); err != nil {
+			return nil, fmt.Errorf("unmarshaling deny_cidrs: %w", err)
 		}
 	}
 
@@ -313,10 +638,13 @@ func marshalJSON(v interface{}) (sql.NullString, error) {
 	if v == nil {
 		return sql.NullString{}, nil
 	}
-	// Check for empty map
+	// Check for empty map or slice
 	if m, ok := v.(map[string]string); ok && len(m) == 0 {
 		return sql.NullString{}, nil
 	}
+	if l, ok := v.([]string); ok && len(l) == 0 {
+		return sql.NullString{}, nil
+	}
 	data, err := json.Marshal(v)
 	if err != nil {
 		return sql.NullString{}, fmt.Errorf("marshaling JSON: %w", err)
@@ -324,6 +652,28 @@ func marshalJSON(v interface{}) (sql.NullString, error) {
 	return sql.NullString{String: string(data), Valid: true}, nil
 }
 
+// hardening defaults an unset profile to baseline, matching the column default.
+func hardening(profile string) string {
+	if profile == "" {
+		return "baseline"
+	}
+	return profile
+}
+
+func proto(p string) string {
+	if p == "" {
+		return "http"
+	}
+	return p
+}
+
+func nullInt(n int) sql.NullInt64 {
+	if n == 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(n), Valid: true}
+}
+
 func nullString(s string) sql.NullString {
 	if s == "" {
 		return sql.NullString{}
@@ -2,6 +2,8 @@ package state
 
 import (
 	"context"
+	"database/sql"
+	"strings"
 	"testing"
 	"time"
 )
@@ -23,10 +25,10 @@ func testService(name string, port int) *Service {
 		Repo:       "testowner/" + name,
 		Version:    "v1.0.0",
 		Port:       port,
-		RouteType:  "subdomain",
-		RouteValue: name + ".example.com",
+		Routes:     []Route{{Type: "subdomain", Value: name + ".example.com"}},
 		DBName:     "gc_" + name,
 		DBUser:     "gc_" + name,
+		DBProvider: "mariadb",
 		DeployedAt: now,
 		UpdatedAt:  now,
 	}
@@ -62,12 +64,62 @@ func TestIdempotentOpen(t *testing.T) {
 	s2.Close()
 }
 
+func TestMigrateLegacyDatabase(t *testing.T) {
+	dbPath := t.TempDir() + "/state.db"
+
+	// Simulate a database created before any migrations existed
+	legacy, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("opening legacy db: %v", err)
+	}
+	if _, err := legacy.Exec(schema); err != nil {
+		t.Fatalf("creating legacy schema: %v", err)
+	}
+	if _, err := legacy.Exec(`INSERT INTO services (name, repo, version, port, route_type, route_value, db_name, db_user, deployed_at, updated_at)
+		VALUES ('api', 'o/api', 'v1.0.0', 3000, 'subdomain', 'api.example.com', '', '', 0, 0),
+		       ('shop', 'o/shop', 'v1.0.0', 3001, 'subdomain', 'shop.example.com', 'gc_shop', 'gc_shop', 0, 0)`); err != nil {
+		t.Fatalf("inserting legacy row: %v", err)
+	}
+	legacy.Close()
+
+	s, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("open with migrations: %v", err)
+	}
+	defer s.Close()
+
+	got, err := s.GetService(context.Background(), "api")
+	if err != nil {
+		t.Fatalf("get migrated service: %v", err)
+	}
+	if got.Args != nil || got.WorkDir != "" {
+		t.Errorf("migrated service should have no args/workdir, got %v %q", got.Args, got.WorkDir)
+	}
+	if len(got.Routes) != 1 || got.Routes[0] != (Route{Type: "subdomain", Value: "api.example.com"}) {
+		t.Errorf("migrated routes = %v, want the legacy route", got.Routes)
+	}
+	if got.DBProvider != "" {
+		t.Errorf("service without a database has provider %q", got.DBProvider)
+	}
+	if shop, _ := s.GetService(context.Background(), "shop"); shop == nil || shop.DBProvider != "mariadb" {
+		t.Errorf("legacy database should be recorded as mariadb, got %+v", shop)
+	}
+
+	var version int
+	s.db.QueryRow("PRAGMA user_version").Scan(&version)
+	if version != len(migrations) {
+		t.Errorf("user_version = %d, want %d", version, len(migrations))
+	}
+}
+
 func TestCRUDRoundTrip(t *testing.T) {
 	s := openTestStore(t)
 	ctx := context.Background()
 
 	svc := testService("api", 3000)
 	svc.ExtraEnv = map[string]string{"LOG_LEVEL": "info"}
+	svc.BackupSchedule = "daily"
+	svc.ConfigFile = true
 
 	// Insert
 	if err := s.InsertService(ctx, svc); err != nil {
@@ -88,6 +140,15 @@ func TestCRUDRoundTrip(t *testing.T) {
 	if got.ExtraEnv["LOG_LEVEL"] != "info" {
 		t.Errorf("extra_env[LOG_LEVEL] = %q, want %q", got.ExtraEnv["LOG_LEVEL"], "info")
 	}
+	if got.DBProvider != "mariadb" {
+		t.Errorf("db_provider = %q, want mariadb", got.DBProvider)
+	}
+	if got.BackupSchedule != "daily" {
+		t.Errorf("backup_schedule = %q, want daily", got.BackupSchedule)
+	}
+	if !got.ConfigFile {
+		t.Error("config_file should be kept")
+	}
 	if !got.DeployedAt.Equal(svc.DeployedAt) {
 		t.Errorf("deployed_at = %v, want %v", got.DeployedAt, svc.DeployedAt)
 	}
@@ -209,6 +270,196 @@ func TestJSONRoundTrip(t *testing.T) {
 	}
 }
 
+func TestArgsRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	svc := testService("api", 3000)
+	svc.Args = []string{"serve", "--config", "/etc/api config.toml"}
+	svc.WorkDir = "/srv/api"
+	s.InsertService(ctx, svc)
+
+	got, _ := s.GetService(ctx, "api")
+	if len(got.Args) != 3 || got.Args[2] != "/etc/api config.toml" {
+		t.Errorf("args = %q, want %q", got.Args, svc.Args)
+	}
+	if got.WorkDir != "/srv/api" {
+		t.Errorf("work_dir = %q, want %q", got.WorkDir, "/srv/api")
+	}
+
+	// Args survive an update that changes only the version
+	got.Version = "v2.0.0"
+	s.UpdateService(ctx, got)
+	updated, _ := s.GetService(ctx, "api")
+	if len(updated.Args) != 3 {
+		t.Errorf("args after update = %q, want %q", updated.Args, svc.Args)
+	}
+}
+
+func TestPreStartRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	svc := testService("api", 3000)
+	svc.PreStart = []string{"migrate", "up"}
+	s.InsertService(ctx, svc)
+
+	got, _ := s.GetService(ctx, "api")
+	if strings.Join(got.PreStart, " ") != "migrate up" {
+		t.Errorf("pre_start = %q, want %q", got.PreStart, svc.PreStart)
+	}
+
+	plain := testService("web", 3001)
+	s.InsertService(ctx, plain)
+	got, _ = s.GetService(ctx, "web")
+	if got.PreStart != nil {
+		t.Errorf("pre_start = %q, want nil", got.PreStart)
+	}
+}
+
+func TestTLSRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	svc := testService("api", 3000)
+	svc.TLS = "acme"
+	svc.TLSCert = "/etc/gophercaptain/certs/api.example.com/fullchain.pem"
+	svc.TLSKey = "/etc/gophercaptain/certs/api.example.com/privkey.pem"
+	s.InsertService(ctx, svc)
+
+	got, _ := s.GetService(ctx, "api")
+	if got.TLS != "acme" || got.TLSCert != svc.TLSCert || got.TLSKey != svc.TLSKey {
+		t.Errorf("tls = %q %q %q, want %q %q %q", got.TLS, got.TLSCert, got.TLSKey, svc.TLS, svc.TLSCert, svc.TLSKey)
+	}
+
+	got.TLS, got.TLSCert, got.TLSKey = "", "", ""
+	s.UpdateService(ctx, got)
+	got, _ = s.GetService(ctx, "api")
+	if got.TLS != "" || got.TLSCert != "" {
+		t.Errorf("tls should clear, got %q %q", got.TLS, got.TLSCert)
+	}
+}
+
+func TestProxyOptionsRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	s.InsertService(ctx, testService("web", 3000))
+	got, _ := s.GetService(ctx, "web")
+	if got.Proto != "http" || got.Keepalive != 0 || got.ReadTimeout != "" || got.MaxBodySize != "" {
+		t.Errorf("defaults = %q %d %q %q, want http 0 \"\" \"\"", got.Proto, got.Keepalive, got.ReadTimeout, got.MaxBodySize)
+	}
+
+	got.Proto, got.Keepalive, got.ReadTimeout, got.MaxBodySize = "websocket", 16, "3600s", "50m"
+	s.UpdateService(ctx, got)
+	got, _ = s.GetService(ctx, "web")
+	if got.Proto != "websocket" || got.Keepalive != 16 || got.ReadTimeout != "3600s" || got.MaxBodySize != "50m" {
+		t.Errorf("options = %q %d %q %q", got.Proto, got.Keepalive, got.ReadTimeout, got.MaxBodySize)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	svc := testService("api", 3000)
+	svc.Routes = []Route{
+		{Type: "subdomain", Value: "api.example.org"},
+		{Type: "subdomain", Value: "api.example.com"},
+		{Type: "path", Value: "example.com/api"},
+	}
+	s.InsertService(ctx, svc)
+	s.InsertService(ctx, testService("worker", 3001))
+
+	got, _ := s.GetService(ctx, "api")
+	if len(got.Routes) != 3 || got.Routes[0].Value != "api.example.org" || got.Routes[2].Type != "path" {
+		t.Errorf("routes = %v, want them in deploy order", got.Routes)
+	}
+
+	got.Routes = got.Routes[1:2]
+	s.UpdateService(ctx, got)
+	got, _ = s.GetService(ctx, "api")
+	if len(got.Routes) != 1 || got.Routes[0].Value != "api.example.com" {
+		t.Errorf("routes after update = %v", got.Routes)
+	}
+
+	unrouted := testService("job", 0)
+	unrouted.Routes = nil
+	s.InsertService(ctx, unrouted)
+	got, _ = s.GetService(ctx, "job")
+	if got.Routes != nil {
+		t.Errorf("unrouted service routes = %v, want nil", got.Routes)
+	}
+
+	s.DeleteService(ctx, "api")
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM routes WHERE service='api'`).Scan(&n)
+	if n != 0 {
+		t.Errorf("routes should be deleted with the service, %d left", n)
+	}
+}
+
+func TestSocketActivatedRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	svc := testService("tool", 3000)
+	svc.SocketActivated = true
+	s.InsertService(ctx, svc)
+
+	got, _ := s.GetService(ctx, "tool")
+	if !got.SocketActivated {
+		t.Error("socket_activated should round-trip")
+	}
+}
+
+func TestHardeningDefaultsToBaseline(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	s.InsertService(ctx, testService("api", 3000))
+	strict := testService("auth", 3001)
+	strict.Hardening = "strict"
+	s.InsertService(ctx, strict)
+
+	got, _ := s.GetService(ctx, "api")
+	if got.Hardening != "baseline" {
+		t.Errorf("hardening = %q, want %q", got.Hardening, "baseline")
+	}
+	got, _ = s.GetService(ctx, "auth")
+	if got.Hardening != "strict" {
+		t.Errorf("hardening = %q, want %q", got.Hardening, "strict")
+	}
+}
+
+func TestScheduledJobsHaveNoPort(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	// Several port-less jobs must not collide on the unique port column
+	for _, name := range []string{"nightly", "cleanup"} {
+		job := testService(name, 0)
+		job.Schedule = "*-*-* 02:00"
+		if err := s.InsertService(ctx, job); err != nil {
+			t.Fatalf("insert %s: %v", name, err)
+		}
+	}
+	s.InsertService(ctx, testService("api", 3000))
+
+	got, _ := s.GetService(ctx, "nightly")
+	if got.Port != 0 || got.Schedule != "*-*-* 02:00" {
+		t.Errorf("job = port %d schedule %q, want 0 and the schedule", got.Port, got.Schedule)
+	}
+
+	ports, err := s.UsedPorts(ctx)
+	if err != nil {
+		t.Fatalf("used ports: %v", err)
+	}
+	if len(ports) != 1 || ports[0] != 3000 {
+		t.Errorf("used ports = %v, want [3000]", ports)
+	}
+}
+
 func TestNilExtraEnv(t *testing.T) {
 	s := openTestStore(t)
 	ctx := context.Background()
@@ -265,3 +516,132 @@ func TestHistory(t *testing.T) {
 		t.Errorf("second entry detail[port] = %q, want %q", entries[1].Detail["port"], "3000")
 	}
 }
+
+func TestInstances(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	s.InsertService(ctx, testService("api", 3000))
+	for _, port := range []int{3000, 3002, 3001} {
+		if err := s.AddInstance(ctx, "api", port); err != nil {
+			t.Fatalf("AddInstance(%d): %v", port, err)
+		}
+	}
+
+	got, _ := s.GetService(ctx, "api")
+	if len(got.Instances) != 3 || got.Instances[0] != 3000 || got.Instances[2] != 3002 {
+		t.Errorf("instances = %v, want [3000 3001 3002]", got.Instances)
+	}
+
+	// Instance ports are taken, and the service's own port is not counted twice
+	used, _ := s.UsedPorts(ctx)
+	if len(used) != 3 {
+		t.Errorf("used ports = %v, want 3 ports", used)
+	}
+	if owner, _ := s.PortOwner(ctx, 3002); owner != "api" {
+		t.Errorf("owner of 3002 = %q, want api", owner)
+	}
+
+	s.RemoveInstance(ctx, "api", 3002)
+	if owner, _ := s.PortOwner(ctx, 3002); owner != "" {
+		t.Errorf("owner of 3002 after removal = %q, want none", owner)
+	}
+
+	s.DeleteService(ctx, "api")
+	if used, _ := s.UsedPorts(ctx); len(used) != 0 {
+		t.Errorf("used ports after delete = %v, want none", used)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	s.InsertService(ctx, testService("api", 3000))
+	s.SetOverride(ctx, &Override{Service: "api", Name: "limits", Content: "[Service]\nLimitNOFILE=1024\n"})
+	s.SetOverride(ctx, &Override{Service: "api", Name: "env", Content: "[Service]\nEnvironment=A=1\n"})
+	// Setting an existing name replaces it
+	s.SetOverride(ctx, &Override{Service: "api", Name: "limits", Content: "[Service]\nLimitNOFILE=65536\n"})
+
+	got, err := s.Overrides(ctx, "api")
+	if err != nil {
+		t.Fatalf("Overrides: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "env" || got[1].Content != "[Service]\nLimitNOFILE=65536\n" {
+		t.Errorf("overrides = %+v", got)
+	}
+
+	if err := s.DeleteOverride(ctx, "api", "env"); err != nil {
+		t.Fatalf("DeleteOverride: %v", err)
+	}
+	if err := s.DeleteOverride(ctx, "api", "env"); err != ErrNotFound {
+		t.Errorf("deleting a missing override: err = %v, want ErrNotFound", err)
+	}
+
+	s.DeleteService(ctx, "api")
+	if got, _ := s.Overrides(ctx, "api"); len(got) != 0 {
+		t.Errorf("overrides after delete = %+v, want none", got)
+	}
+}
+
+func TestAccessRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	svc := testService("admin", 3000)
+	svc.Allow = []string{"10.0.0.0/8", "192.168.1.5"}
+	svc.Deny = []string{"10.0.0.13"}
+	svc.RateLimit, svc.RateBurst = "10r/s", 20
+	s.InsertService(ctx, svc)
+
+	got, _ := s.GetService(ctx, "admin")
+	if len(got.Allow) != 2 || got.Allow[1] != "192.168.1.5" || len(got.Deny) != 1 || got.RateLimit != "10r/s" || got.RateBurst != 20 {
+		t.Errorf("access = %q %q %q %d", got.Allow, got.Deny, got.RateLimit, got.RateBurst)
+	}
+
+	if got.Maintenance {
+		t.Error("maintenance should default to off")
+	}
+
+	got.Allow, got.Deny, got.RateLimit, got.RateBurst = nil, nil, "", 0
+	got.Maintenance = true
+	s.UpdateService(ctx, got)
+	got, _ = s.GetService(ctx, "admin")
+	if got.Allow != nil || got.Deny != nil || got.RateLimit != "" {
+		t.Errorf("access should clear, got %q %q %q", got.Allow, got.Deny, got.RateLimit)
+	}
+	if !got.Maintenance {
+		t.Error("maintenance should be stored")
+	}
+}
+
+func TestAuthUsers(t *testing.T) {
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	s.InsertService(ctx, testService("admin", 3000))
+	s.SetAuthUser(ctx, &AuthUser{Service: "admin", User: "carol", Hash: "h1"})
+	s.SetAuthUser(ctx, &AuthUser{Service: "admin", User: "alice", Hash: "h2"})
+	// Setting an existing user replaces the hash
+	s.SetAuthUser(ctx, &AuthUser{Service: "admin", User: "carol", Hash: "h3"})
+
+	got, err := s.AuthUsers(ctx, "admin")
+	if err != nil {
+		t.Fatalf("AuthUsers: %v", err)
+	}
+	if len(got) != 2 || got[0].User != "alice" || got[1].Hash != "h3" {
+		t.Errorf("users = %+v", got)
+	}
+
+	if err := s.DeleteAuthUser(ctx, "admin", "alice"); err != nil {
+		t.Fatalf("DeleteAuthUser: %v", err)
+	}
+	if err := s.DeleteAuthUser(ctx, "admin", "alice"); err != ErrNotFound {
+		t.Errorf("deleting a missing user: err = %v, want ErrNotFound", err)
+	}
+
+	s.DeleteService(ctx, "admin")
+	if got, _ := s.AuthUsers(ctx, "admin"); len(got) != 0 {
+		t.Errorf("users after delete = %+v, want none", got)
+	}
+}
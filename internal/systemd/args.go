@@ -0,0 +1,81 @@
+package systemd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitArgs splits a command-line string into arguments using shell-like
+// rules: whitespace separates arguments, single quotes are literal, double
+// quotes allow \" and \\ escapes, and a backslash outside quotes escapes the
+// next character.
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case quote == '"':
+			switch r {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				cur.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in %q", quote, s)
+	}
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash in %q", s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// escapeArg quotes a single argument for an ExecStart= line. systemd expands
+// % specifiers and $ variables even inside quotes, so those are always doubled.
+func escapeArg(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	arg = strings.ReplaceAll(arg, "$", "$$")
+
+	if arg != "" && arg != ";" && !strings.ContainsAny(arg, " \t\n\"'\\") {
+		return arg
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+	return `"` + r.Replace(arg) + `"`
+}
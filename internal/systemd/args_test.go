@@ -0,0 +1,63 @@
+package systemd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"serve --http", []string{"serve", "--http"}},
+		{"  serve   --http  ", []string{"serve", "--http"}},
+		{`serve --config "/etc/my app.toml"`, []string{"serve", "--config", "/etc/my app.toml"}},
+		{`--name 'a "quoted" b'`, []string{"--name", `a "quoted" b`}},
+		{`--msg "say \"hi\""`, []string{"--msg", `say "hi"`}},
+		{`path\ with\ spaces`, []string{"path with spaces"}},
+		{`--empty ""`, []string{"--empty", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := SplitArgs(tt.in)
+			if err != nil {
+				t.Fatalf("SplitArgs(%q): %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitArgs(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitArgsErrors(t *testing.T) {
+	for _, in := range []string{`serve "unterminated`, `serve 'open`, `trailing\`} {
+		if _, err := SplitArgs(in); err == nil {
+			t.Errorf("SplitArgs(%q) should fail", in)
+		}
+	}
+}
+
+func TestEscapeArg(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"serve", "serve"},
+		{"--http", "--http"},
+		{"/etc/my app.toml", `"/etc/my app.toml"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"100%", "100%%"},
+		{"$HOME", "$$HOME"},
+		{"", `""`},
+		{";", `";"`},
+		{`C:\path`, `"C:\\path"`},
+	}
+	for _, tt := range tests {
+		if got := escapeArg(tt.in); got != tt.want {
+			t.Errorf("escapeArg(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package systemd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// backupUnit runs the scheduled database backups of one service. Like
+// gophercaptain-renew it is run by the tool, so it sits outside the
+// service's gc- units and survives upgrades untouched.
+func backupUnit(name string) string {
+	return "gophercaptain-backup-" + name
+}
+
+const backupServiceTemplate = `[Unit]
+Description=GopherCaptain: back up the database of {{.Name}}
+After={{.DatabaseUnit}}
+
+[Service]
+Type=oneshot
+ExecStart={{escapeArg .Binary}} db backup {{.Name}}
+`
+
+const backupTimerTemplate = `[Unit]
+Description=GopherCaptain: back up the database of {{.Name}} (timer)
+
+[Timer]
+OnCalendar={{.Schedule}}
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+
+var (
+	parsedBackupServiceTemplate = template.Must(template.New("backup").Funcs(template.FuncMap{
+		"escapeArg": escapeArg,
+	}).Parse(backupServiceTemplate))
+	parsedBackupTimerTemplate = template.Must(template.New("backup-timer").Parse(backupTimerTemplate))
+)
+
+// BackupParams describes the scheduled backups of a service's database.
+type BackupParams struct {
+	Binary     string // gophercaptain binary run by the timer
+	Name       string
+	Schedule   string // OnCalendar= expression
+	DBProvider string
+}
+
+// DatabaseUnit returns the database server unit backups run after.
+func (p BackupParams) DatabaseUnit() string {
+	return databaseUnit(p.DBProvider)
+}
+
+// EnableBackupTimer installs gophercaptain-backup-<name>.timer, which runs
+// "<binary> db backup <name>" on the schedule, and starts it. Calling it
+// again replaces the schedule.
+func (m *Manager) EnableBackupTimer(ctx context.Context, params BackupParams) error {
+	unit := backupUnit(params.Name)
+	var service, timer bytes.Buffer
+	if err := parsedBackupServiceTemplate.Execute(&service, params); err != nil {
+		return fmt.Errorf("rendering backup unit for %s: %w", params.Name, err)
+	}
+	if err := parsedBackupTimerTemplate.Execute(&timer, params); err != nil {
+		return fmt.Errorf("rendering backup timer for %s: %w", params.Name, err)
+	}
+	files := map[string]string{
+		unit + ".service": service.String(),
+		unit + ".timer":   timer.String(),
+	}
+	for file, content := range files {
+		path := filepath.Join(m.unitDir, file)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("writing unit file %s: %w", path, err)
+		}
+	}
+
+	if err := m.DaemonReload(ctx); err != nil {
+		return err
+	}
+	if err := m.enable(ctx, unit+".timer", params.Name); err != nil {
+		return err
+	}
+	// A restart picks up a changed schedule when the timer already runs
+	if err := m.stop(ctx, unit+".timer", params.Name); err != nil {
+		return err
+	}
+	return m.start(ctx, unit+".timer", params.Name)
+}
+
+// DisableBackupTimer stops and removes the backup timer of a service.
+func (m *Manager) DisableBackupTimer(ctx context.Context, name string) error {
+	unit := backupUnit(name)
+	if err := m.stop(ctx, unit+".timer", name); err != nil {
+		return err
+	}
+	if err := m.disable(ctx, unit+".timer", name); err != nil {
+		return err
+	}
+	for _, file := range []string{unit + ".timer", unit + ".service"} {
+		if err := m.removeFile(file); err != nil {
+			return err
+		}
+	}
+	return m.DaemonReload(ctx)
+}
@@ -0,0 +1,133 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+)
+
+// startTimeout bounds how long a start job may take before it is reported
+// as failed.
+const startTimeout = 10 * time.Second
+
+// Controller drives systemd units. Units are full unit names such as
+// gc-api.service. DBusController is the preferred implementation;
+// SystemctlController shells out and is used where the bus is unavailable.
+type Controller interface {
+	Reload(ctx context.Context) error
+	Enable(ctx context.Context, unit string) error
+	Disable(ctx context.Context, unit string) error
+	// Start returns once the unit is active, or an error describing why
+	// it did not become active within startTimeout.
+	Start(ctx context.Context, unit string) error
+	Stop(ctx context.Context, unit string) error
+	Status(ctx context.Context, unit string) (*UnitStatus, error)
+}
+
+// UnitStatus is a snapshot of a unit's runtime properties. The service
+// fields are zero for sockets and timers.
+type UnitStatus struct {
+	ActiveState    string // e.g. "active", "inactive", "failed"
+	SubState       string // e.g. "running", "listening", "waiting"
+	Result         string // result of the last run, e.g. "success", "exit-code"
+	MainPID        int
+	NRestarts      int
+	ExecMainStatus int
+}
+
+// SystemctlController implements Controller by running systemctl.
+type SystemctlController struct {
+	runner runner.CommandRunner
+}
+
+// NewSystemctlController creates a controller that shells out via r.
+func NewSystemctlController(r runner.CommandRunner) *SystemctlController {
+	return &SystemctlController{runner: r}
+}
+
+// Reload runs systemctl daemon-reload.
+func (c *SystemctlController) Reload(ctx context.Context) error {
+	return c.systemctl(ctx, "daemon-reload")
+}
+
+// Enable runs systemctl enable.
+func (c *SystemctlController) Enable(ctx context.Context, unit string) error {
+	return c.systemctl(ctx, "enable", unit)
+}
+
+// Disable runs systemctl disable.
+func (c *SystemctlController) Disable(ctx context.Context, unit string) error {
+	return c.systemctl(ctx, "disable", unit)
+}
+
+// Start runs systemctl start, then polls is-active every 500ms because the
+// exit status alone does not say whether the unit stayed up.
+func (c *SystemctlController) Start(ctx context.Context, unit string) error {
+	if err := c.systemctl(ctx, "start", unit); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(startTimeout)
+	for time.Now().Before(deadline) {
+		stdout, _, _ := c.runner.Run(ctx, "systemctl", "is-active", unit)
+		if strings.TrimSpace(stdout) == "active" {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return fmt.Errorf("%s failed to become active within %s", unit, startTimeout)
+}
+
+// Stop runs systemctl stop.
+func (c *SystemctlController) Stop(ctx context.Context, unit string) error {
+	return c.systemctl(ctx, "stop", unit)
+}
+
+// Status reads the unit's properties with systemctl show.
+func (c *SystemctlController) Status(ctx context.Context, unit string) (*UnitStatus, error) {
+	props, err := show(ctx, c.runner, unit, "ActiveState", "SubState", "Result", "MainPID", "NRestarts", "ExecMainStatus")
+	if err != nil {
+		return nil, err
+	}
+	status := &UnitStatus{
+		ActiveState: props["ActiveState"],
+		SubState:    props["SubState"],
+		Result:      props["Result"],
+	}
+	status.MainPID, _ = strconv.Atoi(props["MainPID"])
+	status.NRestarts, _ = strconv.Atoi(props["NRestarts"])
+	status.ExecMainStatus, _ = strconv.Atoi(props["ExecMainStatus"])
+	return status, nil
+}
+
+func (c *SystemctlController) systemctl(ctx context.Context, args ...string) error {
+	_, stderr, err := c.runner.Run(ctx, "systemctl", args...)
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %s: %w", strings.Join(args, " "), strings.TrimSpace(stderr), err)
+	}
+	return nil
+}
+
+// show runs systemctl show for the given properties and returns them as a map.
+func show(ctx context.Context, r runner.CommandRunner, unit string, props ...string) (map[string]string, error) {
+	args := []string{"show", unit}
+	for _, p := range props {
+		args = append(args, "-p", p)
+	}
+	stdout, stderr, err := r.Run(ctx, "systemctl", args...)
+	if err != nil {
+		return nil, fmt.Errorf("reading properties of %s: %s: %w", unit, strings.TrimSpace(stderr), err)
+	}
+
+	values := make(map[string]string)
+	for _, line := range strings.Split(stdout, "\n") {
+		if k, v, ok := strings.Cut(line, "="); ok {
+			values[k] = v
+		}
+	}
+	return values, nil
+}
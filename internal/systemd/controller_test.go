@@ -0,0 +1,77 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+)
+
+func TestSystemctlControllerStatus(t *testing.T) {
+	fake := runner.NewFakeRunner()
+	fake.SetResponse("systemctl show", runner.Response{
+		Stdout: "ActiveState=active\nSubState=running\nResult=success\nMainPID=4242\nNRestarts=3\nExecMainStatus=0\n",
+	})
+	ctl := NewSystemctlController(fake)
+
+	status, err := ctl.Status(context.Background(), "gc-api.service")
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	want := UnitStatus{ActiveState: "active", SubState: "running", Result: "success", MainPID: 4242, NRestarts: 3}
+	if *status != want {
+		t.Errorf("status = %+v, want %+v", *status, want)
+	}
+}
+
+func TestManagerUsesController(t *testing.T) {
+	fake := runner.NewFakeRunner()
+	ctl := NewFakeController()
+	mgr := NewWithController(fake, ctl, t.TempDir())
+	ctx := context.Background()
+
+	if err := mgr.Enable(ctx, "api"); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	if err := mgr.Start(ctx, "api"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if active, _ := mgr.IsActive(ctx, "api"); !active {
+		t.Error("service should be active after Start")
+	}
+	if err := mgr.Stop(ctx, "api"); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if active, _ := mgr.IsActive(ctx, "api"); active {
+		t.Error("service should be inactive after Stop")
+	}
+
+	for _, call := range []string{"enable gc-api.service", "start gc-api.service", "stop gc-api.service"} {
+		if !ctl.Called(call) {
+			t.Errorf("expected controller call %q", call)
+		}
+	}
+	if fake.Called("systemctl") {
+		t.Error("unit operations should not shell out to systemctl")
+	}
+}
+
+func TestManagerStartFailureIncludesJournal(t *testing.T) {
+	fake := runner.NewFakeRunner()
+	fake.SetResponse("journalctl", runner.Response{Stdout: "panic: missing DATABASE_URL\n"})
+	ctl := NewFakeController()
+	ctl.SetError("start gc-api.service", fmt.Errorf(`job for gc-api.service finished with result "failed"`))
+	mgr := NewWithController(fake, ctl, t.TempDir())
+
+	err := mgr.Start(context.Background(), "api")
+	if err == nil {
+		t.Fatal("expected error for failed start job")
+	}
+	for _, want := range []string{`result "failed"`, "missing DATABASE_URL"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+)
+
+// DBusController implements Controller over systemd's D-Bus API. Start and
+// Stop wait for the job's completion signal instead of polling, so failures
+// carry systemd's own job result.
+type DBusController struct {
+	conn *dbus.Conn
+}
+
+// NewDBusController connects to the system bus. Callers should fall back to
+// a SystemctlController if this fails and Close the controller when done.
+func NewDBusController(ctx context.Context) (*DBusController, error) {
+	conn, err := dbus.NewSystemConnectionContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to systemd over D-Bus: %w", err)
+	}
+	return &DBusController{conn: conn}, nil
+}
+
+// Close closes the bus connection.
+func (c *DBusController) Close() {
+	c.conn.Close()
+}
+
+// Reload is the equivalent of systemctl daemon-reload.
+func (c *DBusController) Reload(ctx context.Context) error {
+	if err := c.conn.ReloadContext(ctx); err != nil {
+		return fmt.Errorf("daemon-reload: %w", err)
+	}
+	return nil
+}
+
+// Enable creates the unit's [Install] symlinks and reloads, as systemctl
+// enable does.
+func (c *DBusController) Enable(ctx context.Context, unit string) error {
+	if _, _, err := c.conn.EnableUnitFilesContext(ctx, []string{unit}, false, true); err != nil {
+		return fmt.Errorf("enabling %s: %w", unit, err)
+	}
+	return c.Reload(ctx)
+}
+
+// Disable removes the unit's [Install] symlinks and reloads.
+func (c *DBusController) Disable(ctx context.Context, unit string) error {
+	if _, err := c.conn.DisableUnitFilesContext(ctx, []string{unit}, false); err != nil {
+		return fmt.Errorf("disabling %s: %w", unit, err)
+	}
+	return c.Reload(ctx)
+}
+
+// Start queues a start job and waits for its result. A job can complete
+// while the main process has already exited, so the active state is
+// checked afterwards too.
+func (c *DBusController) Start(ctx context.Context, unit string) error {
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+
+	if err := c.wait(ctx, unit, c.conn.StartUnitContext); err != nil {
+		return err
+	}
+	status, err := c.Status(ctx, unit)
+	if err != nil {
+		return err
+	}
+	if status.ActiveState != "active" {
+		return fmt.Errorf("%s is %s (%s) after starting", unit, status.ActiveState, status.SubState)
+	}
+	return nil
+}
+
+// Stop queues a stop job and waits for its result.
+func (c *DBusController) Stop(ctx context.Context, unit string) error {
+	return c.wait(ctx, unit, c.conn.StopUnitContext)
+}
+
+// Status reads the unit's properties, including the service-specific ones
+// for .service units.
+func (c *DBusController) Status(ctx context.Context, unit string) (*UnitStatus, error) {
+	props, err := c.conn.GetUnitPropertiesContext(ctx, unit)
+	if err != nil {
+		return nil, fmt.Errorf("reading properties of %s: %w", unit, err)
+	}
+	status := &UnitStatus{
+		ActiveState: stringProp(props, "ActiveState"),
+		SubState:    stringProp(props, "SubState"),
+	}
+	if !strings.HasSuffix(unit, ".service") {
+		return status, nil
+	}
+
+	props, err = c.conn.GetUnitTypePropertiesContext(ctx, unit, "Service")
+	if err != nil {
+		return nil, fmt.Errorf("reading service properties of %s: %w", unit, err)
+	}
+	status.Result = stringProp(props, "Result")
+	status.MainPID = intProp(props, "MainPID")
+	status.NRestarts = intProp(props, "NRestarts")
+	status.ExecMainStatus = intProp(props, "ExecMainStatus")
+	return status, nil
+}
+
+// jobFunc is the shape of the go-systemd calls that queue a unit job.
+type jobFunc func(ctx context.Context, name, mode string, ch chan<- string) (int, error)
+
+// wait queues a job with mode "replace" and blocks until systemd reports
+// its result. Anything but "done" (e.g. "failed", "timeout", "dependency")
+// is an error.
+func (c *DBusController) wait(ctx context.Context, unit string, queue jobFunc) error {
+	done := make(chan string, 1)
+	if _, err := queue(ctx, unit, "replace", done); err != nil {
+		return fmt.Errorf("queueing job for %s: %w", unit, err)
+	}
+	select {
+	case result := <-done:
+		if result != "done" {
+			return fmt.Errorf("job for %s finished with result %q", unit, result)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for job on %s: %w", unit, ctx.Err())
+	}
+}
+
+func stringProp(props map[string]any, key string) string {
+	s, _ := props[key].(string)
+	return s
+}
+
+// intProp converts systemd's unsigned and signed 32-bit properties.
+func intProp(props map[string]any, key string) int {
+	switch v := props[key].(type) {
+	case uint32:
+		return int(v)
+	case int32:
+		return int(v)
+	case uint64:
+		return int(v)
+	case int64:
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var dropInName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// RenderDropIn renders a drop-in file that sets directives in one section,
+// e.g. RenderDropIn("Service", []string{"LimitNOFILE=65536"}).
+func RenderDropIn(section string, directives []string) (string, error) {
+	if section == "" {
+		section = "Service"
+	}
+	if len(directives) == 0 {
+		return "", fmt.Errorf("no directives given")
+	}
+
+	var b strings.Builder
+	b.WriteString("# Managed by GopherCaptain; change with 'gophercaptain unit override'\n")
+	fmt.Fprintf(&b, "[%s]\n", section)
+	for _, d := range directives {
+		if !strings.Contains(d, "=") || strings.ContainsAny(d, "\n\r") {
+			return "", fmt.Errorf("invalid directive %q: must be a single Key=Value line", d)
+		}
+		b.WriteString(d + "\n")
+	}
+	return b.String(), nil
+}
+
+// ValidateDropInName checks that a drop-in name is usable as <name>.conf.
+func ValidateDropInName(name string) error {
+	if !dropInName.MatchString(name) {
+		return fmt.Errorf("invalid override name %q: use letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// dropInDirs are the drop-in directories of a service: one for
+// gc-<name>.service and one for the gc-<name>@.service template, so an
+// override applies whether or not the service is scaled.
+func (m *Manager) dropInDirs(name string) []string {
+	return []string{
+		filepath.Join(m.unitDir, unitName(name)+".d"),
+		filepath.Join(m.unitDir, templateName(name)+".d"),
+	}
+}
+
+// DropInPath returns where the named drop-in of a service is written.
+func (m *Manager) DropInPath(name, dropIn string) string {
+	return filepath.Join(m.dropInDirs(name)[0], dropIn+".conf")
+}
+
+// WriteDropIn writes a drop-in for the service. It lives beside the
+// generated unit, so re-rendering the unit leaves it in place.
+func (m *Manager) WriteDropIn(ctx context.Context, name, dropIn, content string) error {
+	if err := ValidateDropInName(dropIn); err != nil {
+		return err
+	}
+	for _, dir := range m.dropInDirs(name) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating drop-in directory %s: %w", dir, err)
+		}
+		path := filepath.Join(dir, dropIn+".conf")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("writing drop-in %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// RemoveDropIn deletes the named drop-in of a service, if any.
+func (m *Manager) RemoveDropIn(name, dropIn string) error {
+	for _, dir := range m.dropInDirs(name) {
+		path := filepath.Join(dir, dropIn+".conf")
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing drop-in %s: %w", path, err)
+		}
+		os.Remove(dir) // only succeeds once empty
+	}
+	return nil
+}
+
+// RemoveDropIns deletes every drop-in directory of a service.
+func (m *Manager) RemoveDropIns(name string) error {
+	for _, dir := range m.dropInDirs(name) {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("removing drop-in directory %s: %w", dir, err)
+		}
+	}
+	return nil
+}
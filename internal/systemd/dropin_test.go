@@ -0,0 +1,59 @@
+package systemd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+)
+
+func TestRenderDropIn(t *testing.T) {
+	got, err := RenderDropIn("", []string{"LimitNOFILE=65536", "Environment=GOMAXPROCS=2"})
+	if err != nil {
+		t.Fatalf("RenderDropIn: %v", err)
+	}
+	want := "# Managed by GopherCaptain; change with 'gophercaptain unit override'\n" +
+		"[Service]\nLimitNOFILE=65536\nEnvironment=GOMAXPROCS=2\n"
+	if got != want {
+		t.Errorf("drop-in =\n%s\nwant\n%s", got, want)
+	}
+
+	for _, bad := range [][]string{nil, {"LimitNOFILE"}, {"A=1\nExecStart=/bin/sh"}} {
+		if _, err := RenderDropIn("Service", bad); err == nil {
+			t.Errorf("RenderDropIn(%q) should fail", bad)
+		}
+	}
+}
+
+func TestWriteDropInSurvivesUnitRewrite(t *testing.T) {
+	dir := t.TempDir()
+	mgr := New(runner.NewFakeRunner(), dir)
+	ctx := context.Background()
+
+	if err := mgr.WriteDropIn(ctx, "api", "limits", "[Service]\nLimitNOFILE=65536\n"); err != nil {
+		t.Fatalf("WriteDropIn: %v", err)
+	}
+	mgr.WriteUnit(ctx, ServiceParams{Name: "api"})
+
+	for _, unit := range []string{"gc-api.service.d", "gc-api@.service.d"} {
+		if _, err := os.Stat(filepath.Join(dir, unit, "limits.conf")); err != nil {
+			t.Errorf("drop-in for %s: %v", unit, err)
+		}
+	}
+
+	if err := mgr.RemoveDropIn("api", "limits"); err != nil {
+		t.Fatalf("RemoveDropIn: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gc-api.service.d")); !os.IsNotExist(err) {
+		t.Error("empty drop-in directory should be removed")
+	}
+}
+
+func TestWriteDropInRejectsBadName(t *testing.T) {
+	mgr := New(runner.NewFakeRunner(), t.TempDir())
+	if err := mgr.WriteDropIn(context.Background(), "api", "../evil", "[Service]\n"); err == nil {
+		t.Error("expected error for path-like drop-in name")
+	}
+}
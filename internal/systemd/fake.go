@@ -0,0 +1,106 @@
+package systemd
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeController records unit operations and tracks active state in memory.
+// Exported for use by orchestrator and command tests.
+type FakeController struct {
+	mu     sync.Mutex
+	Calls  []string               // e.g. "start gc-api.service"
+	Units  map[string]*UnitStatus // current state by unit name
+	errors map[string]error       // keyed like Calls
+}
+
+// NewFakeController creates a FakeController with every unit inactive.
+func NewFakeController() *FakeController {
+	return &FakeController{
+		Units:  make(map[string]*UnitStatus),
+		errors: make(map[string]error),
+	}
+}
+
+// SetError makes the given call, e.g. "start gc-api.service", fail.
+func (f *FakeController) SetError(call string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors[call] = err
+}
+
+// Called reports whether the given call was made.
+func (f *FakeController) Called(call string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.Calls {
+		if c == call {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload records a daemon-reload.
+func (f *FakeController) Reload(ctx context.Context) error {
+	return f.record("daemon-reload")
+}
+
+// Enable records an enable.
+func (f *FakeController) Enable(ctx context.Context, unit string) error {
+	return f.record("enable " + unit)
+}
+
+// Disable records a disable.
+func (f *FakeController) Disable(ctx context.Context, unit string) error {
+	return f.record("disable " + unit)
+}
+
+// Start records a start and marks the unit active unless it was told to fail.
+func (f *FakeController) Start(ctx context.Context, unit string) error {
+	if err := f.record("start " + unit); err != nil {
+		f.setState(unit, "failed", "failed")
+		return err
+	}
+	f.setState(unit, "active", "running")
+	return nil
+}
+
+// Stop records a stop and marks the unit inactive.
+func (f *FakeController) Stop(ctx context.Context, unit string) error {
+	if err := f.record("stop " + unit); err != nil {
+		return err
+	}
+	f.setState(unit, "inactive", "dead")
+	return nil
+}
+
+// Status returns a copy of the unit's state, inactive if never started.
+func (f *FakeController) Status(ctx context.Context, unit string) (*UnitStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if s, ok := f.Units[unit]; ok {
+		status := *s
+		return &status, nil
+	}
+	return &UnitStatus{ActiveState: "inactive", SubState: "dead"}, nil
+}
+
+func (f *FakeController) record(call string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, call)
+	return f.errors[call]
+}
+
+func (f *FakeController) setState(unit, active, sub string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.Units[unit]
+	if !ok {
+		s = &UnitStatus{}
+		f.Units[unit] = s
+	}
+	s.ActiveState = active
+	s.SubState = sub
+}
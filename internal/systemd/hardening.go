@@ -0,0 +1,125 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Hardening profile names, from least to most restrictive.
+const (
+	HardeningBaseline = "baseline"
+	HardeningStrict   = "strict"
+	HardeningParanoid = "paranoid"
+)
+
+var baselineDirectives = []string{
+	"NoNewPrivileges=true",
+	"ProtectSystem=strict",
+	"ProtectHome=true",
+}
+
+// strictDirectives suit any ordinary network service.
+var strictDirectives = []string{
+	"PrivateTmp=true",
+	"PrivateDevices=true",
+	"ProtectKernelTunables=true",
+	"ProtectKernelModules=true",
+	"ProtectKernelLogs=true",
+	"ProtectControlGroups=true",
+	"ProtectClock=true",
+	"ProtectHostname=true",
+	"RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6",
+	"RestrictNamespaces=true",
+	"RestrictRealtime=true",
+	"RestrictSUIDSGID=true",
+	"LockPersonality=true",
+	"SystemCallArchitectures=native",
+	"SystemCallFilter=@system-service",
+	"CapabilityBoundingSet=",
+}
+
+// paranoidDirectives may break services that inspect other processes,
+// change resource limits or generate code at runtime.
+var paranoidDirectives = []string{
+	"AmbientCapabilities=",
+	"MemoryDenyWriteExecute=true",
+	"ProtectProc=invisible",
+	"ProcSubset=pid",
+	"SystemCallFilter=~@privileged @resources",
+	"RemoveIPC=true",
+	"UMask=0077",
+}
+
+// hardeningProfiles maps each profile to its cumulative directive list.
+var hardeningProfiles = map[string][]string{
+	HardeningBaseline: baselineDirectives,
+	HardeningStrict:   concat(baselineDirectives, strictDirectives),
+	HardeningParanoid: concat(baselineDirectives, strictDirectives, paranoidDirectives),
+}
+
+func concat(lists ...[]string) []string {
+	var out []string
+	for _, l := range lists {
+		out = append(out, l...)
+	}
+	return out
+}
+
+// ValidateHardening checks that profile names a known hardening profile.
+// The empty string is accepted and means baseline.
+func ValidateHardening(profile string) error {
+	if profile == "" {
+		return nil
+	}
+	if _, ok := hardeningProfiles[profile]; !ok {
+		names := make([]string, 0, len(hardeningProfiles))
+		for n := range hardeningProfiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown hardening profile %q; must be one of %s", profile, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// hardeningDirectives returns the unit directives for a profile.
+func hardeningDirectives(profile string) []string {
+	if profile == "" {
+		profile = HardeningBaseline
+	}
+	return hardeningProfiles[profile]
+}
+
+// SecurityScore is the result of systemd-analyze security for a unit.
+type SecurityScore struct {
+	Exposure float64 // 0.0 (most secure) to 10.0 (least secure)
+	Rating   string  // e.g. "OK", "MEDIUM", "EXPOSED", "UNSAFE"
+}
+
+var exposureLine = regexp.MustCompile(`Overall exposure level for \S+: ([0-9]+(?:\.[0-9]+)?) (\S+)`)
+
+// SecurityScore runs systemd-analyze security for the service and parses
+// the overall exposure level.
+func (m *Manager) SecurityScore(ctx context.Context, name string) (*SecurityScore, error) {
+	stdout, stderr, err := m.runner.Run(ctx, "systemd-analyze", "security", "--no-pager", unitName(name))
+	if err != nil {
+		return nil, fmt.Errorf("analyzing security of %s: %s: %w", name, strings.TrimSpace(stderr), err)
+	}
+	return parseSecurityScore(stdout)
+}
+
+func parseSecurityScore(output string) (*SecurityScore, error) {
+	match := exposureLine.FindStringSubmatch(output)
+	if match == nil {
+		return nil, fmt.Errorf("no exposure level in systemd-analyze output")
+	}
+	exposure, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing exposure level %q: %w", match[1], err)
+	}
+	return &SecurityScore{Exposure: exposure, Rating: match[2]}, nil
+}
@@ -0,0 +1,91 @@
+package systemd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+)
+
+func TestRenderUnitHardeningProfiles(t *testing.T) {
+	tests := []struct {
+		profile string
+		want    []string
+		absent  []string
+	}{
+		{
+			profile: "",
+			want:    []string{"# Hardening (baseline)", "NoNewPrivileges=true", "ProtectSystem=strict", "ProtectHome=true"},
+			absent:  []string{"PrivateTmp=true", "SystemCallFilter="},
+		},
+		{
+			profile: HardeningStrict,
+			want:    []string{"NoNewPrivileges=true", "PrivateTmp=true", "ProtectKernelModules=true", "SystemCallFilter=@system-service", "CapabilityBoundingSet=\n"},
+			absent:  []string{"MemoryDenyWriteExecute=true"},
+		},
+		{
+			profile: HardeningParanoid,
+			want:    []string{"PrivateDevices=true", "MemoryDenyWriteExecute=true", "ProtectProc=invisible"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			content, err := RenderUnit(ServiceParams{Name: "api", Hardening: tt.profile})
+			if err != nil {
+				t.Fatalf("RenderUnit: %v", err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(content, w) {
+					t.Errorf("unit should contain %q", w)
+				}
+			}
+			for _, a := range tt.absent {
+				if strings.Contains(content, a) {
+					t.Errorf("unit should not contain %q", a)
+				}
+			}
+			if !strings.Contains(content, "StateDirectory=gophercaptain/api") {
+				t.Error("every profile should keep the managed state directory")
+			}
+		})
+	}
+}
+
+func TestRenderUnitUnknownHardening(t *testing.T) {
+	if _, err := RenderUnit(ServiceParams{Name: "api", Hardening: "extreme"}); err == nil {
+		t.Fatal("expected error for unknown hardening profile")
+	}
+}
+
+func TestSecurityScore(t *testing.T) {
+	fake := runner.NewFakeRunner()
+	fake.SetResponse("systemd-analyze security", runner.Response{Stdout: `  NAME                    DESCRIPTION                        EXPOSURE
+✓ PrivateDevices=         Service has no access to hardware devices
+✗ PrivateNetwork=         Service has access to the host's network   0.5
+
+→ Overall exposure level for gc-api.service: 4.6 OK 🙂
+`})
+	mgr := New(fake, t.TempDir())
+
+	score, err := mgr.SecurityScore(context.Background(), "api")
+	if err != nil {
+		t.Fatalf("SecurityScore: %v", err)
+	}
+	if score.Exposure != 4.6 || score.Rating != "OK" {
+		t.Errorf("score = %+v, want 4.6 OK", score)
+	}
+	if !fake.Called("systemd-analyze security --no-pager gc-api.service") {
+		t.Error("expected systemd-analyze to be called for the service unit")
+	}
+}
+
+func TestSecurityScoreUnparseable(t *testing.T) {
+	fake := runner.NewFakeRunner()
+	fake.SetResponse("systemd-analyze security", runner.Response{Stdout: "Unit gc-api.service not found.\n"})
+	mgr := New(fake, t.TempDir())
+
+	if _, err := mgr.SecurityScore(context.Background(), "api"); err == nil {
+		t.Fatal("expected error when output has no exposure level")
+	}
+}
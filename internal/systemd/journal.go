@@ -0,0 +1,67 @@
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// LogOptions selects journal entries for one or more services. Zero values
+// leave journalctl's defaults in place.
+type LogOptions struct {
+	Services []string // service names; entries from several are interleaved by time
+	Follow   bool
+	Lines    int    // -n; 0 = journalctl default
+	Since    string // any journalctl time spec, e.g. "1h ago", "2024-05-01 10:00"
+	Until    string
+	Priority string // e.g. "err", "warning..err", "3"
+	Grep     string
+	Output   string // journalctl -o mode, e.g. "json"
+}
+
+// JournalArgs builds the journalctl arguments for opts. Each service is
+// matched both as gc-<name>.service and, in case it is scaled, as its
+// instances gc-<name>@<port>.service.
+func JournalArgs(opts LogOptions) []string {
+	args := []string{"--no-pager"}
+	for _, name := range opts.Services {
+		args = append(args, "-u", unitName(name), "-u", fmt.Sprintf("gc-%s@*.service", name))
+	}
+	if opts.Follow {
+		args = append(args, "-f")
+	}
+	if opts.Lines > 0 {
+		args = append(args, "-n", strconv.Itoa(opts.Lines))
+	}
+	if opts.Since != "" {
+		args = append(args, "--since", opts.Since)
+	}
+	if opts.Until != "" {
+		args = append(args, "--until", opts.Until)
+	}
+	if opts.Priority != "" {
+		args = append(args, "-p", opts.Priority)
+	}
+	if opts.Grep != "" {
+		args = append(args, "-g", opts.Grep)
+	}
+	if opts.Output != "" {
+		args = append(args, "-o", opts.Output)
+	}
+	return args
+}
+
+// Logs streams journal entries to stdout until journalctl exits or, when
+// following, until ctx is cancelled.
+func (m *Manager) Logs(ctx context.Context, opts LogOptions, stdout, stderr io.Writer) error {
+	if len(opts.Services) == 0 {
+		return errors.New("no services given")
+	}
+	err := m.runner.Stream(ctx, stdout, stderr, "journalctl", JournalArgs(opts)...)
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("reading journal: %w", err)
+	}
+	return nil
+}
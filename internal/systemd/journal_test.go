@@ -0,0 +1,75 @@
+package systemd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+)
+
+func TestJournalArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts LogOptions
+		want string
+	}{
+		{
+			name: "single service",
+			opts: LogOptions{Services: []string{"api"}},
+			want: "--no-pager -u gc-api.service -u gc-api@*.service",
+		},
+		{
+			// Instances of a scaled service run as gc-web@<port>.service
+			name: "scaled service",
+			opts: LogOptions{Services: []string{"web"}, Follow: true},
+			want: "--no-pager -u gc-web.service -u gc-web@*.service -f",
+		},
+		{
+			name: "interleaved services",
+			opts: LogOptions{Services: []string{"api", "worker"}, Lines: 50},
+			want: "--no-pager -u gc-api.service -u gc-api@*.service -u gc-worker.service -u gc-worker@*.service -n 50",
+		},
+		{
+			name: "all filters",
+			opts: LogOptions{
+				Services: []string{"api"},
+				Follow:   true,
+				Since:    "1h ago",
+				Until:    "10 min ago",
+				Priority: "err",
+				Grep:     "timeout",
+				Output:   "json",
+			},
+			want: "--no-pager -u gc-api.service -u gc-api@*.service -f --since 1h ago --until 10 min ago -p err -g timeout -o json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(JournalArgs(tt.opts), " ")
+			if got != tt.want {
+				t.Errorf("args = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogsStreamsOutput(t *testing.T) {
+	fake := runner.NewFakeRunner()
+	fake.SetResponse("journalctl", runner.Response{Stdout: "May 01 10:00:00 host api[42]: listening\n"})
+	mgr := New(fake, t.TempDir())
+
+	var stdout, stderr bytes.Buffer
+	err := mgr.Logs(context.Background(), LogOptions{Services: []string{"api"}}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("Logs: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "listening") {
+		t.Errorf("stdout = %q, want journal output", stdout.String())
+	}
+	if !fake.Called("journalctl --no-pager -u gc-api.service") {
+		t.Error("expected journalctl for gc-api.service")
+	}
+}
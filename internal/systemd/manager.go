@@ -6,38 +6,103 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/ecairns22/GopherCaptain/internal/runner"
+	"github.com/ecairns22/GopherCaptain/internal/templates"
 )
 
-// Manager handles systemd unit lifecycle operations.
+// Manager handles systemd unit lifecycle operations. Unit files, users and
+// helper tools go through the runner; unit state goes through the Controller.
 type Manager struct {
 	runner  runner.CommandRunner
+	ctl     Controller
 	unitDir string
+
+	templates *templates.Dir
 }
 
-// New creates a systemd manager with the given command runner and unit directory.
+// New creates a systemd manager with the given command runner and unit
+// directory, controlling units with systemctl.
 func New(r runner.CommandRunner, unitDir string) *Manager {
-	return &Manager{runner: r, unitDir: unitDir}
+	return NewWithController(r, NewSystemctlController(r), unitDir)
+}
+
+// NewWithController creates a systemd manager that controls units through
+// ctl, e.g. a DBusController.
+func NewWithController(r runner.CommandRunner, ctl Controller, unitDir string) *Manager {
+	return &Manager{runner: r, ctl: ctl, unitDir: unitDir}
+}
+
+// WithTemplates makes the manager render service units with an override
+// from d where one exists, instead of the built-in template.
+func (m *Manager) WithTemplates(d *templates.Dir) *Manager {
+	m.templates = d
+	return m
+}
+
+// renderUnit renders a service or template unit, from an override if any.
+func (m *Manager) renderUnit(params ServiceParams) (string, error) {
+	t, source, err := m.templates.Load(parsedUnitTemplate, params.Name, templates.Unit)
+	if err != nil {
+		return "", err
+	}
+	return renderUnit(t, source, params)
 }
 
 func unitName(name string) string {
 	return fmt.Sprintf("gc-%s.service", name)
 }
 
+func socketName(name string) string {
+	return fmt.Sprintf("gc-%s.socket", name)
+}
+
+func timerName(name string) string {
+	return fmt.Sprintf("gc-%s.timer", name)
+}
+
+func templateName(name string) string {
+	return fmt.Sprintf("gc-%s@.service", name)
+}
+
+func instanceName(name string, port int) string {
+	return fmt.Sprintf("gc-%s@%d.service", name, port)
+}
+
+// StateDir returns the service's StateDirectory=, its persistent data
+// directory and default working directory.
+func StateDir(name string) string {
+	return filepath.Join("/var/lib/gophercaptain", name)
+}
+
+// CacheDir returns the service's CacheDirectory=.
+func CacheDir(name string) string {
+	return filepath.Join("/var/cache/gophercaptain", name)
+}
+
+// LogsDir returns the service's LogsDirectory=.
+func LogsDir(name string) string {
+	return filepath.Join("/var/log/gophercaptain", name)
+}
+
+// DataDirs returns every systemd-managed directory for a service. systemd
+// creates them on start and leaves them in place when the unit is removed.
+func DataDirs(name string) []string {
+	return []string{StateDir(name), CacheDir(name), LogsDir(name)}
+}
+
 func userName(name string) string {
 	return fmt.Sprintf("gc-%s", name)
 }
 
 // WriteUnit renders and writes the systemd unit file for a service.
-func (m *Manager) WriteUnit(ctx context.Context, name string) error {
-	content, err := RenderUnit(ServiceParams{Name: name})
+func (m *Manager) WriteUnit(ctx context.Context, params ServiceParams) error {
+	content, err := m.renderUnit(params)
 	if err != nil {
-		return fmt.Errorf("rendering unit for %s: %w", name, err)
+		return fmt.Errorf("rendering unit for %s: %w", params.Name, err)
 	}
 
-	path := filepath.Join(m.unitDir, unitName(name))
+	path := filepath.Join(m.unitDir, unitName(params.Name))
 	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
 		return fmt.Errorf("writing unit file %s: %w", path, err)
 	}
@@ -46,90 +111,248 @@ func (m *Manager) WriteUnit(ctx context.Context, name string) error {
 
 // RemoveUnit deletes the systemd unit file for a service.
 func (m *Manager) RemoveUnit(name string) error {
-	path := filepath.Join(m.unitDir, unitName(name))
-	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("removing unit file %s: %w", path, err)
+	return m.removeFile(unitName(name))
+}
+
+// WriteTemplate renders and writes the gc-<name>@.service template unit
+// that runs the instances of a scaled service.
+func (m *Manager) WriteTemplate(ctx context.Context, params ServiceParams) error {
+	params.Instanced = true
+	content, err := m.renderUnit(params)
+	if err != nil {
+		return fmt.Errorf("rendering template unit for %s: %w", params.Name, err)
+	}
+
+	path := filepath.Join(m.unitDir, templateName(params.Name))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing unit file %s: %w", path, err)
 	}
 	return nil
 }
 
-// DaemonReload runs systemctl daemon-reload.
-func (m *Manager) DaemonReload(ctx context.Context) error {
-	_, stderr, err := m.runner.Run(ctx, "systemctl", "daemon-reload")
+// RemoveTemplate deletes the template unit file for a service, if any.
+func (m *Manager) RemoveTemplate(name string) error {
+	return m.removeFile(templateName(name))
+}
+
+// WriteSocket renders and writes the socket unit for a socket-activated service.
+func (m *Manager) WriteSocket(ctx context.Context, params SocketParams) error {
+	content, err := RenderSocket(params)
 	if err != nil {
-		return fmt.Errorf("daemon-reload: %s: %w", strings.TrimSpace(stderr), err)
+		return fmt.Errorf("rendering socket for %s: %w", params.Name, err)
+	}
+
+	path := filepath.Join(m.unitDir, socketName(params.Name))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing socket file %s: %w", path, err)
 	}
 	return nil
 }
 
-// Enable enables the service unit.
-func (m *Manager) Enable(ctx context.Context, name string) error {
-	_, stderr, err := m.runner.Run(ctx, "systemctl", "enable", unitName(name))
+// RemoveSocket deletes the socket unit file for a service, if any.
+func (m *Manager) RemoveSocket(name string) error {
+	return m.removeFile(socketName(name))
+}
+
+// WriteTimer renders and writes the timer unit for a scheduled job.
+func (m *Manager) WriteTimer(ctx context.Context, params TimerParams) error {
+	content, err := RenderTimer(params)
 	if err != nil {
-		return fmt.Errorf("enabling %s: %s: %w", name, strings.TrimSpace(stderr), err)
+		return fmt.Errorf("rendering timer for %s: %w", params.Name, err)
+	}
+
+	path := filepath.Join(m.unitDir, timerName(params.Name))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing timer file %s: %w", path, err)
 	}
 	return nil
 }
 
-// Start starts the service and polls for active status up to 10 seconds.
-// On failure, returns an error including journal tail output.
-func (m *Manager) Start(ctx context.Context, name string) error {
-	_, stderr, err := m.runner.Run(ctx, "systemctl", "start", unitName(name))
-	if err != nil {
-		return fmt.Errorf("starting %s: %s: %w", name, strings.TrimSpace(stderr), err)
+// RemoveTimer deletes the timer unit file for a service, if any.
+func (m *Manager) RemoveTimer(name string) error {
+	return m.removeFile(timerName(name))
+}
+
+func (m *Manager) removeFile(unit string) error {
+	path := filepath.Join(m.unitDir, unit)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing unit file %s: %w", path, err)
 	}
+	return nil
+}
 
-	// Poll for active state up to 10 seconds
-	deadline := time.Now().Add(10 * time.Second)
-	for time.Now().Before(deadline) {
-		active, err := m.IsActive(ctx, name)
-		if err == nil && active {
-			return nil
-		}
-		time.Sleep(500 * time.Millisecond)
+// DaemonReload reloads systemd's unit files.
+func (m *Manager) DaemonReload(ctx context.Context) error {
+	return m.ctl.Reload(ctx)
+}
+
+// Enable enables the service unit.
+func (m *Manager) Enable(ctx context.Context, name string) error {
+	return m.enable(ctx, unitName(name), name)
+}
+
+// EnableSocket enables the socket unit of a socket-activated service.
+func (m *Manager) EnableSocket(ctx context.Context, name string) error {
+	return m.enable(ctx, socketName(name), name)
+}
+
+// EnableTimer enables the timer unit of a scheduled job.
+func (m *Manager) EnableTimer(ctx context.Context, name string) error {
+	return m.enable(ctx, timerName(name), name)
+}
+
+// EnableInstance enables one instance of a scaled service.
+func (m *Manager) EnableInstance(ctx context.Context, name string, port int) error {
+	return m.enable(ctx, instanceName(name, port), name)
+}
+
+func (m *Manager) enable(ctx context.Context, unit, name string) error {
+	if err := m.ctl.Enable(ctx, unit); err != nil {
+		return fmt.Errorf("enabling %s: %w", name, err)
 	}
+	return nil
+}
+
+// Start starts the service and waits up to 10 seconds for it to become
+// active. On failure, returns an error including journal tail output.
+func (m *Manager) Start(ctx context.Context, name string) error {
+	return m.start(ctx, unitName(name), name)
+}
+
+// StartSocket starts the socket unit and waits until it is listening.
+// The service itself is left for systemd to start on the first connection.
+func (m *Manager) StartSocket(ctx context.Context, name string) error {
+	return m.start(ctx, socketName(name), name)
+}
+
+// StartTimer starts the timer unit and waits until it is waiting for the
+// next elapse. The job itself is not run.
+func (m *Manager) StartTimer(ctx context.Context, name string) error {
+	return m.start(ctx, timerName(name), name)
+}
 
-	// Service failed to become active — include journal output in error
-	journal, _ := m.JournalTail(ctx, name, 20)
-	return fmt.Errorf("service %s failed to become active within 10s; journal:\n%s", name, journal)
+// StartInstance starts one instance of a scaled service and waits for it
+// to become active.
+func (m *Manager) StartInstance(ctx context.Context, name string, port int) error {
+	return m.start(ctx, instanceName(name, port), name)
+}
+
+func (m *Manager) start(ctx context.Context, unit, name string) error {
+	if err := m.ctl.Start(ctx, unit); err != nil {
+		journal, _ := m.journalTail(ctx, unit, 20)
+		return fmt.Errorf("starting %s: %w; journal:\n%s", name, err, journal)
+	}
+	return nil
 }
 
 // Stop stops the service.
 func (m *Manager) Stop(ctx context.Context, name string) error {
-	_, stderr, err := m.runner.Run(ctx, "systemctl", "stop", unitName(name))
-	if err != nil {
-		return fmt.Errorf("stopping %s: %s: %w", name, strings.TrimSpace(stderr), err)
+	return m.stop(ctx, unitName(name), name)
+}
+
+// StopSocket stops the socket unit so it no longer accepts connections.
+func (m *Manager) StopSocket(ctx context.Context, name string) error {
+	return m.stop(ctx, socketName(name), name)
+}
+
+// StopTimer stops the timer so the job is no longer scheduled.
+func (m *Manager) StopTimer(ctx context.Context, name string) error {
+	return m.stop(ctx, timerName(name), name)
+}
+
+// StopInstance stops one instance of a scaled service.
+func (m *Manager) StopInstance(ctx context.Context, name string, port int) error {
+	return m.stop(ctx, instanceName(name, port), name)
+}
+
+func (m *Manager) stop(ctx context.Context, unit, name string) error {
+	if err := m.ctl.Stop(ctx, unit); err != nil {
+		return fmt.Errorf("stopping %s: %w", name, err)
 	}
 	return nil
 }
 
 // Disable disables the service unit.
 func (m *Manager) Disable(ctx context.Context, name string) error {
-	_, stderr, err := m.runner.Run(ctx, "systemctl", "disable", unitName(name))
-	if err != nil {
-		return fmt.Errorf("disabling %s: %s: %w", name, strings.TrimSpace(stderr), err)
+	return m.disable(ctx, unitName(name), name)
+}
+
+// DisableSocket disables the socket unit.
+func (m *Manager) DisableSocket(ctx context.Context, name string) error {
+	return m.disable(ctx, socketName(name), name)
+}
+
+// DisableTimer disables the timer unit.
+func (m *Manager) DisableTimer(ctx context.Context, name string) error {
+	return m.disable(ctx, timerName(name), name)
+}
+
+// DisableInstance disables one instance of a scaled service.
+func (m *Manager) DisableInstance(ctx context.Context, name string, port int) error {
+	return m.disable(ctx, instanceName(name, port), name)
+}
+
+func (m *Manager) disable(ctx context.Context, unit, name string) error {
+	if err := m.ctl.Disable(ctx, unit); err != nil {
+		return fmt.Errorf("disabling %s: %w", name, err)
 	}
 	return nil
 }
 
+// Status returns the live state of the service unit, including its main
+// PID and restart count.
+func (m *Manager) Status(ctx context.Context, name string) (*UnitStatus, error) {
+	return m.ctl.Status(ctx, unitName(name))
+}
+
+// InstanceStatus returns the live state of one instance of a scaled service.
+func (m *Manager) InstanceStatus(ctx context.Context, name string, port int) (*UnitStatus, error) {
+	return m.ctl.Status(ctx, instanceName(name, port))
+}
+
 // IsActive returns true if the service is in the "active" state.
 func (m *Manager) IsActive(ctx context.Context, name string) (bool, error) {
-	stdout, _, err := m.runner.Run(ctx, "systemctl", "is-active", unitName(name))
-	status := strings.TrimSpace(stdout)
-	if status == "active" {
-		return true, nil
-	}
+	return m.isActive(ctx, unitName(name))
+}
+
+// IsSocketActive returns true if the service's socket unit is listening.
+func (m *Manager) IsSocketActive(ctx context.Context, name string) (bool, error) {
+	return m.isActive(ctx, socketName(name))
+}
+
+// IsTimerActive returns true if the job's timer is scheduled.
+func (m *Manager) IsTimerActive(ctx context.Context, name string) (bool, error) {
+	return m.isActive(ctx, timerName(name))
+}
+
+// IsInstanceActive returns true if the instance of a scaled service is active.
+func (m *Manager) IsInstanceActive(ctx context.Context, name string, port int) (bool, error) {
+	return m.isActive(ctx, instanceName(name, port))
+}
+
+func (m *Manager) isActive(ctx context.Context, unit string) (bool, error) {
+	status, err := m.ctl.Status(ctx, unit)
 	if err != nil {
-		return false, nil // not active
+		return false, err
 	}
-	return false, nil
+	return status.ActiveState == "active", nil
 }
 
 // JournalTail returns the last n lines of journal output for the service.
 func (m *Manager) JournalTail(ctx context.Context, name string, lines int) (string, error) {
-	stdout, _, err := m.runner.Run(ctx, "journalctl", "-u", unitName(name), "-n", fmt.Sprintf("%d", lines), "--no-pager")
+	return m.journalTail(ctx, unitName(name), lines)
+}
+
+// InstanceJournalTail returns the last n lines of journal output for one
+// instance of a scaled service.
+func (m *Manager) InstanceJournalTail(ctx context.Context, name string, port, lines int) (string, error) {
+	return m.journalTail(ctx, instanceName(name, port), lines)
+}
+
+func (m *Manager) journalTail(ctx context.Context, unit string, lines int) (string, error) {
+	stdout, _, err := m.runner.Run(ctx, "journalctl", "-u", unit, "-n", fmt.Sprintf("%d", lines), "--no-pager")
 	if err != nil {
-		return "", fmt.Errorf("reading journal for %s: %w", name, err)
+		return "", fmt.Errorf("reading journal for %s: %w", unit, err)
 	}
 	return stdout, nil
 }
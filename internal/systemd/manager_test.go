@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/ecairns22/GopherCaptain/internal/runner"
+	"github.com/ecairns22/GopherCaptain/internal/templates"
 )
 
 func TestWriteUnitCreatesFile(t *testing.T) {
@@ -17,7 +18,7 @@ func TestWriteUnitCreatesFile(t *testing.T) {
 	mgr := New(fake, dir)
 
 	ctx := context.Background()
-	if err := mgr.WriteUnit(ctx, "api"); err != nil {
+	if err := mgr.WriteUnit(ctx, ServiceParams{Name: "api"}); err != nil {
 		t.Fatalf("WriteUnit: %v", err)
 	}
 
@@ -131,12 +132,75 @@ func TestRenderUnitOutput(t *testing.T) {
 		"Group=gc-myapi",
 		"NoNewPrivileges=true",
 		"WantedBy=multi-user.target",
+		"After=network.target mariadb.service",
 	}
 	for _, check := range checks {
 		if !strings.Contains(content, check) {
 			t.Errorf("unit should contain %q", check)
 		}
 	}
+
+	content, err = RenderUnit(ServiceParams{Name: "myapi", DBProvider: "postgres"})
+	if err != nil {
+		t.Fatalf("RenderUnit: %v", err)
+	}
+	if !strings.Contains(content, "After=network.target postgresql.service") {
+		t.Errorf("postgres service should start after PostgreSQL:\n%s", content)
+	}
+}
+
+func TestRenderUnitArgsAndWorkingDir(t *testing.T) {
+	content, err := RenderUnit(ServiceParams{
+		Name:       "myapi",
+		Args:       []string{"serve", "--config", "/etc/my api.toml", "--rate=100%"},
+		WorkingDir: "/srv/myapi",
+	})
+	if err != nil {
+		t.Fatalf("RenderUnit: %v", err)
+	}
+
+	want := `ExecStart=/opt/gophercaptain/bin/myapi/myapi serve --config "/etc/my api.toml" --rate=100%%` + "\n"
+	if !strings.Contains(content, want) {
+		t.Errorf("unit should contain %q, got:\n%s", want, content)
+	}
+	if !strings.Contains(content, "WorkingDirectory=/srv/myapi\n") {
+		t.Error("unit should use the custom WorkingDirectory")
+	}
+}
+
+func TestRenderUnitDefaultWorkingDir(t *testing.T) {
+	content, err := RenderUnit(ServiceParams{Name: "myapi"})
+	if err != nil {
+		t.Fatalf("RenderUnit: %v", err)
+	}
+	if !strings.Contains(content, "ExecStart=/opt/gophercaptain/bin/myapi/myapi\n") {
+		t.Error("ExecStart should have no trailing arguments by default")
+	}
+	if !strings.Contains(content, "WorkingDirectory=/var/lib/gophercaptain/myapi\n") {
+		t.Error("WorkingDirectory should default to the state directory")
+	}
+}
+
+func TestRenderUnitDataDirectories(t *testing.T) {
+	content, err := RenderUnit(ServiceParams{Name: "myapi"})
+	if err != nil {
+		t.Fatalf("RenderUnit: %v", err)
+	}
+
+	checks := []string{
+		"StateDirectory=gophercaptain/myapi",
+		"CacheDirectory=gophercaptain/myapi",
+		"LogsDirectory=gophercaptain/myapi",
+	}
+	for _, check := range checks {
+		if !strings.Contains(content, check) {
+			t.Errorf("unit should contain %q", check)
+		}
+	}
+	// The bin directory is replaced wholesale on upgrade and must stay read-only
+	if strings.Contains(content, "ReadWritePaths=/opt/gophercaptain/bin") {
+		t.Error("bin directory should no longer be writable")
+	}
 }
 
 func TestRemoveUnit(t *testing.T) {
@@ -165,3 +229,226 @@ func TestRemoveUnitNotExists(t *testing.T) {
 		t.Fatalf("RemoveUnit non-existent: %v", err)
 	}
 }
+
+func TestWriteSocket(t *testing.T) {
+	dir := t.TempDir()
+	mgr := New(runner.NewFakeRunner(), dir)
+
+	if err := mgr.WriteSocket(context.Background(), SocketParams{Name: "tool", Port: 3005}); err != nil {
+		t.Fatalf("WriteSocket: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "gc-tool.socket"))
+	if err != nil {
+		t.Fatalf("reading socket file: %v", err)
+	}
+	content := string(data)
+	if !strings.Contains(content, "ListenStream=127.0.0.1:3005") {
+		t.Error("socket should listen on the allocated port")
+	}
+	if !strings.Contains(content, "WantedBy=sockets.target") {
+		t.Error("socket should be wanted by sockets.target")
+	}
+
+	if err := mgr.RemoveSocket("tool"); err != nil {
+		t.Fatalf("RemoveSocket: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gc-tool.socket")); !os.IsNotExist(err) {
+		t.Error("socket file should be removed")
+	}
+}
+
+func TestRenderUnitSocketActivated(t *testing.T) {
+	content, err := RenderUnit(ServiceParams{Name: "tool", SocketActivated: true})
+	if err != nil {
+		t.Fatalf("RenderUnit: %v", err)
+	}
+	if !strings.Contains(content, "Requires=gc-tool.socket") {
+		t.Error("socket-activated unit should require its socket")
+	}
+	if strings.Contains(content, "[Install]") {
+		t.Error("socket-activated unit should not be installed into multi-user.target")
+	}
+}
+
+func TestStartSocket(t *testing.T) {
+	fake := runner.NewFakeRunner()
+	fake.SetResponse("systemctl is-active gc-tool.socket", runner.Response{Stdout: "active\n"})
+	mgr := New(fake, t.TempDir())
+
+	if err := mgr.StartSocket(context.Background(), "tool"); err != nil {
+		t.Fatalf("StartSocket: %v", err)
+	}
+	if !fake.Called("systemctl start gc-tool.socket") {
+		t.Error("expected the socket unit to be started")
+	}
+	if fake.Called("systemctl start gc-tool.service") {
+		t.Error("the service should be left for socket activation")
+	}
+}
+
+func TestWriteTemplate(t *testing.T) {
+	dir := t.TempDir()
+	mgr := New(runner.NewFakeRunner(), dir)
+
+	if err := mgr.WriteTemplate(context.Background(), ServiceParams{Name: "api"}); err != nil {
+		t.Fatalf("WriteTemplate: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "gc-api@.service"))
+	if err != nil {
+		t.Fatalf("reading template unit: %v", err)
+	}
+	content := string(data)
+	if !strings.Contains(content, "Description=GopherCaptain: api (port %i)") {
+		t.Error("template should name the instance port")
+	}
+	// The instance env file must come after the shared one to override PORT
+	shared := strings.Index(content, "EnvironmentFile=/etc/gophercaptain/api/env")
+	instance := strings.Index(content, "EnvironmentFile=/etc/gophercaptain/api/instance-%i.env")
+	if shared < 0 || instance < shared {
+		t.Errorf("instance env file should follow the shared one, got:\n%s", content)
+	}
+}
+
+func TestInstanceUnits(t *testing.T) {
+	ctl := NewFakeController()
+	mgr := NewWithController(runner.NewFakeRunner(), ctl, t.TempDir())
+	ctx := context.Background()
+
+	if err := mgr.StartInstance(ctx, "api", 3005); err != nil {
+		t.Fatalf("StartInstance: %v", err)
+	}
+	if !ctl.Called("start gc-api@3005.service") {
+		t.Errorf("expected instance unit to be started, calls: %v", ctl.Calls)
+	}
+	if active, _ := mgr.IsInstanceActive(ctx, "api", 3005); !active {
+		t.Error("instance should be active")
+	}
+	if active, _ := mgr.IsActive(ctx, "api"); active {
+		t.Error("the plain unit should be unaffected")
+	}
+}
+
+func TestEnableRenewTimer(t *testing.T) {
+	dir := t.TempDir()
+	ctl := NewFakeController()
+	mgr := NewWithController(runner.NewFakeRunner(), ctl, dir)
+
+	if err := mgr.EnableRenewTimer(context.Background(), "/usr/local/bin/gophercaptain"); err != nil {
+		t.Fatalf("EnableRenewTimer: %v", err)
+	}
+
+	service, err := os.ReadFile(filepath.Join(dir, "gophercaptain-renew.service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(service), "ExecStart=/usr/local/bin/gophercaptain tls renew\n") {
+		t.Errorf("renew service should run tls renew, got:\n%s", service)
+	}
+	timer, err := os.ReadFile(filepath.Join(dir, "gophercaptain-renew.timer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(timer), "OnCalendar=daily") {
+		t.Errorf("renew timer should run daily, got:\n%s", timer)
+	}
+	for _, call := range []string{"enable gophercaptain-renew.timer", "start gophercaptain-renew.timer"} {
+		if !ctl.Called(call) {
+			t.Errorf("expected %q, calls: %v", call, ctl.Calls)
+		}
+	}
+
+	if err := mgr.DisableRenewTimer(context.Background()); err != nil {
+		t.Fatalf("DisableRenewTimer: %v", err)
+	}
+	if !ctl.Called("disable gophercaptain-renew.timer") {
+		t.Errorf("expected the timer to be disabled, calls: %v", ctl.Calls)
+	}
+	for _, file := range []string{"gophercaptain-renew.service", "gophercaptain-renew.timer"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", file)
+		}
+	}
+
+	// Without the timer there is nothing to do
+	ctl.Calls = nil
+	if err := mgr.DisableRenewTimer(context.Background()); err != nil || len(ctl.Calls) != 0 {
+		t.Errorf("DisableRenewTimer without a timer = %v, calls %v", err, ctl.Calls)
+	}
+}
+
+func TestBackupTimer(t *testing.T) {
+	dir := t.TempDir()
+	ctl := NewFakeController()
+	mgr := NewWithController(runner.NewFakeRunner(), ctl, dir)
+	ctx := context.Background()
+
+	err := mgr.EnableBackupTimer(ctx, BackupParams{
+		Binary:     "/usr/local/bin/gophercaptain",
+		Name:       "api",
+		Schedule:   "*-*-* 03:00",
+		DBProvider: "postgres",
+	})
+	if err != nil {
+		t.Fatalf("EnableBackupTimer: %v", err)
+	}
+
+	service, err := os.ReadFile(filepath.Join(dir, "gophercaptain-backup-api.service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"After=postgresql.service\n", "ExecStart=/usr/local/bin/gophercaptain db backup api\n"} {
+		if !strings.Contains(string(service), want) {
+			t.Errorf("backup service missing %q, got:\n%s", want, service)
+		}
+	}
+	timer, err := os.ReadFile(filepath.Join(dir, "gophercaptain-backup-api.timer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(timer), "OnCalendar=*-*-* 03:00\n") {
+		t.Errorf("backup timer should use the schedule, got:\n%s", timer)
+	}
+	for _, call := range []string{"enable gophercaptain-backup-api.timer", "start gophercaptain-backup-api.timer"} {
+		if !ctl.Called(call) {
+			t.Errorf("expected %q, calls: %v", call, ctl.Calls)
+		}
+	}
+
+	if err := mgr.DisableBackupTimer(ctx, "api"); err != nil {
+		t.Fatalf("DisableBackupTimer: %v", err)
+	}
+	if !ctl.Called("disable gophercaptain-backup-api.timer") {
+		t.Errorf("expected the timer to be disabled, calls: %v", ctl.Calls)
+	}
+	for _, file := range []string{"gophercaptain-backup-api.service", "gophercaptain-backup-api.timer"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", file)
+		}
+	}
+}
+
+func TestWriteUnitOverride(t *testing.T) {
+	dir := t.TempDir()
+	tmplDir := t.TempDir()
+	override := filepath.Join(tmplDir, templates.Unit)
+	os.WriteFile(override, []byte(`[Service]
+ExecStart=/usr/local/bin/wrap {{.Name}} {{.Version}}
+Environment=REGION={{index .Env "REGION"}}
+{{range hardening .Hardening}}{{.}}
+{{end}}`), 0644)
+
+	mgr := New(runner.NewFakeRunner(), dir).WithTemplates(templates.New(tmplDir))
+	params := ServiceParams{Name: "api", Version: "v1.2.0", Env: map[string]string{"REGION": "eu"}}
+	if err := mgr.WriteUnit(context.Background(), params); err != nil {
+		t.Fatalf("WriteUnit: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "gc-api.service"))
+	content := string(data)
+	for _, want := range []string{"# Template: " + override, "ExecStart=/usr/local/bin/wrap api v1.2.0", "Environment=REGION=eu", "NoNewPrivileges=true"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("unit should contain %q, got:\n%s", want, content)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package systemd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// renewUnit runs certificate renewal for all ACME routes. It belongs to the
+// tool rather than a service, so it has no gc- prefix.
+const renewUnit = "gophercaptain-renew"
+
+const renewServiceTemplate = `[Unit]
+Description=GopherCaptain: renew TLS certificates
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=oneshot
+ExecStart={{escapeArg .}} tls renew
+`
+
+// renewTimerTemplate checks daily; renewal only happens within 30 days of
+// expiry, and the random delay spreads load on the CA.
+const renewTimerTemplate = `[Unit]
+Description=GopherCaptain: renew TLS certificates (timer)
+
+[Timer]
+OnCalendar=daily
+RandomizedDelaySec=1h
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+
+var parsedRenewServiceTemplate = template.Must(template.New("renew").Funcs(template.FuncMap{
+	"escapeArg": escapeArg,
+}).Parse(renewServiceTemplate))
+
+// EnableRenewTimer installs gophercaptain-renew.timer, which runs
+// "<binary> tls renew" daily, and starts it. It is safe to call repeatedly.
+func (m *Manager) EnableRenewTimer(ctx context.Context, binary string) error {
+	var service bytes.Buffer
+	if err := parsedRenewServiceTemplate.Execute(&service, binary); err != nil {
+		return fmt.Errorf("rendering renew unit: %w", err)
+	}
+	files := map[string]string{
+		renewUnit + ".service": service.String(),
+		renewUnit + ".timer":   renewTimerTemplate,
+	}
+	for unit, content := range files {
+		path := filepath.Join(m.unitDir, unit)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("writing unit file %s: %w", path, err)
+		}
+	}
+
+	if err := m.DaemonReload(ctx); err != nil {
+		return err
+	}
+	if err := m.enable(ctx, renewUnit+".timer", renewUnit); err != nil {
+		return err
+	}
+	return m.start(ctx, renewUnit+".timer", renewUnit)
+}
+
+// DisableRenewTimer stops and removes gophercaptain-renew.timer once no
+// route needs renewal. It is safe to call when the timer is not installed.
+func (m *Manager) DisableRenewTimer(ctx context.Context) error {
+	timer := renewUnit + ".timer"
+	if _, err := os.Stat(filepath.Join(m.unitDir, timer)); os.IsNotExist(err) {
+		return nil
+	}
+	if err := m.stop(ctx, timer, renewUnit); err != nil {
+		return err
+	}
+	if err := m.disable(ctx, timer, renewUnit); err != nil {
+		return err
+	}
+	for _, unit := range []string{timer, renewUnit + ".service"} {
+		if err := m.removeFile(unit); err != nil {
+			return err
+		}
+	}
+	return m.DaemonReload(ctx)
+}
@@ -0,0 +1,53 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RunOnce runs a one-off command as the service would run: as its user,
+// with its env file, working directory, data directories and hardening
+// profile. It blocks until the command exits and fails on a non-zero exit.
+// The command is not quoted for the unit file, so args go through verbatim.
+func (m *Manager) RunOnce(ctx context.Context, params ServiceParams, binary string, args []string) error {
+	if params.WorkingDir == "" {
+		params.WorkingDir = StateDir(params.Name)
+	}
+	if params.Hardening == "" {
+		params.Hardening = HardeningBaseline
+	}
+	if err := ValidateHardening(params.Hardening); err != nil {
+		return err
+	}
+
+	user := userName(params.Name)
+	dataDir := "gophercaptain/" + params.Name
+	props := []string{
+		"User=" + user,
+		"Group=" + user,
+		fmt.Sprintf("EnvironmentFile=/etc/gophercaptain/%s/env", params.Name),
+		"WorkingDirectory=" + params.WorkingDir,
+		"StateDirectory=" + dataDir,
+		"CacheDirectory=" + dataDir,
+		"LogsDirectory=" + dataDir,
+	}
+	props = append(props, hardeningDirectives(params.Hardening)...)
+
+	cmdArgs := []string{"--wait", "--collect", "--pipe", "--quiet"}
+	for _, p := range props {
+		cmdArgs = append(cmdArgs, "-p", p)
+	}
+	cmdArgs = append(cmdArgs, binary)
+	cmdArgs = append(cmdArgs, args...)
+
+	stdout, stderr, err := m.runner.Run(ctx, "systemd-run", cmdArgs...)
+	if err != nil {
+		output := strings.TrimSpace(stderr)
+		if output == "" {
+			output = strings.TrimSpace(stdout)
+		}
+		return fmt.Errorf("running %s %s for %s: %s: %w", binary, strings.Join(args, " "), params.Name, output, err)
+	}
+	return nil
+}
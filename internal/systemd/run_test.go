@@ -0,0 +1,57 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+)
+
+func TestRunOnceUsesServiceSandbox(t *testing.T) {
+	fake := runner.NewFakeRunner()
+	mgr := New(fake, t.TempDir())
+
+	binary := "/opt/gophercaptain/bin/api/api-v1.1.0"
+	err := mgr.RunOnce(context.Background(), ServiceParams{Name: "api"}, binary, []string{"migrate", "up"})
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+
+	if len(fake.Calls) != 1 {
+		t.Fatalf("calls = %d, want 1", len(fake.Calls))
+	}
+	call := fake.Calls[0].String()
+	for _, want := range []string{
+		"systemd-run --wait --collect --pipe --quiet",
+		"-p User=gc-api -p Group=gc-api",
+		"-p EnvironmentFile=/etc/gophercaptain/api/env",
+		"-p WorkingDirectory=/var/lib/gophercaptain/api",
+		"-p NoNewPrivileges=true",
+	} {
+		if !strings.Contains(call, want) {
+			t.Errorf("call %q should contain %q", call, want)
+		}
+	}
+	if !strings.HasSuffix(call, binary+" migrate up") {
+		t.Errorf("call %q should end with the command", call)
+	}
+}
+
+func TestRunOnceNonZeroExit(t *testing.T) {
+	fake := runner.NewFakeRunner()
+	fake.SetResponse("systemd-run", runner.Response{
+		Stderr: "migration 0042 failed: column exists",
+		Err:    fmt.Errorf("exit status 1"),
+	})
+	mgr := New(fake, t.TempDir())
+
+	err := mgr.RunOnce(context.Background(), ServiceParams{Name: "api"}, "/bin/api", []string{"migrate", "up"})
+	if err == nil {
+		t.Fatal("expected error for non-zero exit")
+	}
+	if !strings.Contains(err.Error(), "column exists") {
+		t.Errorf("error %q should include the command output", err)
+	}
+}
@@ -3,43 +3,167 @@ package systemd
 import (
 	"bytes"
 	"text/template"
+
+	"github.com/ecairns22/GopherCaptain/internal/templates"
 )
 
 const unitTemplate = `[Unit]
-Description=GopherCaptain: {{.Name}}
-After=network.target mariadb.service
+Description=GopherCaptain: {{.Name}}{{if .Instanced}} (port %i){{end}}
+After=network.target {{.DatabaseUnit}}{{if .SocketActivated}} gc-{{.Name}}.socket
+Requires=gc-{{.Name}}.socket{{end}}
 
 [Service]
+{{- if .Scheduled}}
+Type=oneshot
+{{- else}}
 Type=simple
-ExecStart=/opt/gophercaptain/bin/{{.Name}}/{{.Name}}
+{{- end}}
+ExecStart=/opt/gophercaptain/bin/{{.Name}}/{{.Name}}{{range .Args}} {{escapeArg .}}{{end}}
 EnvironmentFile=/etc/gophercaptain/{{.Name}}/env
+{{- if .Instanced}}
+EnvironmentFile=/etc/gophercaptain/{{.Name}}/instance-%i.env
+{{- end}}
+{{- if not .Scheduled}}
 Restart=on-failure
 RestartSec=5
+{{- end}}
 User=gc-{{.Name}}
 Group=gc-{{.Name}}
-WorkingDirectory=/opt/gophercaptain/bin/{{.Name}}
-
-# Hardening
-NoNewPrivileges=true
-ProtectSystem=strict
-ProtectHome=true
-ReadWritePaths=/opt/gophercaptain/bin/{{.Name}}
+WorkingDirectory={{.WorkingDir}}
+StateDirectory=gophercaptain/{{.Name}}
+CacheDirectory=gophercaptain/{{.Name}}
+LogsDirectory=gophercaptain/{{.Name}}
 
+# Hardening ({{.Hardening}})
+{{range hardening .Hardening}}{{.}}
+{{end}}
+{{- if not (or .SocketActivated .Scheduled)}}
 [Install]
 WantedBy=multi-user.target
+{{end}}`
+
+// socketTemplate listens on the service's port and starts gc-<name>.service
+// on the first connection.
+const socketTemplate = `[Unit]
+Description=GopherCaptain: {{.Name}} (socket)
+
+[Socket]
+ListenStream=127.0.0.1:{{.Port}}
+
+[Install]
+WantedBy=sockets.target
+`
+
+// timerTemplate runs gc-<name>.service on a calendar schedule. Persistent
+// catches up on runs missed while the machine was off.
+const timerTemplate = `[Unit]
+Description=GopherCaptain: {{.Name}} (timer)
+
+[Timer]
+OnCalendar={{.Schedule}}
+Persistent=true
+Unit=gc-{{.Name}}.service
+
+[Install]
+WantedBy=timers.target
 `
 
-var parsedUnitTemplate = template.Must(template.New("unit").Parse(unitTemplate))
+var parsedUnitTemplate = template.Must(template.New("unit").Funcs(template.FuncMap{
+	"escapeArg": escapeArg,
+	"hardening": hardeningDirectives,
+}).Parse(unitTemplate))
+var parsedSocketTemplate = template.Must(template.New("socket").Parse(socketTemplate))
+var parsedTimerTemplate = template.Must(template.New("timer").Parse(timerTemplate))
 
 // ServiceParams holds values for the systemd unit template.
 type ServiceParams struct {
+	Name       string
+	Args       []string // extra ExecStart arguments, unescaped
+	WorkingDir string   // defaults to the service's state directory
+	Hardening  string   // hardening profile name, defaults to baseline
+
+	// SocketActivated ties the service to gc-<name>.socket and leaves it
+	// out of multi-user.target so it only starts on demand.
+	SocketActivated bool
+
+	// Scheduled makes the service a oneshot job run by gc-<name>.timer
+	// rather than a long-running server.
+	Scheduled bool
+
+	// Instanced renders the gc-<name>@.service template for scaled
+	// services. The instance name is the port, and the instance's env file
+	// overrides PORT from the shared one.
+	Instanced bool
+
+	// DBProvider is the service's database provider, "postgres" to order
+	// the unit after PostgreSQL rather than MariaDB.
+	DBProvider string
+
+	// Metadata for override templates; the built-in one does not use it
+	Version string
+	Repo    string
+	Port    int
+	Env     map[string]string // the service's --env values and PORT
+}
+
+// DatabaseUnit returns the database server unit the service starts after.
+func (p ServiceParams) DatabaseUnit() string {
+	return databaseUnit(p.DBProvider)
+}
+
+// databaseUnit returns the systemd unit of a database provider's server.
+func databaseUnit(provider string) string {
+	if provider == "postgres" {
+		return "postgresql.service"
+	}
+	return "mariadb.service"
+}
+
+// TimerParams holds values for the systemd timer template.
+type TimerParams struct {
+	Name     string
+	Schedule string // OnCalendar= expression, e.g. "*-*-* 02:00"
+}
+
+// SocketParams holds values for the systemd socket template.
+type SocketParams struct {
 	Name string
+	Port int
 }
 
 // RenderUnit renders the systemd unit file for the given parameters.
 func RenderUnit(params ServiceParams) (string, error) {
+	return renderUnit(parsedUnitTemplate, templates.Builtin, params)
+}
+
+// renderUnit renders the unit file with t.
+func renderUnit(t *template.Template, source string, params ServiceParams) (string, error) {
+	if params.WorkingDir == "" {
+		params.WorkingDir = StateDir(params.Name)
+	}
+	if params.Hardening == "" {
+		params.Hardening = HardeningBaseline
+	}
+	if err := ValidateHardening(params.Hardening); err != nil {
+		return "", err
+	}
+
+	return templates.Execute(t, source, params)
+}
+
+// RenderSocket renders the systemd socket unit for the given parameters.
+func RenderSocket(params SocketParams) (string, error) {
+	var buf bytes.Buffer
+	if err := parsedSocketTemplate.Execute(&buf, params); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderTimer renders the systemd timer unit for the given parameters.
+func RenderTimer(params TimerParams) (string, error) {
 	var buf bytes.Buffer
-	if err := parsedUnitTemplate.Execute(&buf, params); err != nil {
+	if err := parsedTimerTemplate.Execute(&buf, params); err != nil {
 		return "", err
 	}
 	return buf.String(), nil
@@ -0,0 +1,56 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JobStatus describes the last and next run of a scheduled job.
+type JobStatus struct {
+	LastRun    string // empty if the job has never run
+	NextRun    string // empty if nothing is scheduled
+	Result     string // systemd result of the last run, e.g. "success", "exit-code"
+	ExitStatus int    // exit code of the last run
+}
+
+// ValidateSchedule checks an OnCalendar= expression with systemd-analyze.
+func (m *Manager) ValidateSchedule(ctx context.Context, schedule string) error {
+	_, stderr, err := m.runner.Run(ctx, "systemd-analyze", "calendar", schedule)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q: %s", schedule, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// JobStatus reads the last/next run from the timer and the outcome of the
+// last run from the service.
+func (m *Manager) JobStatus(ctx context.Context, name string) (*JobStatus, error) {
+	timer, err := show(ctx, m.runner, timerName(name), "LastTriggerUSec", "NextElapseUSecRealtime")
+	if err != nil {
+		return nil, err
+	}
+	service, err := show(ctx, m.runner, unitName(name), "Result", "ExecMainStatus")
+	if err != nil {
+		return nil, err
+	}
+
+	status := &JobStatus{
+		LastRun: timestamp(timer["LastTriggerUSec"]),
+		NextRun: timestamp(timer["NextElapseUSecRealtime"]),
+		Result:  service["Result"],
+	}
+	if code, err := strconv.Atoi(service["ExecMainStatus"]); err == nil {
+		status.ExitStatus = code
+	}
+	return status, nil
+}
+
+// timestamp normalizes systemd's "n/a" and zero timestamps to empty.
+func timestamp(v string) string {
+	if v == "n/a" || v == "0" {
+		return ""
+	}
+	return v
+}
@@ -0,0 +1,113 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ecairns22/GopherCaptain/internal/runner"
+)
+
+func TestRenderUnitScheduled(t *testing.T) {
+	content, err := RenderUnit(ServiceParams{Name: "report", Scheduled: true})
+	if err != nil {
+		t.Fatalf("RenderUnit: %v", err)
+	}
+	if !strings.Contains(content, "Type=oneshot") {
+		t.Error("scheduled unit should be a oneshot service")
+	}
+	if strings.Contains(content, "Restart=") {
+		t.Error("scheduled unit should not restart")
+	}
+	if strings.Contains(content, "[Install]") {
+		t.Error("scheduled unit is started by its timer, not installed")
+	}
+}
+
+func TestWriteTimer(t *testing.T) {
+	dir := t.TempDir()
+	mgr := New(runner.NewFakeRunner(), dir)
+
+	if err := mgr.WriteTimer(context.Background(), TimerParams{Name: "report", Schedule: "*-*-* 02:00"}); err != nil {
+		t.Fatalf("WriteTimer: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "gc-report.timer"))
+	if err != nil {
+		t.Fatalf("reading timer file: %v", err)
+	}
+	content := string(data)
+	for _, check := range []string{"OnCalendar=*-*-* 02:00", "Unit=gc-report.service", "WantedBy=timers.target"} {
+		if !strings.Contains(content, check) {
+			t.Errorf("timer should contain %q", check)
+		}
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	fake := runner.NewFakeRunner()
+	mgr := New(fake, t.TempDir())
+
+	if err := mgr.ValidateSchedule(context.Background(), "daily"); err != nil {
+		t.Fatalf("ValidateSchedule: %v", err)
+	}
+	if !fake.Called("systemd-analyze calendar daily") {
+		t.Error("expected systemd-analyze calendar to be called")
+	}
+
+	fake.SetResponse("systemd-analyze calendar", runner.Response{
+		Stderr: "Failed to parse calendar specification 'sometimes': Invalid argument",
+		Err:    fmt.Errorf("exit status 1"),
+	})
+	err := mgr.ValidateSchedule(context.Background(), "sometimes")
+	if err == nil || !strings.Contains(err.Error(), "Failed to parse") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
+
+func TestJobStatus(t *testing.T) {
+	fake := runner.NewFakeRunner()
+	fake.SetResponse("systemctl show gc-report.timer -p LastTriggerUSec -p NextElapseUSecRealtime", runner.Response{
+		Stdout: "LastTriggerUSec=Sat 2026-10-17 02:00:03 UTC\nNextElapseUSecRealtime=Sun 2026-10-18 02:00:00 UTC\n",
+	})
+	fake.SetResponse("systemctl show gc-report.service -p Result -p ExecMainStatus", runner.Response{
+		Stdout: "Result=exit-code\nExecMainStatus=2\n",
+	})
+	mgr := New(fake, t.TempDir())
+
+	status, err := mgr.JobStatus(context.Background(), "report")
+	if err != nil {
+		t.Fatalf("JobStatus: %v", err)
+	}
+	if status.LastRun != "Sat 2026-10-17 02:00:03 UTC" {
+		t.Errorf("LastRun = %q", status.LastRun)
+	}
+	if status.NextRun != "Sun 2026-10-18 02:00:00 UTC" {
+		t.Errorf("NextRun = %q", status.NextRun)
+	}
+	if status.Result != "exit-code" || status.ExitStatus != 2 {
+		t.Errorf("result = %q/%d, want exit-code/2", status.Result, status.ExitStatus)
+	}
+}
+
+func TestJobStatusNeverRun(t *testing.T) {
+	fake := runner.NewFakeRunner()
+	fake.SetResponse("systemctl show gc-report.timer -p LastTriggerUSec -p NextElapseUSecRealtime", runner.Response{
+		Stdout: "LastTriggerUSec=n/a\nNextElapseUSecRealtime=Sun 2026-10-18 02:00:00 UTC\n",
+	})
+	fake.SetResponse("systemctl show gc-report.service -p Result -p ExecMainStatus", runner.Response{
+		Stdout: "Result=success\nExecMainStatus=0\n",
+	})
+	mgr := New(fake, t.TempDir())
+
+	status, err := mgr.JobStatus(context.Background(), "report")
+	if err != nil {
+		t.Fatalf("JobStatus: %v", err)
+	}
+	if status.LastRun != "" {
+		t.Errorf("LastRun should be empty for a job that never ran, got %q", status.LastRun)
+	}
+}
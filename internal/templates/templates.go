@@ -0,0 +1,99 @@
+// Package templates finds operator overrides of the built-in nginx and
+// systemd templates. An override in <dir>/<service>/<file> applies to one
+// service and wins over <dir>/<file>, which applies to all of them.
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Override file names, one per overridable template.
+const (
+	NginxConfig   = "nginx.conf.tmpl"          // gc-<name>.conf
+	NginxLocation = "nginx-location.conf.tmpl" // path route snippet per host
+	Unit          = "unit.service.tmpl"        // gc-<name>.service and gc-<name>@.service
+)
+
+// Builtin is the source reported for output of a compiled-in template.
+const Builtin = "built-in"
+
+// header starts the first line of output rendered from an override.
+const header = "# Template: "
+
+// Dir is a directory of override templates. A nil Dir has no overrides.
+type Dir struct {
+	path string
+}
+
+// New returns the override directory at path.
+func New(path string) *Dir {
+	return &Dir{path: path}
+}
+
+// Find returns the override of file for a service, or "" when the built-in
+// template applies.
+func (d *Dir) Find(service, file string) string {
+	if d == nil || d.path == "" {
+		return ""
+	}
+	for _, path := range []string{filepath.Join(d.path, service, file), filepath.Join(d.path, file)} {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Load returns the template to render file with for a service, and its
+// source: the override parsed into a clone of base, so it can use base's
+// functions and named templates, or base itself. Overrides are validated
+// here, and a broken one is an error rather than a silent fallback.
+func (d *Dir) Load(base *template.Template, service, file string) (*template.Template, string, error) {
+	path := d.Find(service, file)
+	if path == "" {
+		return base, Builtin, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading template %s: %w", path, err)
+	}
+	clone, err := base.Clone()
+	if err != nil {
+		return nil, "", fmt.Errorf("loading template %s: %w", path, err)
+	}
+	t, err := clone.New(filepath.Base(path)).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing template %s: %w", path, err)
+	}
+	return t, path, nil
+}
+
+// Execute renders t with data. Output from an override is stamped with its
+// source so inspect can report it.
+func Execute(t *template.Template, source string, data any) (string, error) {
+	var buf bytes.Buffer
+	if source != Builtin {
+		buf.WriteString(header + source + "\n")
+	}
+	if err := t.Execute(&buf, data); err != nil {
+		if source != Builtin {
+			return "", fmt.Errorf("rendering template %s: %w", source, err)
+		}
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Source returns the template that rendered content, as stamped by Execute.
+func Source(content string) string {
+	line, _, _ := strings.Cut(content, "\n")
+	if source, ok := strings.CutPrefix(line, header); ok {
+		return source
+	}
+	return Builtin
+}
@@ -0,0 +1,106 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+var base = template.Must(template.New("base").Parse(`{{define "greet"}}hello {{.Name}}{{end}}{{template "greet" .}}`))
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindPrefersService(t *testing.T) {
+	dir := t.TempDir()
+	d := New(dir)
+	if got := d.Find("api", Unit); got != "" {
+		t.Errorf("Find with no overrides = %q, want none", got)
+	}
+
+	global := filepath.Join(dir, Unit)
+	writeFile(t, global, "global")
+	if got := d.Find("api", Unit); got != global {
+		t.Errorf("Find = %q, want %q", got, global)
+	}
+
+	own := filepath.Join(dir, "api", Unit)
+	writeFile(t, own, "own")
+	if got := d.Find("api", Unit); got != own {
+		t.Errorf("Find = %q, want %q", got, own)
+	}
+	if got := d.Find("web", Unit); got != global {
+		t.Errorf("Find for another service = %q, want %q", got, global)
+	}
+
+	var none *Dir
+	if got := none.Find("api", Unit); got != "" {
+		t.Errorf("nil Dir Find = %q, want none", got)
+	}
+}
+
+func TestLoadBuiltin(t *testing.T) {
+	tmpl, source, err := New(t.TempDir()).Load(base, "api", Unit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Execute(tmpl, source, struct{ Name string }{"api"})
+	if err != nil || out != "hello api" || source != Builtin || Source(out) != Builtin {
+		t.Errorf("builtin render = %q, %q, %v", out, source, err)
+	}
+}
+
+func TestLoadOverride(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, Unit)
+	writeFile(t, path, `{{template "greet" .}} from {{index .Env "REGION"}}`)
+
+	tmpl, source, err := New(dir).Load(base, "api", Unit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := struct {
+		Name string
+		Env  map[string]string
+	}{"api", map[string]string{"REGION": "eu"}}
+	out, err := Execute(tmpl, source, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out, "hello api from eu") || Source(out) != path {
+		t.Errorf("override render = %q, source %q", out, Source(out))
+	}
+
+	// The base template is unchanged
+	out, _ = Execute(base, Builtin, data)
+	if out != "hello api" {
+		t.Errorf("base render = %q", out)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "api", Unit)
+	writeFile(t, path, `{{if .Name}}unterminated`)
+	if _, _, err := New(dir).Load(base, "api", Unit); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("expected a parse error naming %s, got %v", path, err)
+	}
+
+	writeFile(t, path, `{{.Missing}}`)
+	tmpl, source, err := New(dir).Load(base, "api", Unit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Execute(tmpl, source, struct{ Name string }{"api"}); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("expected an execution error naming %s, got %v", path, err)
+	}
+}
@@ -0,0 +1,139 @@
+// Package traffic summarizes the JSON access logs nginx writes for each
+// service in the gophercaptain log_format.
+package traffic
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// entry is the part of a log line a summary needs.
+type entry struct {
+	Time         string `json:"time"`
+	Status       int    `json:"status"`
+	UpstreamTime string `json:"upstream_time"` // "0.012", "0.010, 0.004" after retries, or "" / "-" without one
+}
+
+// Summary describes the requests logged within a window.
+type Summary struct {
+	Window   time.Duration
+	Requests int
+	Codes    map[int]int   // requests per status code
+	P50      time.Duration // upstream latency; zero when no request reached the service
+	P95      time.Duration
+}
+
+// Rate returns requests per second over the window.
+func (s *Summary) Rate() float64 {
+	return float64(s.Requests) / s.Window.Seconds()
+}
+
+// Class returns the number of requests with a status in class, e.g. 5
+// for 5xx.
+func (s *Summary) Class(class int) int {
+	n := 0
+	for code, count := range s.Codes {
+		if code/100 == class {
+			n += count
+		}
+	}
+	return n
+}
+
+// Share returns n as a percentage of all requests.
+func (s *Summary) Share(n int) float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(s.Requests)
+}
+
+// SortedCodes returns the status codes seen, ascending.
+func (s *Summary) SortedCodes() []int {
+	codes := make([]int, 0, len(s.Codes))
+	for code := range s.Codes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+// Summarize reads the requests logged to path in the window before now,
+// including those in the last rotated file (path.1). A missing log means
+// no traffic yet. Lines that are not in the gophercaptain format are
+// skipped.
+func Summarize(path string, window time.Duration, now time.Time) (*Summary, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("window must be positive")
+	}
+	s := &Summary{Window: window, Codes: make(map[int]int)}
+	since := now.Add(-window)
+	var latencies []float64
+	for _, p := range []string{path + ".1", path} {
+		f, err := os.Open(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading access log: %w", err)
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var e entry
+			if json.Unmarshal(scanner.Bytes(), &e) != nil || e.Status == 0 {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, e.Time)
+			if err != nil || t.Before(since) || t.After(now) {
+				continue
+			}
+			s.Requests++
+			s.Codes[e.Status]++
+			if latency, ok := upstreamSeconds(e.UpstreamTime); ok {
+				latencies = append(latencies, latency)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading access log %s: %w", p, err)
+		}
+	}
+
+	sort.Float64s(latencies)
+	s.P50 = percentile(latencies, 0.50)
+	s.P95 = percentile(latencies, 0.95)
+	return s, nil
+}
+
+// upstreamSeconds adds up the upstream times of a request, which nginx
+// lists once per upstream tried.
+func upstreamSeconds(field string) (float64, bool) {
+	total, ok := 0.0, false
+	for _, part := range strings.Split(field, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			continue
+		}
+		total += v
+		ok = true
+	}
+	return total, ok
+}
+
+// percentile returns the nearest-rank percentile p of sorted seconds.
+func percentile(sorted []float64, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return time.Duration(sorted[max(i, 0)] * float64(time.Second))
+}
@@ -0,0 +1,75 @@
+package traffic
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func logLine(t time.Time, status int, upstream string) string {
+	return fmt.Sprintf(`{"time":"%s","remote_addr":"10.0.0.1","host":"api.example.com","method":"GET","uri":"/","status":%d,"bytes":12,"request_time":0.010,"upstream_time":"%s","upstream":"127.0.0.1:3000"}`,
+		t.Format(time.RFC3339), status, upstream)
+}
+
+func TestSummarize(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "gc-api.access.log")
+
+	var lines []string
+	// Outside the window
+	lines = append(lines, logLine(now.Add(-2*time.Hour), 200, "0.500"))
+	for i := 1; i <= 18; i++ {
+		lines = append(lines, logLine(now.Add(-time.Duration(i)*time.Minute), 200, fmt.Sprintf("0.%03d", i*10)))
+	}
+	lines = append(lines,
+		logLine(now.Add(-time.Minute), 404, "0.001, 0.004"),
+		logLine(now.Add(-time.Minute), 502, "-"),
+		"not json",
+		`{"time":"garbage","status":200}`,
+	)
+	os.WriteFile(path+".1", []byte(lines[0]+"\n"+lines[1]+"\n"), 0644)
+	os.WriteFile(path, []byte(strings.Join(lines[2:], "\n")+"\n"), 0644)
+
+	s, err := Summarize(path, time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Requests != 20 {
+		t.Errorf("requests = %d, want 20", s.Requests)
+	}
+	if s.Codes[200] != 18 || s.Codes[404] != 1 || s.Codes[502] != 1 {
+		t.Errorf("codes = %v", s.Codes)
+	}
+	if s.Class(2) != 18 || s.Class(4) != 1 || s.Class(5) != 1 {
+		t.Errorf("classes = %d %d %d", s.Class(2), s.Class(4), s.Class(5))
+	}
+	if got := s.Share(s.Class(5)); got != 5 {
+		t.Errorf("5xx share = %v, want 5", got)
+	}
+	if got := s.Rate(); got != 20.0/3600 {
+		t.Errorf("rate = %v", got)
+	}
+	// 19 upstream times: 0.005 and 0.010..0.180; the 502 never reached one
+	if s.P50 != 90*time.Millisecond {
+		t.Errorf("p50 = %v, want 90ms", s.P50)
+	}
+	if s.P95 != 180*time.Millisecond {
+		t.Errorf("p95 = %v, want 180ms", s.P95)
+	}
+	if codes := s.SortedCodes(); fmt.Sprint(codes) != "[200 404 502]" {
+		t.Errorf("sorted codes = %v", codes)
+	}
+}
+
+func TestSummarizeMissingLog(t *testing.T) {
+	s, err := Summarize(filepath.Join(t.TempDir(), "gc-none.access.log"), time.Hour, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Requests != 0 || s.P95 != 0 {
+		t.Errorf("expected an empty summary, got %+v", s)
+	}
+}